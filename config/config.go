@@ -14,6 +14,28 @@ type AppConfig struct {
 	APIKey    string
 	GinMode   string
 	JWTExpiry time.Duration
+
+	// Attachments
+	StorageDriver  string // local|s3
+	StorageDir     string
+	S3Endpoint     string
+	S3Bucket       string
+	S3Region       string
+	S3AccessKey    string
+	S3SecretKey    string
+	MaxUploadBytes int64
+	UserQuotaBytes int64
+	DownloadURLTTL time.Duration
+
+	// Trash
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
+
+	// Manual ordering
+	RebalanceInterval time.Duration
+
+	// Snoozed tasks
+	SnoozeWakeInterval time.Duration
 }
 
 var C AppConfig
@@ -26,6 +48,24 @@ func Load() {
 		APIKey:    os.Getenv("API_KEY"),
 		GinMode:   getEnv("GIN_MODE", "release"),
 		JWTExpiry: getDuration("JWT_EXP_MIN", 30),
+
+		StorageDriver:  getEnv("STORAGE_DRIVER", "local"),
+		StorageDir:     getEnv("STORAGE_DIR", "./data/uploads"),
+		S3Endpoint:     os.Getenv("S3_ENDPOINT"),
+		S3Bucket:       os.Getenv("S3_BUCKET"),
+		S3Region:       getEnv("S3_REGION", "us-east-1"),
+		S3AccessKey:    os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:    os.Getenv("S3_SECRET_KEY"),
+		MaxUploadBytes: getMegabytes("MAX_UPLOAD_MB", 10),
+		UserQuotaBytes: getMegabytes("USER_QUOTA_MB", 100),
+		DownloadURLTTL: getDuration("DOWNLOAD_URL_TTL_MIN", 15),
+
+		TrashRetention:     getDays("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeInterval: getDuration("TRASH_PURGE_INTERVAL_MIN", 60),
+
+		RebalanceInterval: getDuration("POSITION_REBALANCE_INTERVAL_MIN", 60),
+
+		SnoozeWakeInterval: getDuration("SNOOZE_WAKE_INTERVAL_MIN", 1),
 	}
 }
 
@@ -48,4 +88,16 @@ func getDuration(k string, def int) time.Duration {
 	}
 	return time.Duration(def) * time.Minute
 }
+func getDays(k string, def int) time.Duration {
+	if v := os.Getenv(k); v != "" {
+		return time.Duration(atoi(v)) * 24 * time.Hour
+	}
+	return time.Duration(def) * 24 * time.Hour
+}
+func getMegabytes(k string, def int) int64 {
+	if v := os.Getenv(k); v != "" {
+		return int64(atoi(v)) << 20
+	}
+	return int64(def) << 20
+}
 func atoi(s string) int { var n int; fmt.Sscanf(s, "%d", &n); return n }
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SearchEnabled reports whether SetupSearch created the full-text index
+var SearchEnabled bool
+
+// ErrSearchUnsupported means the database can't hold the search index,
+// e.g. SQLite built without FTS5 (the sqlite_fts5 build tag)
+var ErrSearchUnsupported = errors.New("full-text search not supported by this database")
+
+// SetupSearch creates the full-text index over task titles and
+// descriptions: a generated tsvector column with a GIN index on Postgres,
+// an external-content FTS5 table kept in sync by triggers on SQLite.
+func SetupSearch(db *gorm.DB) error {
+	SearchEnabled = false
+	var stmts []string
+	switch db.Dialector.Name() {
+	case "postgres":
+		stmts = []string{
+			`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(description, '')), 'B')) STORED`,
+			`CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks USING GIN (search_vector)`,
+		}
+	case "sqlite":
+		stmts = []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS tasks_fts USING fts5(
+				title, description, content='tasks', content_rowid='id', tokenize='porter unicode61')`,
+			`CREATE TRIGGER IF NOT EXISTS tasks_fts_insert AFTER INSERT ON tasks BEGIN
+				INSERT INTO tasks_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS tasks_fts_delete AFTER DELETE ON tasks BEGIN
+				INSERT INTO tasks_fts(tasks_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS tasks_fts_update AFTER UPDATE OF title, description ON tasks BEGIN
+				INSERT INTO tasks_fts(tasks_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+				INSERT INTO tasks_fts(rowid, title, description) VALUES (new.id, new.title, new.description);
+			END`,
+			`INSERT INTO tasks_fts(tasks_fts) VALUES ('rebuild')`,
+		}
+	default:
+		return ErrSearchUnsupported
+	}
+	for _, stmt := range stmts {
+		if err := db.Exec(stmt).Error; err != nil {
+			if strings.Contains(err.Error(), "no such module") {
+				return ErrSearchUnsupported
+			}
+			return err
+		}
+	}
+	SearchEnabled = true
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"log"
+
+	"go-todo-app/storage"
+)
+
+var Blobs storage.BlobStore
+
+func ConnectStorage() {
+	switch C.StorageDriver {
+	case "s3":
+		if C.S3Endpoint == "" || C.S3Bucket == "" {
+			log.Fatalf("S3_ENDPOINT and S3_BUCKET are required for the s3 storage driver")
+		}
+		Blobs = storage.NewS3Store(C.S3Endpoint, C.S3Bucket, C.S3Region, C.S3AccessKey, C.S3SecretKey)
+	case "local":
+		store, err := storage.NewLocalStore(C.StorageDir)
+		if err != nil {
+			log.Fatalf("failed to init storage dir: %v", err)
+		}
+		Blobs = store
+	default:
+		log.Fatalf("unknown STORAGE_DRIVER %q", C.StorageDriver)
+	}
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// errForbidden means the user can see a resource but their role doesn't
+// allow the requested change
+var errForbidden = errors.New("forbidden")
+
+// visibleTasks scopes a task query to what the user may read: their own
+// tasks outside any project plus every task in a project they own or are a
+// member of. Tasks in unshared projects therefore stay private.
+func visibleTasks(userID int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("((tasks.project_id IS NULL AND tasks.user_id = ?)"+
+			" OR tasks.project_id IN (SELECT id FROM projects WHERE user_id = ?)"+
+			" OR tasks.project_id IN (SELECT project_id FROM project_members WHERE user_id = ?))",
+			userID, userID, userID)
+	}
+}
+
+// editableTasks is visibleTasks restricted to projects where the user is
+// an owner or editor
+func editableTasks(userID int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("((tasks.project_id IS NULL AND tasks.user_id = ?)"+
+			" OR tasks.project_id IN (SELECT id FROM projects WHERE user_id = ?)"+
+			" OR tasks.project_id IN (SELECT project_id FROM project_members WHERE user_id = ? AND role IN ?))",
+			userID, userID, userID, []string{models.ProjectRoleOwner, models.ProjectRoleEditor})
+	}
+}
+
+// projectRole returns the user's role in the project, or "" without access
+func projectRole(tx *gorm.DB, userID int64, project models.Project) (string, error) {
+	if project.UserID == userID {
+		return models.ProjectRoleOwner, nil
+	}
+	var m models.ProjectMember
+	err := tx.Where("project_id = ? AND user_id = ?", project.ID, userID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return m.Role, err
+}
+
+// findAccessibleProject loads a project the user owns or belongs to along
+// with their role in it. Projects without access are reported as not found.
+func findAccessibleProject(tx *gorm.DB, userID, projectID int64) (models.Project, string, error) {
+	var project models.Project
+	if err := tx.First(&project, projectID).Error; err != nil {
+		return project, "", err
+	}
+	role, err := projectRole(tx, userID, project)
+	if err != nil {
+		return project, "", err
+	}
+	if role == "" {
+		return project, "", gorm.ErrRecordNotFound
+	}
+	return project, role, nil
+}
+
+// findTask loads a task visible to the user. With write set, viewers get
+// errForbidden instead of the task.
+func findTask(tx *gorm.DB, userID, taskID int64, write bool) (models.Task, error) {
+	var task models.Task
+	if err := tx.Scopes(visibleTasks(userID)).Where("tasks.id = ?", taskID).First(&task).Error; err != nil {
+		return task, err
+	}
+	if write {
+		ok, err := canEditTask(tx, userID, task)
+		if err != nil {
+			return task, err
+		}
+		if !ok {
+			return task, errForbidden
+		}
+	}
+	return task, nil
+}
+
+// canEditTask reports whether a user can change the task, i.e. is not a
+// viewer of its project
+func canEditTask(tx *gorm.DB, userID int64, task models.Task) (bool, error) {
+	var n int64
+	err := tx.Model(&models.Task{}).Scopes(editableTasks(userID)).Where("tasks.id = ?", task.ID).Count(&n).Error
+	return n > 0, err
+}
+
+// taskErrorResponse maps findTask errors to a response
+func taskErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "task not found"})
+	case errors.Is(err, errForbidden):
+		helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "read-only access to this task"})
+	default:
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+	}
+}
+
+// requireProject loads a project for a handler, writing the error response
+// itself. minRole "owner" restricts to owners, "editor" to owners and
+// editors, "" to any member.
+func requireProject(c *gin.Context, userID, projectID int64, minRole string) (models.Project, string, bool) {
+	project, role, err := findAccessibleProject(config.DB, userID, projectID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "project not found"})
+		} else {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		}
+		return project, role, false
+	}
+	allowed := true
+	switch minRole {
+	case models.ProjectRoleOwner:
+		allowed = role == models.ProjectRoleOwner
+	case models.ProjectRoleEditor:
+		allowed = models.CanEditTasks(role)
+	}
+	if !allowed {
+		helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "requires " + minRole + " role"})
+		return project, role, false
+	}
+	return project, role, true
+}
+
+// canAccessTask reports whether a user can see the task, e.g. to validate
+// assignees and mentions
+func canAccessTask(tx *gorm.DB, userID int64, task models.Task) (bool, error) {
+	if task.ProjectID == nil {
+		return task.UserID == userID, nil
+	}
+	var project models.Project
+	if err := tx.First(&project, *task.ProjectID).Error; err != nil {
+		return false, err
+	}
+	role, err := projectRole(tx, userID, project)
+	return role != "", err
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// taskSnapshot returns the audited fields of a task as strings, nil for
+// unset optional fields
+func taskSnapshot(t *models.Task) map[string]*string {
+	str := func(s string) *string { return &s }
+	id := func(p *int64) *string {
+		if p == nil {
+			return nil
+		}
+		return str(strconv.FormatInt(*p, 10))
+	}
+	var due, estimate, recurrence, tags, snoozed *string
+	if t.DueAt != nil {
+		due = str(t.DueAt.UTC().Format(time.RFC3339))
+	}
+	if t.SnoozedUntil != nil {
+		snoozed = str(t.SnoozedUntil.UTC().Format(time.RFC3339))
+	}
+	if t.EstimateMinutes != nil {
+		estimate = str(strconv.Itoa(*t.EstimateMinutes))
+	}
+	if t.Recurrence != "" {
+		recurrence = str(t.Recurrence)
+	}
+	if len(t.Tags) > 0 {
+		tags = str(strings.Join(t.Tags, ","))
+	}
+	snapshot := map[string]*string{
+		"title":       str(t.Title),
+		"description": str(t.Description),
+		"priority":    str(t.Priority),
+		"status":      str(t.Status),
+		"project_id":  id(t.ProjectID),
+		"assignee_id": id(t.AssigneeID),
+		"due_at":      due,
+		"tags":        tags,
+		"starred":     str(strconv.FormatBool(t.Starred)),
+		"pinned":      str(strconv.FormatBool(t.Pinned)),
+
+		"estimate_minutes": estimate,
+		"recurrence":       recurrence,
+		"snoozed_until":    snoozed,
+	}
+	for k, v := range t.CustomFields {
+		var s string
+		switch v := v.(type) {
+		case []string:
+			s = strings.Join(v, ",")
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			s = fmt.Sprint(v)
+		}
+		snapshot["custom_fields."+k] = &s
+	}
+	return snapshot
+}
+
+// recordTaskEvent writes a single audit entry for an action that doesn't
+// change fields, such as a deletion or a restore
+func recordTaskEvent(tx *gorm.DB, c *gin.Context, task *models.Task, action string) error {
+	uid, _ := c.Get("user_id")
+	return tx.Create(&models.TaskActivity{
+		TaskID:    task.ID,
+		ProjectID: task.ProjectID,
+		ActorID:   uid.(int64),
+		Action:    action,
+		RequestID: c.GetString("request_id"),
+	}).Error
+}
+
+// recordTaskActivity writes the audit trail for a change to a task. before
+// is nil for creations and after is nil for deletions; updates get one
+// entry per changed field. The actor and request ID come from the context.
+func recordTaskActivity(tx *gorm.DB, c *gin.Context, before, after *models.Task) error {
+	uid, _ := c.Get("user_id")
+	base := models.TaskActivity{
+		ActorID:   uid.(int64),
+		RequestID: c.GetString("request_id"),
+	}
+
+	switch {
+	case before == nil:
+		base.TaskID, base.ProjectID, base.Action = after.ID, after.ProjectID, models.ActivityCreated
+	case after == nil:
+		return recordTaskEvent(tx, c, before, models.ActivityDeleted)
+	default:
+		base.TaskID, base.ProjectID, base.Action = after.ID, after.ProjectID, models.ActivityUpdated
+	}
+
+	var old map[string]*string
+	if before != nil {
+		old = taskSnapshot(before)
+	}
+	cur := taskSnapshot(after)
+	fields := make([]string, 0, len(cur))
+	for f := range cur {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	var entries []models.TaskActivity
+	for _, f := range fields {
+		o, n := old[f], cur[f]
+		if o == nil && n == nil || o != nil && n != nil && *o == *n {
+			continue
+		}
+		// a new task's empty fields and unset flags aren't worth an entry
+		if before == nil && n != nil && (*n == "" || *n == "false") {
+			continue
+		}
+		e := base
+		e.Field, e.OldValue, e.NewValue = f, o, n
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return tx.Create(&entries).Error
+}
+
+func listActivity(c *gin.Context, q *gorm.DB) {
+	page, pageSize := pageParams(c)
+	var total int64
+	if err := q.Model(&models.TaskActivity{}).Count(&total).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail count"})
+		return
+	}
+	var entries []models.TaskActivity
+	if err := q.Preload("Actor").Order("id desc").Limit(pageSize).Offset((page - 1) * pageSize).
+		Find(&entries).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"activity": entries,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// GetTaskHistory returns the change log of one task, newest first
+func GetTaskHistory(c *gin.Context) {
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	listActivity(c, config.DB.Where("task_id = ?", task.ID))
+}
+
+// GetActivityFeed returns the user's own changes plus changes to tasks they
+// can see, including deletions in projects they belong to
+func GetActivityFeed(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	userID := uid.(int64)
+	visible := config.DB.Model(&models.Task{}).Select("tasks.id").Scopes(visibleTasks(userID))
+	q := config.DB.Where("(actor_id = ? OR task_id IN (?)"+
+		" OR project_id IN (SELECT id FROM projects WHERE user_id = ?)"+
+		" OR project_id IN (SELECT project_id FROM project_members WHERE user_id = ?))",
+		userID, visible, userID, userID)
+	if v := c.Query("project_id"); v != "" {
+		projectID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid project_id"})
+			return
+		}
+		q = q.Where("project_id = ?", projectID)
+	}
+	listActivity(c, q)
+}
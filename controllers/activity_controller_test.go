@@ -0,0 +1,99 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/middlewares"
+	"go-todo-app/models"
+)
+
+func TestTaskHistoryAndFeed(t *testing.T) {
+	r, api := newTestAPI()
+	api.Use(middlewares.RequestID())
+	api.POST("/tasks", controllers.CreateTask)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+	api.DELETE("/tasks/:id", controllers.DeleteTask)
+	api.GET("/tasks/:id/history", controllers.GetTaskHistory)
+	api.GET("/activity", controllers.GetActivityFeed)
+
+	u := seedUser(t, "auditor")
+	other := seedUser(t, "bystander")
+
+	w := doJSON(r, "POST", "/api/tasks", gin.H{"title": "audit me"}, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	taskID := int64(responseData(t, w)["id"].(float64))
+
+	req, _ := http.NewRequest("PATCH", fmt.Sprintf("/api/tasks/%d", taskID),
+		strings.NewReader(`{"status":"completed","title":"audit me"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", strconv.FormatInt(u.ID, 10))
+	req.Header.Set(middlewares.RequestIDHeader, "req-123")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	expectStatus(t, w, http.StatusOK)
+
+	w = doJSON(r, "GET", fmt.Sprintf("/api/tasks/%d/history", taskID), nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	entries := responseData(t, w)["activity"].([]interface{})
+	latest := entries[0].(map[string]interface{})
+	// only the status changed; the unchanged title isn't logged
+	if latest["action"] != "updated" || latest["field"] != "status" ||
+		latest["old_value"] != "pending" || latest["new_value"] != "completed" ||
+		latest["request_id"] != "req-123" {
+		t.Fatalf("latest entry=%v", latest)
+	}
+	for _, e := range entries[1:] {
+		if e.(map[string]interface{})["action"] != "created" {
+			t.Fatalf("unexpected entry %v", e)
+		}
+	}
+
+	w = doJSON(r, "DELETE", fmt.Sprintf("/api/tasks/%d", taskID), nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+
+	w = doJSON(r, "GET", "/api/activity", nil, u.ID)
+	feed := responseData(t, w)["activity"].([]interface{})
+	if feed[0].(map[string]interface{})["action"] != "deleted" {
+		t.Fatalf("feed head=%v", feed[0])
+	}
+	w = doJSON(r, "GET", "/api/activity", nil, other.ID)
+	if n := len(responseData(t, w)["activity"].([]interface{})); n != 0 {
+		t.Fatalf("other user sees %d entries of a private task", n)
+	}
+}
+
+func TestActivityActorsHideEmails(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks/:id/history", controllers.GetTaskHistory)
+	api.GET("/activity", controllers.GetActivityFeed)
+
+	owner := seedUser(t, "historian")
+	viewer := seedUser(t, "reader")
+	project := models.Project{UserID: owner.ID, Name: "Archive"}
+	config.DB.Create(&project)
+	config.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: viewer.ID, Role: models.ProjectRoleViewer})
+
+	w := doJSON(r, "POST", "/api/tasks", gin.H{"title": "catalogue", "project_id": project.ID}, owner.ID)
+	expectStatus(t, w, http.StatusCreated)
+	for _, path := range []string{fmt.Sprintf("/api/tasks/%v/history", responseData(t, w)["id"]), "/api/activity"} {
+		w = doJSON(r, "GET", path, nil, viewer.ID)
+		expectStatus(t, w, http.StatusOK)
+		entries := responseData(t, w)["activity"].([]interface{})
+		if len(entries) == 0 {
+			t.Fatalf("%s: no activity", path)
+		}
+		actor := entries[0].(map[string]interface{})["actor"].(map[string]interface{})
+		if actor["username"] != owner.Username || strings.Contains(w.Body.String(), owner.Email) {
+			t.Fatalf("%s: actor=%v body=%s", path, actor, w.Body.String())
+		}
+	}
+}
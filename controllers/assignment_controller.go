@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// AssignTask sets the task's assignee. The assignee must have access to the
+// task and is notified about it.
+func AssignTask(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	var in struct {
+		UserID int64 `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	ok, err := canAccessTask(config.DB, in.UserID, task)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if !ok {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "assignee has no access to this task"})
+		return
+	}
+	if task.AssigneeID != nil && *task.AssigneeID == in.UserID {
+		assignmentResponse(c, task)
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		before := task
+		task.AssigneeID = &in.UserID
+		task.Version++
+		if err := tx.Model(&task).Updates(map[string]interface{}{"assignee_id": in.UserID, "version": nextVersion}).Error; err != nil {
+			return err
+		}
+		if err := recordTaskActivity(tx, c, &before, &task); err != nil {
+			return err
+		}
+		actor := uid.(int64)
+		return notify(tx, models.Notification{
+			UserID:  in.UserID,
+			ActorID: &actor,
+			TaskID:  &task.ID,
+			Type:    models.NotificationTaskAssigned,
+			Message: fmt.Sprintf("You were assigned to %q", task.Title),
+		})
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	assignmentResponse(c, task)
+}
+
+func UnassignTask(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	if task.AssigneeID == nil {
+		assignmentResponse(c, task)
+		return
+	}
+	before := task
+	task.AssigneeID = nil
+	task.Version++
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Updates(map[string]interface{}{"assignee_id": nil, "version": nextVersion}).Error; err != nil {
+			return err
+		}
+		return recordTaskActivity(tx, c, &before, &task)
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	assignmentResponse(c, task)
+}
+
+// assignmentResponse answers with the task as GET returns it, so the ETag
+// matches
+func assignmentResponse(c *gin.Context, task models.Task) {
+	tasks := []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	taskResponse(c, http.StatusOK, "Updated", tasks[0])
+}
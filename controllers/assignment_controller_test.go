@@ -0,0 +1,79 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func TestAssignTask(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.GET("/tasks/:id", controllers.GetTask)
+	api.PUT("/tasks/:id/assignee", controllers.AssignTask)
+	api.DELETE("/tasks/:id/assignee", controllers.UnassignTask)
+	api.GET("/notifications", controllers.GetNotifications)
+
+	owner := seedUser(t, "assigner")
+	member := seedUser(t, "assignee")
+	stranger := seedUser(t, "stranger")
+
+	project := models.Project{UserID: owner.ID, Name: "Shared"}
+	config.DB.Create(&project)
+	config.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: member.ID, Role: models.ProjectRoleViewer})
+
+	w := doJSON(r, "POST", "/api/tasks", gin.H{"title": "do it", "project_id": project.ID}, owner.ID)
+	expectStatus(t, w, http.StatusCreated)
+	taskID := int64(responseData(t, w)["id"].(float64))
+	doJSON(r, "POST", "/api/tasks", gin.H{"title": "unassigned", "project_id": project.ID}, owner.ID)
+
+	// users without access to the task can't be assigned
+	w = doJSON(r, "PUT", fmt.Sprintf("/api/tasks/%d/assignee", taskID), gin.H{"user_id": stranger.ID}, owner.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	w = doJSON(r, "PUT", fmt.Sprintf("/api/tasks/%d/assignee", taskID), gin.H{"user_id": member.ID}, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+
+	w = doJSON(r, "GET", "/api/notifications", nil, member.ID)
+	notes := responseData(t, w)["notifications"].([]interface{})
+	if len(notes) != 1 || notes[0].(map[string]interface{})["type"] != models.NotificationTaskAssigned {
+		t.Fatalf("assignee notifications=%v", notes)
+	}
+
+	for query, want := range map[string]int{
+		"assignee=me":                        1,
+		"assignee=none":                      1,
+		fmt.Sprintf("assignee=%d", owner.ID): 0,
+	} {
+		w = doJSON(r, "GET", "/api/tasks?"+query, nil, member.ID)
+		expectStatus(t, w, http.StatusOK)
+		if n := len(responseData(t, w)["tasks"].([]interface{})); n != want {
+			t.Fatalf("%s: %d tasks want %d", query, n, want)
+		}
+	}
+
+	w = doJSON(r, "DELETE", fmt.Sprintf("/api/tasks/%d/assignee", taskID), nil, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+	if a := responseData(t, w)["assignee_id"]; a != nil {
+		t.Fatalf("assignee_id=%v after unassign", a)
+	}
+
+	// unassigning an unassigned task changes nothing, and answers with the
+	// ETag GET gives
+	version := responseData(t, w)["version"]
+	w = doJSON(r, "DELETE", fmt.Sprintf("/api/tasks/%d/assignee", taskID), nil, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+	if v := responseData(t, w)["version"]; v != version {
+		t.Fatalf("version=%v want %v", v, version)
+	}
+	get := doJSON(r, "GET", fmt.Sprintf("/api/tasks/%d", taskID), nil, owner.ID)
+	if etag := w.Header().Get("ETag"); etag == "" || etag != get.Header().Get("ETag") {
+		t.Fatalf("ETag %q, GET gives %q", etag, get.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+)
+
+// sniffLen is how much of a file http.DetectContentType looks at
+const sniffLen = 512
+
+func withDownloadURL(a *models.Attachment) {
+	url, expires := helpers.SignedDownloadURL(a.ID)
+	a.DownloadURL = url
+	a.URLExpires = &expires
+}
+
+func loadAttachment(c *gin.Context, task models.Task) (models.Attachment, bool) {
+	var a models.Attachment
+	id, err := strconv.ParseInt(c.Param("attachment_id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid attachment id"})
+		return a, false
+	}
+	if err := config.DB.Where("id = ? AND task_id = ?", id, task.ID).First(&a).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "attachment not found"})
+		return a, false
+	}
+	return a, true
+}
+
+// UploadAttachment stores a multipart "file" for the task. The content type
+// is sniffed from the data rather than trusted from the client, and uploads
+// count against the uploader's storage quota.
+func UploadAttachment(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+
+	// leave some room for the multipart framing around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.C.MaxUploadBytes+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "multipart field \"file\" is required and must be within the size limit"})
+		return
+	}
+	if header.Size > config.C.MaxUploadBytes {
+		helpers.ErrorResponse(c, http.StatusRequestEntityTooLarge, "File too large", gin.H{
+			"details": fmt.Sprintf("files may be at most %d bytes", config.C.MaxUploadBytes),
+		})
+		return
+	}
+
+	var used int64
+	if err := config.DB.Model(&models.Attachment{}).Where("user_id = ?", uid.(int64)).
+		Select("COALESCE(SUM(size), 0)").Scan(&used).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if used+header.Size > config.C.UserQuotaBytes {
+		helpers.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Quota exceeded", gin.H{
+			"details": fmt.Sprintf("storage quota of %d bytes exceeded (%d bytes used)", config.C.UserQuotaBytes, used),
+		})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "cannot read upload"})
+		return
+	}
+	defer file.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "cannot read upload"})
+		return
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !models.IsAllowedAttachmentType(contentType) {
+		helpers.ErrorResponse(c, http.StatusUnsupportedMediaType, "Unsupported file type", gin.H{"details": contentType})
+		return
+	}
+
+	name := filepath.Base(strings.ReplaceAll(header.Filename, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		name = "file"
+	}
+	if len(name) > 255 {
+		// keep the end, with the extension, without splitting a character
+		start := len(name) - 255
+		for !utf8.RuneStart(name[start]) {
+			start++
+		}
+		name = name[start:]
+	}
+	attachment := models.Attachment{
+		TaskID:      task.ID,
+		UserID:      uid.(int64),
+		FileName:    name,
+		ContentType: contentType,
+		Size:        header.Size,
+		StorageKey:  fmt.Sprintf("tasks/%d/%s", task.ID, uuid.New().String()),
+	}
+	body := io.MultiReader(bytes.NewReader(head), file)
+	if err := config.Blobs.Put(c.Request.Context(), attachment.StorageKey, body, header.Size, contentType); err != nil {
+		log.Printf("attachment upload failed: %v", err)
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail store file"})
+		return
+	}
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		config.Blobs.Delete(c.Request.Context(), attachment.StorageKey)
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create attachment"})
+		return
+	}
+	withDownloadURL(&attachment)
+	helpers.APIResponse(c, http.StatusCreated, "Attachment uploaded", attachment)
+}
+
+func GetAttachments(c *gin.Context) {
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	var attachments []models.Attachment
+	if err := config.DB.Where("task_id = ?", task.ID).Order("id asc").Find(&attachments).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	for i := range attachments {
+		withDownloadURL(&attachments[i])
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"attachments": attachments})
+}
+
+// GetAttachment returns one attachment with a fresh signed download URL
+func GetAttachment(c *gin.Context) {
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	attachment, ok := loadAttachment(c, task)
+	if !ok {
+		return
+	}
+	withDownloadURL(&attachment)
+	helpers.APIResponse(c, http.StatusOK, "OK", attachment)
+}
+
+// DeleteAttachment removes an attachment; allowed for its uploader and for
+// users who can edit the task
+func DeleteAttachment(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	attachment, ok := loadAttachment(c, task)
+	if !ok {
+		return
+	}
+	if attachment.UserID != uid.(int64) {
+		canEdit, err := canEditTask(config.DB, uid.(int64), task)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if !canEdit {
+			helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "cannot delete this attachment"})
+			return
+		}
+	}
+	if err := config.DB.Delete(&attachment).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
+		return
+	}
+	if err := config.Blobs.Delete(c.Request.Context(), attachment.StorageKey); err != nil {
+		log.Printf("attachment blob %s not deleted: %v", attachment.StorageKey, err)
+	}
+	helpers.APIResponse(c, http.StatusOK, "Deleted", gin.H{"id": attachment.ID})
+}
+
+// DownloadAttachment serves a file through a signed URL from
+// SignedDownloadURL. It is mounted outside the JWT-protected group so the
+// URL can be used directly by browsers.
+func DownloadAttachment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid attachment id"})
+		return
+	}
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if !helpers.VerifyDownloadSignature(id, expires, c.Query("signature")) {
+		helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "invalid or expired download link"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := config.DB.First(&attachment, id).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "attachment not found"})
+		return
+	}
+	rc, err := config.Blobs.Get(c.Request.Context(), attachment.StorageKey)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "file not found"})
+		return
+	}
+	defer rc.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, rc, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+		"Cache-Control":       "private, no-store",
+	})
+}
@@ -0,0 +1,102 @@
+package controllers_test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/storage"
+)
+
+func upload(r *gin.Engine, path, name string, data []byte, userID int64) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, _ := mw.CreateFormFile("file", name)
+	fw.Write(data)
+	mw.Close()
+	req, _ := http.NewRequest("POST", path, &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-Test-User", strconv.FormatInt(userID, 10))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAttachments(t *testing.T) {
+	r, api := newTestAPI()
+	r.GET("/files/:id", controllers.DownloadAttachment)
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks/:id/attachments", controllers.GetAttachments)
+	api.POST("/tasks/:id/attachments", controllers.UploadAttachment)
+	api.DELETE("/tasks/:id/attachments/:attachment_id", controllers.DeleteAttachment)
+
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Blobs = store
+	config.C.MaxUploadBytes = 1 << 10
+	config.C.UserQuotaBytes = 1 << 10
+	config.C.DownloadURLTTL = time.Minute
+
+	u := seedUser(t, "uploader")
+	w := doJSON(r, "POST", "/api/tasks", gin.H{"title": "with files"}, u.ID)
+	taskID := int64(responseData(t, w)["id"].(float64))
+	base := fmt.Sprintf("/api/tasks/%d/attachments", taskID)
+
+	// the content type comes from the bytes, not the file name
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+	w = upload(r, base, "shot.txt", png, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	att := responseData(t, w)
+	if att["content_type"] != "image/png" {
+		t.Fatalf("content_type=%v want image/png", att["content_type"])
+	}
+
+	w = upload(r, base, "tool.exe", []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff"), u.ID)
+	expectStatus(t, w, http.StatusUnsupportedMediaType)
+
+	w = upload(r, base, "big.txt", bytes.Repeat([]byte("a"), 2<<10), u.ID)
+	expectStatus(t, w, http.StatusRequestEntityTooLarge)
+
+	// 108 bytes used, 1000 more would exceed the 1 KiB quota
+	w = upload(r, base, "notes.txt", bytes.Repeat([]byte("b"), 1000), u.ID)
+	expectStatus(t, w, http.StatusRequestEntityTooLarge)
+	if !strings.Contains(w.Body.String(), "quota") {
+		t.Fatalf("expected quota error, got %s", w.Body.String())
+	}
+
+	// signed URLs download without a bearer token; tampering is rejected
+	url := att["download_url"].(string)
+	w = doJSON(r, "GET", url, nil, 0)
+	expectStatus(t, w, http.StatusOK)
+	if !bytes.Equal(w.Body.Bytes(), png) {
+		t.Fatal("downloaded bytes differ")
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "shot.txt") {
+		t.Fatalf("Content-Disposition=%q", cd)
+	}
+	w = doJSON(r, "GET", strings.Replace(url, "/files/", "/files/9", 1), nil, 0)
+	expectStatus(t, w, http.StatusForbidden)
+
+	w = doJSON(r, "DELETE", fmt.Sprintf("%s/%v", base, att["id"]), nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	w = doJSON(r, "GET", url, nil, 0)
+	expectStatus(t, w, http.StatusNotFound)
+	// long names keep their end and stay valid UTF-8
+	w = upload(r, base, strings.Repeat("é", 130)+".txt", []byte("hi"), u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	if name := responseData(t, w)["file_name"].(string); len(name) > 255 || !utf8.ValidString(name) || !strings.HasSuffix(name, "é.txt") {
+		t.Fatalf("file_name=%q", name)
+	}
+}
@@ -0,0 +1,541 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const MaxWIPLimit = 1000
+
+// boardNone is the key of the column holding tasks without an assignee, or
+// without tags. Tag names can't start with an underscore, so no tag column
+// shares it.
+const boardNone = "_none"
+
+// errWIPLimit means a move would take a column past its WIP limit
+var errWIPLimit = errors.New("column is at its WIP limit")
+
+// boardColumn is one column of a board with a page of its tasks, in their
+// manual order
+type boardColumn struct {
+	Key        string        `json:"key"`
+	Name       string        `json:"name"`
+	Category   string        `json:"category,omitempty"` // of a status column
+	WIPLimit   *int          `json:"wip_limit"`
+	OverLimit  bool          `json:"over_limit"` // holds more tasks than its limit, filters aside
+	Count      int64         `json:"count"`
+	Tasks      []models.Task `json:"tasks"`
+	Pagination gin.H         `json:"pagination"`
+}
+
+// boardColumns lists the columns of the project's board for a grouping:
+// the workflow's statuses, the priorities from high to low, the members and
+// current assignees, or the tags in use, with a column for tasks that have
+// no assignee or tag first
+func boardColumns(tx *gorm.DB, project models.Project, groupBy string) ([]boardColumn, error) {
+	var columns []boardColumn
+	switch groupBy {
+	case models.BoardByStatus:
+		workflow, _, err := projectWorkflow(tx, project)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range workflow {
+			columns = append(columns, boardColumn{Key: s.Key, Name: s.Name, Category: s.Category})
+		}
+	case models.BoardByPriority:
+		for i := len(models.ValidTaskPriorities) - 1; i >= 0; i-- {
+			p := models.ValidTaskPriorities[i]
+			columns = append(columns, boardColumn{Key: p, Name: strings.ToUpper(p[:1]) + p[1:]})
+		}
+	case models.BoardByAssignee:
+		columns = append(columns, boardColumn{Key: boardNone, Name: "Unassigned"})
+		var users []models.User
+		if err := tx.Where("id = ? OR id IN (SELECT user_id FROM project_members WHERE project_id = ?)"+
+			" OR id IN (SELECT assignee_id FROM tasks WHERE project_id = ? AND deleted_at IS NULL)",
+			project.UserID, project.ID, project.ID).Order("username").Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			columns = append(columns, boardColumn{Key: strconv.FormatInt(u.ID, 10), Name: u.Username})
+		}
+	case models.BoardByTag:
+		columns = append(columns, boardColumn{Key: boardNone, Name: "Untagged"})
+		var names []string
+		if err := tx.Table("tags").Distinct("tags.name").
+			Joins("JOIN task_tags ON task_tags.tag_id = tags.id").
+			Joins("JOIN tasks ON tasks.id = task_tags.task_id").
+			Where("tasks.project_id = ? AND tasks.deleted_at IS NULL", project.ID).
+			Order("tags.name").Pluck("tags.name", &names).Error; err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			columns = append(columns, boardColumn{Key: n, Name: n})
+		}
+	}
+	return columns, nil
+}
+
+// boardColumnScope matches the tasks in one column
+func boardColumnScope(groupBy, key string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch {
+		case groupBy == models.BoardByStatus:
+			return db.Where("tasks.status = ?", key)
+		case groupBy == models.BoardByPriority:
+			return db.Where("tasks.priority = ?", key)
+		case groupBy == models.BoardByAssignee && key == boardNone:
+			return db.Where("tasks.assignee_id IS NULL")
+		case groupBy == models.BoardByAssignee:
+			return db.Where("tasks.assignee_id = ?", key)
+		case key == boardNone:
+			return db.Where("tasks.id NOT IN (SELECT task_id FROM task_tags)")
+		default:
+			return db.Where("tasks.id IN (SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE tags.name = ?)", key)
+		}
+	}
+}
+
+// inBoardColumn reports whether the task belongs in a column
+func inBoardColumn(task models.Task, groupBy, key string) bool {
+	switch groupBy {
+	case models.BoardByStatus:
+		return task.Status == key
+	case models.BoardByPriority:
+		return task.Priority == key
+	case models.BoardByAssignee:
+		if task.AssigneeID == nil {
+			return key == boardNone
+		}
+		return strconv.FormatInt(*task.AssigneeID, 10) == key
+	default:
+		if len(task.Tags) == 0 {
+			return key == boardNone
+		}
+		return indexOf(task.Tags, key) >= 0
+	}
+}
+
+// boardLimits returns the WIP limits of a grouping by column key
+func boardLimits(tx *gorm.DB, projectID int64, groupBy string) (map[string]int, error) {
+	var rows []models.BoardLimit
+	if err := tx.Where("project_id = ? AND group_by = ?", projectID, groupBy).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	limits := make(map[string]int, len(rows))
+	for _, l := range rows {
+		limits[l.Column] = l.Limit
+	}
+	return limits, nil
+}
+
+// boardParams reads the project a board request is about, the user's
+// Inbox by default, and the grouping, writing the error response itself
+func boardParams(c *gin.Context, projectParam *int64, groupBy, minRole string) (models.Project, string, bool) {
+	uid, _ := c.Get("user_id")
+	groupBy = strings.ToLower(strings.TrimSpace(groupBy))
+	if groupBy == "" {
+		groupBy = models.BoardByStatus
+	}
+	if !models.IsValidBoardGrouping(groupBy) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "group_by must be " + strings.Join(models.ValidBoardGroupings, "|")})
+		return models.Project{}, "", false
+	}
+	if projectParam == nil {
+		inbox, err := ensureInbox(config.DB, uid.(int64))
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return inbox, "", false
+		}
+		projectParam = &inbox.ID
+	}
+	project, _, ok := requireProject(c, uid.(int64), *projectParam, minRole)
+	return project, groupBy, ok
+}
+
+// GetBoard returns a project's tasks as board columns, each with its count
+// and a page of tasks; page and page_size apply to every column, and column
+// narrows the board to one of them to load more. The usual list filters
+// narrow the tasks.
+func GetBoard(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	f, err := queryTaskFilter(c)
+	if err != nil {
+		filterErrorResponse(c, err)
+		return
+	}
+	project, groupBy, ok := boardParams(c, f.ProjectID, c.Query("group_by"), "")
+	if !ok {
+		return
+	}
+	columns, err := boardColumns(config.DB, project, groupBy)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if key := c.Query("column"); key != "" {
+		var only []boardColumn
+		for _, col := range columns {
+			if col.Key == key {
+				only = append(only, col)
+			}
+		}
+		if only == nil {
+			helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "column not found"})
+			return
+		}
+		columns = only
+	}
+	limits, err := boardLimits(config.DB, project.ID, groupBy)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+
+	page, pageSize := pageParams(c)
+	for i := range columns {
+		col := &columns[i]
+		column := config.DB.Model(&models.Task{}).Where("tasks.project_id = ?", project.ID).
+			Scopes(boardColumnScope(groupBy, col.Key)).Session(&gorm.Session{})
+		q, err := f.apply(column, uid.(int64))
+		if err != nil {
+			filterErrorResponse(c, err)
+			return
+		}
+		q = q.Session(&gorm.Session{})
+		if err := q.Count(&col.Count).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail count"})
+			return
+		}
+		if err := q.Order("tasks.position, tasks.id").Limit(pageSize).Offset((page - 1) * pageSize).Find(&col.Tasks).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if err := decorateTasks(config.DB, col.Tasks); err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if limit, ok := limits[col.Key]; ok {
+			var n int64
+			if err := column.Count(&n).Error; err != nil {
+				helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail count"})
+				return
+			}
+			col.WIPLimit, col.OverLimit = &limit, n > int64(limit)
+		}
+		if col.Tasks == nil {
+			col.Tasks = []models.Task{}
+		}
+		col.Pagination = gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": (col.Count + int64(pageSize) - 1) / int64(pageSize),
+		}
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"project_id": project.ID,
+		"group_by":   groupBy,
+		"columns":    columns,
+	})
+}
+
+// SetBoardLimits replaces the WIP limits of one grouping of a project's
+// board
+func SetBoardLimits(c *gin.Context) {
+	var in struct {
+		ProjectID *int64         `json:"project_id"`
+		GroupBy   string         `json:"group_by"`
+		Limits    map[string]int `json:"limits"` // column key → limit
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	project, groupBy, ok := boardParams(c, in.ProjectID, in.GroupBy, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	columns, err := boardColumns(config.DB, project, groupBy)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	var limits []models.BoardLimit
+	for key, limit := range in.Limits {
+		known := false
+		for _, col := range columns {
+			known = known || col.Key == key
+		}
+		if !known {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fmt.Sprintf("the board has no %q column", key)})
+			return
+		}
+		if limit < 1 || limit > MaxWIPLimit {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fmt.Sprintf("limits must be between 1 and %d", MaxWIPLimit)})
+			return
+		}
+		limits = append(limits, models.BoardLimit{ProjectID: project.ID, GroupBy: groupBy, Column: key, Limit: limit})
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Column < limits[j].Column })
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ? AND group_by = ?", project.ID, groupBy).Delete(&models.BoardLimit{}).Error; err != nil {
+			return err
+		}
+		if len(limits) == 0 {
+			return nil
+		}
+		return tx.Create(&limits).Error
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Limits updated", gin.H{"project_id": project.ID, "group_by": groupBy, "limits": in.Limits})
+}
+
+// MoveCard moves a task to a column of its project's board and, given
+// before or after, next to another card, as one change. A move into a
+// column at its WIP limit, or of a blocked task into a done status, takes
+// force=true. On tag boards the card leaves the from column.
+func MoveCard(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var in struct {
+		TaskID  int64  `json:"task_id" binding:"required"`
+		GroupBy string `json:"group_by"` // status by default
+		To      string `json:"to" binding:"required"`
+		From    string `json:"from"`
+		Before  *int64 `json:"before"`
+		After   *int64 `json:"after"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if in.Before != nil && in.After != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "give before or after, not both"})
+		return
+	}
+	task, err := findTask(config.DB, uid.(int64), in.TaskID, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	if task.ProjectID == nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "the task isn't in a project"})
+		return
+	}
+	if !checkIfMatch(c, task) {
+		return
+	}
+	project, groupBy, ok := boardParams(c, task.ProjectID, in.GroupBy, models.ProjectRoleEditor)
+	if !ok {
+		return
+	}
+	tasks := []models.Task{task}
+	err = loadTags(config.DB, tasks)
+	if err == nil {
+		err = loadCustomFields(config.DB, tasks)
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	task = tasks[0]
+	before := task
+
+	to := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(in.To), "#"))
+	switch groupBy {
+	case models.BoardByStatus:
+		workflow, err := taskWorkflow(config.DB, task)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if err := setTaskStatus(workflow, &task, to); err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+			return
+		}
+	case models.BoardByPriority:
+		if !models.IsValidPriority(to) {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "priority must be low|medium|high"})
+			return
+		}
+		task.Priority = to
+	case models.BoardByAssignee:
+		task.AssigneeID = nil
+		if to != boardNone {
+			assignee, err := strconv.ParseInt(to, 10, 64)
+			if err != nil {
+				helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "to must be _none or a user id"})
+				return
+			}
+			ok, err := canAccessTask(config.DB, assignee, task)
+			if err != nil {
+				helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+				return
+			}
+			if !ok {
+				helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "assignee has no access to this task"})
+				return
+			}
+			task.AssigneeID = &assignee
+		}
+	case models.BoardByTag:
+		from := strings.ToLower(strings.TrimSpace(in.From))
+		if from == "" && len(task.Tags) > 1 {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "from is required for a task with several tags"})
+			return
+		}
+		if from == "" {
+			from = boardNone
+			if len(task.Tags) == 1 {
+				from = task.Tags[0]
+			}
+		}
+		if !inBoardColumn(task, groupBy, from) {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "the task isn't in the from column"})
+			return
+		}
+		var names []string
+		for _, t := range task.Tags {
+			if t != from {
+				names = append(names, t)
+			}
+		}
+		if to != boardNone {
+			names = append(names, to)
+		}
+		tags, err := normalizeTags(names)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+			return
+		}
+		task.Tags = tags
+	}
+
+	force := c.Query("force") == "true"
+	limit, limited := 0, false
+	if !force && !inBoardColumn(before, groupBy, to) {
+		limits, err := boardLimits(config.DB, project.ID, groupBy)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		limit, limited = limits[to]
+	}
+	finished := finishesTask(before, task)
+	if !force && finished {
+		blockers, err := openBlockers(config.DB, task.ID)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if len(blockers) > 0 {
+			helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{
+				"details":  "the task is blocked by open tasks; use force=true to finish it anyway",
+				"blockers": blockers,
+			})
+			return
+		}
+	}
+	if finished {
+		task.Recurrence = "" // moves on to the next occurrence
+	}
+	var anchor *models.Task
+	if id := in.Before; id != nil || in.After != nil {
+		if id == nil {
+			id = in.After
+		}
+		a, err := findTask(config.DB, uid.(int64), *id, false)
+		if err != nil || a.ID == task.ID || a.ProjectID == nil || *a.ProjectID != project.ID {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "anchor must be another task in the same project"})
+			return
+		}
+		anchor = &a
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if limited {
+			// lock the project so concurrent moves count one after the other
+			if err := tx.Model(&models.Project{}).Where("id = ?", project.ID).
+				UpdateColumn("user_id", gorm.Expr("user_id")).Error; err != nil {
+				return err
+			}
+			var n int64
+			if err := tx.Model(&models.Task{}).Where("tasks.project_id = ? AND tasks.id <> ?", project.ID, task.ID).
+				Scopes(boardColumnScope(groupBy, to)).Count(&n).Error; err != nil {
+				return err
+			}
+			if n >= int64(limit) {
+				return errWIPLimit
+			}
+		}
+		if anchor != nil {
+			key, err := placeBeside(tx, task, *anchor, in.After != nil)
+			if err != nil {
+				return err
+			}
+			task.Position = key
+		}
+		if err := saveTask(tx, &task); err != nil {
+			return err
+		}
+		if strings.Join(before.Tags, ",") != strings.Join(task.Tags, ",") {
+			if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+				return err
+			}
+		}
+		if task.AssigneeID != nil && (before.AssigneeID == nil || *before.AssigneeID != *task.AssigneeID) {
+			actor := uid.(int64)
+			if err := notify(tx, models.Notification{
+				UserID:  *task.AssigneeID,
+				ActorID: &actor,
+				TaskID:  &task.ID,
+				Type:    models.NotificationTaskAssigned,
+				Message: fmt.Sprintf("You were assigned to %q", task.Title),
+			}); err != nil {
+				return err
+			}
+		}
+		if finished {
+			if err := notifyUnblocked(tx, c, task); err != nil {
+				return err
+			}
+			if err := repeatTask(tx, c, before.Recurrence, task); err != nil {
+				return err
+			}
+		}
+		return recordTaskActivity(tx, c, &before, &task)
+	})
+	if errors.Is(err, errWIPLimit) {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{
+			"details":   fmt.Sprintf("the %s column is at its WIP limit; use force=true to move the task anyway", to),
+			"wip_limit": limit,
+		})
+		return
+	}
+	if errors.Is(err, errStaleTask) {
+		staleTaskResponse(c, task)
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail move"})
+		return
+	}
+	tasks = []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	taskResponse(c, http.StatusOK, "Moved", tasks[0])
+}
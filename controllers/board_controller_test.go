@@ -0,0 +1,132 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func TestBoard(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.PUT("/projects/:id/statuses", controllers.SetProjectStatuses)
+	api.GET("/board", controllers.GetBoard)
+	api.PUT("/board/limits", controllers.SetBoardLimits)
+	api.POST("/board/move", controllers.MoveCard)
+
+	owner := seedUser(t, "lead")
+	dev := seedUser(t, "dev")
+	project := models.Project{UserID: owner.ID, Name: "Sprint"}
+	config.DB.Create(&project)
+	config.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: dev.ID, Role: models.ProjectRoleEditor})
+	expectStatus(t, doJSON(r, "PUT", fmt.Sprintf("/api/projects/%d/statuses", project.ID), gin.H{"statuses": []gin.H{
+		{"key": "todo", "name": "To do", "category": "todo"},
+		{"key": "doing", "name": "Doing", "category": "doing"},
+		{"key": "done", "name": "Done", "category": "done"},
+	}}, owner.ID), http.StatusOK)
+
+	ids := map[string]int64{}
+	for _, task := range []gin.H{
+		{"title": "a", "tags": []string{"api"}},
+		{"title": "b", "status": "doing", "priority": "high", "tags": []string{"api", "web"}},
+		{"title": "c", "status": "doing"},
+		{"title": "d"},
+		{"title": "e"},
+	} {
+		task["project_id"] = project.ID
+		w := doJSON(r, "POST", "/api/tasks", task, owner.ID)
+		expectStatus(t, w, http.StatusCreated)
+		ids[task["title"].(string)] = int64(responseData(t, w)["id"].(float64))
+	}
+
+	board := func(query string) []interface{} {
+		t.Helper()
+		w := doJSON(r, "GET", fmt.Sprintf("/api/board?project_id=%d&%s", project.ID, query), nil, dev.ID)
+		expectStatus(t, w, http.StatusOK)
+		return responseData(t, w)["columns"].([]interface{})
+	}
+	// summary renders columns as key=count:titles
+	summary := func(columns []interface{}) string {
+		var out []string
+		for _, col := range columns {
+			col := col.(map[string]interface{})
+			s := fmt.Sprintf("%v=%v:", col["key"], col["count"])
+			for _, task := range col["tasks"].([]interface{}) {
+				s += task.(map[string]interface{})["title"].(string)
+			}
+			out = append(out, s)
+		}
+		return fmt.Sprint(out)
+	}
+
+	for _, tc := range []struct{ query, want string }{
+		{"", "[todo=3:ade doing=2:bc done=0:]"},
+		{"page_size=1&page=2", "[todo=3:d doing=2:c done=0:]"},
+		{"group_by=priority", "[high=1:b medium=4:acde low=0:]"},
+		{"group_by=tag", "[_none=3:cde api=2:ab web=1:b]"},
+		{"group_by=assignee&column=_none", "[_none=5:abcde]"},
+		{"priority=medium", "[todo=3:ade doing=1:c done=0:]"},
+	} {
+		if got := summary(board(tc.query)); got != tc.want {
+			t.Errorf("board %q: %s want %s", tc.query, got, tc.want)
+		}
+	}
+	expectStatus(t, doJSON(r, "GET", "/api/board?group_by=due", nil, dev.ID), http.StatusBadRequest)
+
+	// WIP limits are the owner's to set and hold moves back unless forced
+	limits := gin.H{"project_id": project.ID, "group_by": "status", "limits": gin.H{"doing": 2}}
+	expectStatus(t, doJSON(r, "PUT", "/api/board/limits", limits, dev.ID), http.StatusForbidden)
+	expectStatus(t, doJSON(r, "PUT", "/api/board/limits", limits, owner.ID), http.StatusOK)
+	expectStatus(t, doJSON(r, "PUT", "/api/board/limits", gin.H{"project_id": project.ID, "limits": gin.H{"review": 1}}, owner.ID), http.StatusBadRequest)
+
+	move := func(body gin.H, query string) int {
+		return doJSON(r, "POST", "/api/board/move"+query, body, dev.ID).Code
+	}
+	if code := move(gin.H{"task_id": ids["a"], "to": "doing"}, ""); code != http.StatusConflict {
+		t.Fatalf("move over the WIP limit: %d", code)
+	}
+	if code := move(gin.H{"task_id": ids["a"], "to": "doing", "before": ids["c"]}, "?force=true"); code != http.StatusOK {
+		t.Fatalf("forced move: %d", code)
+	}
+	cols := board("")
+	if got := summary(cols); got != "[todo=2:de doing=3:bac done=0:]" {
+		t.Fatalf("after move: %s", got)
+	}
+	if doing := cols[1].(map[string]interface{}); doing["wip_limit"] != float64(2) || doing["over_limit"] != true {
+		t.Fatalf("doing column=%v", doing)
+	}
+	// reordering within a column isn't held back by its limit
+	if code := move(gin.H{"task_id": ids["c"], "to": "doing", "before": ids["b"]}, ""); code != http.StatusOK {
+		t.Fatalf("reorder: %d", code)
+	}
+
+	// other groupings change what the column stands for
+	if code := move(gin.H{"task_id": ids["d"], "group_by": "assignee", "to": fmt.Sprint(dev.ID)}, ""); code != http.StatusOK {
+		t.Fatalf("assign by move: %d", code)
+	}
+	if code := move(gin.H{"task_id": ids["b"], "group_by": "tag", "to": "ux"}, ""); code != http.StatusBadRequest {
+		t.Fatalf("tag move without from: %d", code)
+	}
+	if code := move(gin.H{"task_id": ids["b"], "group_by": "tag", "from": "web", "to": "ux"}, ""); code != http.StatusOK {
+		t.Fatalf("tag move: %d", code)
+	}
+	if got := summary(board("group_by=tag")); got != "[_none=3:cde api=2:ba ux=1:b]" {
+		t.Fatalf("tag board: %s", got)
+	}
+	if got := summary(board("group_by=assignee")); got != fmt.Sprintf("[_none=4:cbae %d=1:d %d=0:]", dev.ID, owner.ID) {
+		t.Fatalf("assignee board: %s", got)
+	}
+
+	// a tag named none gets a column of its own
+	if code := move(gin.H{"task_id": ids["e"], "group_by": "tag", "to": "none"}, ""); code != http.StatusOK {
+		t.Fatalf("move to tag none: %d", code)
+	}
+	if got := summary(board("group_by=tag")); got != "[_none=2:cd api=2:ba none=1:e ux=1:b]" {
+		t.Fatalf("tag board with none: %s", got)
+	}
+}
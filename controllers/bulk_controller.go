@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const MaxBulkTasks = 500
+
+// Bulk actions
+const (
+	BulkComplete    = "complete"
+	BulkReopen      = "reopen"
+	BulkSetPriority = "set_priority"
+	BulkMove        = "move"
+	BulkAddTags     = "add_tags"
+	BulkRemoveTags  = "remove_tags"
+	BulkDelete      = "delete"
+)
+
+// Per-task results of a bulk operation
+const (
+	bulkUpdated   = "updated"
+	bulkDeleted   = "deleted"
+	bulkUnchanged = "unchanged"
+	bulkSkipped   = "skipped"
+)
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+type bulkResult struct {
+	ID     int64  `json:"id"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkTasks applies one action to a list of tasks, given by id or by the
+// task list filters, in a single transaction. Tasks that can't be changed
+// are skipped and reported; with dry_run nothing is saved.
+func BulkTasks(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	userID := uid.(int64)
+	var in struct {
+		IDs       []int64     `json:"ids"`
+		Filter    *taskFilter `json:"filter"`
+		Action    string      `json:"action" binding:"required"`
+		Priority  string      `json:"priority"`
+		ProjectID *int64      `json:"project_id"`
+		Tags      []string    `json:"tags"`
+		DryRun    bool        `json:"dry_run"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if (len(in.IDs) == 0) == (in.Filter == nil) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "give either ids or filter"})
+		return
+	}
+	if len(in.IDs) > MaxBulkTasks {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{
+			"details": fmt.Sprintf("at most %d tasks per request", MaxBulkTasks),
+		})
+		return
+	}
+
+	// Validate the action's arguments once, up front
+	var project models.Project
+	var tags []string
+	switch in.Action {
+	case BulkComplete, BulkReopen, BulkDelete:
+	case BulkSetPriority:
+		in.Priority = strings.ToLower(strings.TrimSpace(in.Priority))
+		if !models.IsValidPriority(in.Priority) {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "priority must be low|medium|high"})
+			return
+		}
+	case BulkMove:
+		if in.ProjectID == nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "project_id is required"})
+			return
+		}
+		var err error
+		if project, err = resolveTaskProject(config.DB, userID, in.ProjectID); err != nil {
+			projectErrorResponse(c, err)
+			return
+		}
+	case BulkAddTags, BulkRemoveTags:
+		var err error
+		if tags, err = normalizeTags(in.Tags); err == nil && len(tags) == 0 {
+			err = errors.New("tags is required")
+		}
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+			return
+		}
+	default:
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{
+			"details": "action must be complete|reopen|set_priority|move|add_tags|remove_tags|delete",
+		})
+		return
+	}
+
+	// Resolve the targets
+	var tasks []models.Task
+	results := []bulkResult{}
+	q := config.DB.Scopes(visibleTasks(userID))
+	if in.Filter != nil {
+		var err error
+		if q, err = in.Filter.apply(q, userID); err != nil {
+			filterErrorResponse(c, err)
+			return
+		}
+		if err := q.Order("tasks.id").Limit(MaxBulkTasks + 1).Find(&tasks).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if len(tasks) > MaxBulkTasks {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{
+				"details": fmt.Sprintf("filter matches more than %d tasks", MaxBulkTasks),
+			})
+			return
+		}
+	} else {
+		var found []models.Task
+		if err := q.Where("tasks.id IN ?", in.IDs).Find(&found).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		byID := make(map[int64]models.Task, len(found))
+		for _, t := range found {
+			byID[t.ID] = t
+		}
+		seen := map[int64]bool{}
+		for _, id := range in.IDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if t, ok := byID[id]; ok {
+				tasks = append(tasks, t)
+			} else {
+				results = append(results, bulkResult{ID: id, Result: bulkSkipped, Error: "task not found"})
+			}
+		}
+	}
+	if err := loadTags(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range tasks {
+			task := &tasks[i]
+			result := bulkResult{ID: task.ID}
+			ok, err := canEditTask(tx, userID, *task)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				result.Result, result.Error = bulkSkipped, "read-only access to task"
+				results = append(results, result)
+				continue
+			}
+			if in.Action == BulkDelete {
+				if err := tx.Delete(task).Error; err != nil {
+					return err
+				}
+				if task.StatusCategory != models.StatusCategoryDone {
+					if err := notifyUnblocked(tx, c, *task); err != nil {
+						return err
+					}
+				}
+				if err := recordTaskActivity(tx, c, task, nil); err != nil {
+					return err
+				}
+				result.Result = bulkDeleted
+				results = append(results, result)
+				continue
+			}
+
+			before := *task
+			switch in.Action {
+			case BulkComplete, BulkReopen:
+				// the first done (or todo) status of the task's workflow;
+				// tasks already there are left alone
+				done := task.StatusCategory == models.StatusCategoryDone
+				if done == (in.Action == BulkComplete) {
+					break
+				}
+				workflow, err := taskWorkflow(tx, *task)
+				if err != nil {
+					return err
+				}
+				category := models.StatusCategoryDone
+				if in.Action == BulkReopen {
+					category = models.StatusCategoryTodo
+				}
+				if err := setTaskStatus(workflow, task, firstInCategory(workflow, category).Key); err != nil {
+					result.Result, result.Error = bulkSkipped, err.Error()
+					results = append(results, result)
+					continue
+				}
+				if in.Action == BulkComplete {
+					blockers, err := openBlockers(tx, task.ID)
+					if err != nil {
+						return err
+					}
+					if len(blockers) > 0 {
+						*task = before
+						result.Result, result.Error = bulkSkipped, "blocked by open tasks"
+						results = append(results, result)
+						continue
+					}
+				}
+			case BulkSetPriority:
+				task.Priority = in.Priority
+			case BulkMove:
+				if err := moveTask(tx, task, project); err != nil {
+					return err
+				}
+			case BulkAddTags, BulkRemoveTags:
+				task.Tags = mergeTags(task.Tags, tags, in.Action == BulkRemoveTags)
+				if len(task.Tags) > MaxTagsPerTask {
+					task.Tags = before.Tags
+					result.Result = bulkSkipped
+					result.Error = fmt.Sprintf("a task can have at most %d tags", MaxTagsPerTask)
+					results = append(results, result)
+					continue
+				}
+			}
+			finished := finishesTask(before, *task)
+			if finished {
+				task.Recurrence = "" // moves on to the next occurrence
+			}
+			if reflect.DeepEqual(taskSnapshot(&before), taskSnapshot(task)) {
+				result.Result = bulkUnchanged
+				results = append(results, result)
+				continue
+			}
+			if err := saveTask(tx, task); err != nil {
+				if !errors.Is(err, errStaleTask) {
+					return err
+				}
+				// changed by a concurrent request since it was loaded
+				result.Result, result.Error = bulkSkipped, err.Error()
+				results = append(results, result)
+				continue
+			}
+			if in.Action == BulkAddTags || in.Action == BulkRemoveTags {
+				if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+					return err
+				}
+			}
+			if in.Action == BulkMove {
+				if err := remapFieldValues(tx, *task); err != nil {
+					return err
+				}
+			}
+			if finished {
+				if err := notifyUnblocked(tx, c, *task); err != nil {
+					return err
+				}
+				if err := repeatTask(tx, c, before.Recurrence, *task); err != nil {
+					return err
+				}
+			}
+			if err := recordTaskActivity(tx, c, &before, task); err != nil {
+				return err
+			}
+			result.Result = bulkUpdated
+			results = append(results, result)
+		}
+		if in.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail bulk update"})
+		return
+	}
+
+	summary := map[string]int{bulkUpdated: 0, bulkDeleted: 0, bulkUnchanged: 0, bulkSkipped: 0}
+	for _, r := range results {
+		summary[r.Result]++
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"action":  in.Action,
+		"dry_run": in.DryRun,
+		"total":   len(results),
+		"summary": summary,
+		"results": results,
+	})
+}
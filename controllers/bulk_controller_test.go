@@ -0,0 +1,74 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/controllers"
+)
+
+func TestBulkTasks(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.POST("/tasks/bulk", controllers.BulkTasks)
+
+	u := seedUser(t, "bulker")
+	other := seedUser(t, "outsider")
+	var ids []int64
+	for i, status := range []string{"pending", "pending", "completed"} {
+		w := doJSON(r, "POST", "/api/tasks", gin.H{"title": fmt.Sprintf("t%d", i), "tags": []string{"Home"}}, u.ID)
+		expectStatus(t, w, http.StatusCreated)
+		ids = append(ids, int64(responseData(t, w)["id"].(float64)))
+		if status == "completed" {
+			doJSON(r, "POST", "/api/tasks/bulk", gin.H{"ids": []int64{ids[i]}, "action": "complete"}, u.ID)
+		}
+	}
+	w := doJSON(r, "POST", "/api/tasks", gin.H{"title": "not mine"}, other.ID)
+	foreign := int64(responseData(t, w)["id"].(float64))
+
+	// a dry run reports per task but changes nothing
+	w = doJSON(r, "POST", "/api/tasks/bulk", gin.H{
+		"ids": []int64{ids[0], ids[2], foreign}, "action": "complete", "dry_run": true,
+	}, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	summary := responseData(t, w)["summary"].(map[string]interface{})
+	if summary["updated"] != float64(1) || summary["unchanged"] != float64(1) || summary["skipped"] != float64(1) {
+		t.Fatalf("summary=%v", summary)
+	}
+	w = doJSON(r, "GET", "/api/tasks?status=completed", nil, u.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 1 {
+		t.Fatalf("dry run changed tasks: %d completed", n)
+	}
+
+	// filters select the same tasks as the task list
+	w = doJSON(r, "POST", "/api/tasks/bulk", gin.H{
+		"filter": gin.H{"status": "pending", "tag": "home"}, "action": "add_tags", "tags": []string{"urgent"},
+	}, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	if n := responseData(t, w)["summary"].(map[string]interface{})["updated"]; n != float64(2) {
+		t.Fatalf("updated=%v want 2", n)
+	}
+	w = doJSON(r, "GET", "/api/tasks?tag=urgent", nil, u.ID)
+	tasks := responseData(t, w)["tasks"].([]interface{})
+	if len(tasks) != 2 {
+		t.Fatalf("tagged tasks=%d want 2", len(tasks))
+	}
+	if tags := tasks[0].(map[string]interface{})["tags"]; fmt.Sprint(tags) != "[home urgent]" {
+		t.Fatalf("tags=%v", tags)
+	}
+
+	w = doJSON(r, "POST", "/api/tasks/bulk", gin.H{"filter": gin.H{"status": "completed"}, "action": "delete"}, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	w = doJSON(r, "GET", "/api/tasks", nil, u.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 2 {
+		t.Fatalf("tasks after bulk delete=%d want 2", n)
+	}
+
+	w = doJSON(r, "POST", "/api/tasks/bulk", gin.H{"ids": ids, "action": "set_priority", "priority": "urgent"}, u.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+	w = doJSON(r, "POST", "/api/tasks/bulk", gin.H{"action": "complete"}, u.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,392 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxChecklistItems  = 100
+	MaxChecklistLength = 255
+)
+
+var errChecklistFull = fmt.Errorf("a checklist can have at most %d items", MaxChecklistItems)
+
+// checklistText trims an item's text and checks its length
+func checklistText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > MaxChecklistLength {
+		return "", fmt.Errorf("item text must be 1-%d characters", MaxChecklistLength)
+	}
+	return text, nil
+}
+
+// addChecklistItems appends unchecked items to a task's checklist
+func addChecklistItems(tx *gorm.DB, taskID int64, texts []string) ([]models.ChecklistItem, error) {
+	var last struct{ N, Max int }
+	if err := tx.Model(&models.ChecklistItem{}).Select("COUNT(*) AS n, COALESCE(MAX(position), 0) AS max").
+		Where("task_id = ?", taskID).Scan(&last).Error; err != nil {
+		return nil, err
+	}
+	if last.N+len(texts) > MaxChecklistItems {
+		return nil, errChecklistFull
+	}
+	items := make([]models.ChecklistItem, len(texts))
+	for i, text := range texts {
+		items[i] = models.ChecklistItem{TaskID: taskID, Text: text, Position: last.Max + i + 1}
+	}
+	if len(items) == 0 {
+		return items, nil
+	}
+	return items, tx.Create(&items).Error
+}
+
+// loadChecklist fills in the checklist progress of tasks
+func loadChecklist(tx *gorm.DB, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	var rows []struct {
+		TaskID int64
+		Done   int64
+		Total  int64
+	}
+	if err := tx.Model(&models.ChecklistItem{}).
+		Select("task_id, SUM(CASE WHEN done THEN 1 ELSE 0 END) AS done, COUNT(*) AS total").
+		Where("task_id IN ?", ids).Group("task_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+	progress := make(map[int64]models.ChecklistProgress, len(rows))
+	for _, r := range rows {
+		progress[r.TaskID] = models.ChecklistProgress{Done: r.Done, Total: r.Total}
+	}
+	for i := range tasks {
+		tasks[i].Checklist = progress[tasks[i].ID]
+	}
+	return nil
+}
+
+// checklistChanged records a change to the task's checklist: the task
+// moves to a done status if it auto-completes and every item is now done,
+// otherwise only its version is bumped. It reports whether the task was
+// finished. Tasks with open blockers, or whose workflow doesn't allow the
+// move, stay as they are.
+func checklistChanged(tx *gorm.DB, c *gin.Context, task *models.Task) (bool, error) {
+	tasks := []models.Task{*task}
+	if err := loadChecklist(tx, tasks); err != nil {
+		return false, err
+	}
+	progress := tasks[0].Checklist
+	if task.AutoComplete && progress.Total > 0 && progress.Done == progress.Total &&
+		task.StatusCategory != models.StatusCategoryDone {
+		blockers, err := openBlockers(tx, task.ID)
+		if err != nil {
+			return false, err
+		}
+		workflow, err := taskWorkflow(tx, *task)
+		if err != nil {
+			return false, err
+		}
+		before := *task
+		if len(blockers) == 0 && setTaskStatus(workflow, task, firstInCategory(workflow, models.StatusCategoryDone).Key) == nil {
+			rule := task.Recurrence
+			task.Recurrence = "" // moves on to the next occurrence
+			if err := saveTask(tx, task); err != nil {
+				return false, err
+			}
+			if err := notifyUnblocked(tx, c, *task); err != nil {
+				return false, err
+			}
+			if err := repeatTask(tx, c, rule, *task); err != nil {
+				return false, err
+			}
+			return true, recordTaskActivity(tx, c, &before, task)
+		}
+		*task = before
+	}
+	return false, bumpVersion(tx, task)
+}
+
+// checklistResponse writes an item change's response: the item, the
+// checklist's progress and whether the change finished the task
+func checklistResponse(c *gin.Context, code int, msg string, task models.Task, item interface{}, finished bool) {
+	tasks := []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	c.Header("ETag", taskETag(tasks[0]))
+	helpers.APIResponse(c, code, msg, gin.H{
+		"item":           item,
+		"checklist":      tasks[0].Checklist,
+		"task_completed": finished,
+		"status":         task.Status,
+	})
+}
+
+// GetChecklist lists a task's checklist items in order
+func GetChecklist(c *gin.Context) {
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	items := []models.ChecklistItem{}
+	if err := config.DB.Where("task_id = ?", task.ID).Order("position, id").Find(&items).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	progress := models.ChecklistProgress{Total: int64(len(items))}
+	for _, item := range items {
+		if item.Done {
+			progress.Done++
+		}
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"items": items, "checklist": progress})
+}
+
+// AddChecklistItem appends an item to a task's checklist
+func AddChecklistItem(c *gin.Context) {
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		Text string `json:"text" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	text, err := checklistText(in.Text)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	var items []models.ChecklistItem
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if items, err = addChecklistItems(tx, task.ID, []string{text}); err != nil {
+			return err
+		}
+		_, err = checklistChanged(tx, c, &task)
+		return err
+	})
+	if errors.Is(err, errChecklistFull) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if errors.Is(err, errStaleTask) {
+		staleTaskResponse(c, task)
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create item"})
+		return
+	}
+	checklistResponse(c, http.StatusCreated, "Item added", task, items[0], false)
+}
+
+// findChecklistItem loads the item named in the URL from the task's
+// checklist, writing the error response itself
+func findChecklistItem(c *gin.Context, task models.Task) (models.ChecklistItem, bool) {
+	var item models.ChecklistItem
+	id, err := strconv.ParseInt(c.Param("item_id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid item id"})
+		return item, false
+	}
+	if err := config.DB.Where("id = ? AND task_id = ?", id, task.ID).First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "item not found"})
+		} else {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		}
+		return item, false
+	}
+	return item, true
+}
+
+// updateChecklistItem saves an item's new text and done state
+func updateChecklistItem(c *gin.Context, task models.Task, item models.ChecklistItem) {
+	if item.Done && item.DoneAt == nil {
+		now := time.Now()
+		item.DoneAt = &now
+	} else if !item.Done {
+		item.DoneAt = nil
+	}
+	var finished bool
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("text", "done", "done_at", "updated_at").Save(&item).Error; err != nil {
+			return err
+		}
+		var err error
+		finished, err = checklistChanged(tx, c, &task)
+		return err
+	})
+	if errors.Is(err, errStaleTask) {
+		staleTaskResponse(c, task)
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update item"})
+		return
+	}
+	checklistResponse(c, http.StatusOK, "Item updated", task, item, finished)
+}
+
+// UpdateChecklistItem changes an item's text and/or checks or unchecks it.
+// Checking the last open item finishes a task with checklist_auto_complete.
+func UpdateChecklistItem(c *gin.Context) {
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	item, ok := findChecklistItem(c, task)
+	if !ok {
+		return
+	}
+	var in struct {
+		Text *string `json:"text"`
+		Done *bool   `json:"done"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if in.Text != nil {
+		text, err := checklistText(*in.Text)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+			return
+		}
+		item.Text = text
+	}
+	if in.Done != nil {
+		if *in.Done != item.Done {
+			item.DoneAt = nil
+		}
+		item.Done = *in.Done
+	}
+	updateChecklistItem(c, task, item)
+}
+
+// ToggleChecklistItem checks an open item or unchecks a done one
+func ToggleChecklistItem(c *gin.Context) {
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	item, ok := findChecklistItem(c, task)
+	if !ok {
+		return
+	}
+	item.Done, item.DoneAt = !item.Done, nil
+	updateChecklistItem(c, task, item)
+}
+
+// DeleteChecklistItem removes an item from a task's checklist
+func DeleteChecklistItem(c *gin.Context) {
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	item, ok := findChecklistItem(c, task)
+	if !ok {
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&item).Error; err != nil {
+			return err
+		}
+		return bumpVersion(tx, &task)
+	})
+	if errors.Is(err, errStaleTask) {
+		staleTaskResponse(c, task)
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete item"})
+		return
+	}
+	checklistResponse(c, http.StatusOK, "Item deleted", task, gin.H{"id": item.ID}, false)
+}
+
+// ReorderChecklist puts a task's checklist items in the order given; ids
+// must list every item once
+func ReorderChecklist(c *gin.Context) {
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		IDs []int64 `json:"ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	var items []models.ChecklistItem
+	if err := config.DB.Where("task_id = ?", task.ID).Find(&items).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	byID := make(map[int64]*models.ChecklistItem, len(items))
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+	seen := map[int64]bool{}
+	for _, id := range in.IDs {
+		if byID[id] == nil || seen[id] {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fmt.Sprintf("%d is not an item of the checklist, or is listed twice", id)})
+			return
+		}
+		seen[id] = true
+	}
+	if len(in.IDs) != len(items) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "ids must list every item of the checklist"})
+		return
+	}
+	ordered := make([]models.ChecklistItem, len(in.IDs))
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for i, id := range in.IDs {
+			item := byID[id]
+			if item.Position != i+1 {
+				item.Position = i + 1
+				if err := tx.Model(item).Update("position", item.Position).Error; err != nil {
+					return err
+				}
+			}
+			ordered[i] = *item
+		}
+		return bumpVersion(tx, &task)
+	})
+	if errors.Is(err, errStaleTask) {
+		staleTaskResponse(c, task)
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail reorder"})
+		return
+	}
+	tasks := []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	c.Header("ETag", taskETag(tasks[0]))
+	helpers.APIResponse(c, http.StatusOK, "Checklist reordered", gin.H{"items": ordered})
+}
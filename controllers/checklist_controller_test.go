@@ -0,0 +1,124 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func TestChecklist(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks/:id", controllers.GetTask)
+	api.GET("/tasks/:id/checklist", controllers.GetChecklist)
+	api.POST("/tasks/:id/checklist", controllers.AddChecklistItem)
+	api.PUT("/tasks/:id/checklist/order", controllers.ReorderChecklist)
+	api.PATCH("/tasks/:id/checklist/:item_id", controllers.UpdateChecklistItem)
+	api.POST("/tasks/:id/checklist/:item_id/toggle", controllers.ToggleChecklistItem)
+	api.DELETE("/tasks/:id/checklist/:item_id", controllers.DeleteChecklistItem)
+
+	owner := seedUser(t, "traveler")
+	viewer := seedUser(t, "friend")
+	project := models.Project{UserID: owner.ID, Name: "Trip"}
+	config.DB.Create(&project)
+	config.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: viewer.ID, Role: models.ProjectRoleViewer})
+
+	w := doJSON(r, "POST", "/api/tasks", gin.H{
+		"title": "Pack", "project_id": project.ID, "checklist_auto_complete": true,
+		"checklist_items": []string{"passport", " charger ", "adapter"},
+	}, owner.ID)
+	expectStatus(t, w, http.StatusCreated)
+	task := responseData(t, w)
+	path := fmt.Sprintf("/api/tasks/%v", task["id"])
+	if fmt.Sprint(task["checklist"]) != "map[done:0 total:3]" {
+		t.Fatalf("checklist=%v", task["checklist"])
+	}
+	expectStatus(t, doJSON(r, "POST", "/api/tasks", gin.H{"title": "x", "checklist_items": []string{""}}, owner.ID), http.StatusBadRequest)
+
+	items := func() ([]map[string]interface{}, string) {
+		t.Helper()
+		w := doJSON(r, "GET", path+"/checklist", nil, viewer.ID)
+		expectStatus(t, w, http.StatusOK)
+		var out []map[string]interface{}
+		order := ""
+		for _, item := range responseData(t, w)["items"].([]interface{}) {
+			item := item.(map[string]interface{})
+			out = append(out, item)
+			order += fmt.Sprint(item["text"], map[bool]string{true: "+", false: "-"}[item["done"].(bool)], " ")
+		}
+		return out, order
+	}
+	expectStatus(t, doJSON(r, "POST", path+"/checklist", gin.H{"text": "tickets"}, viewer.ID), http.StatusForbidden)
+	// changes answer with the ETag GET gives
+	sameETag := func(w *httptest.ResponseRecorder) {
+		t.Helper()
+		get := doJSON(r, "GET", path, nil, owner.ID)
+		if etag := w.Header().Get("ETag"); etag == "" || etag != get.Header().Get("ETag") {
+			t.Fatalf("ETag %q, GET gives %q", etag, get.Header().Get("ETag"))
+		}
+	}
+	w = doJSON(r, "POST", path+"/checklist", gin.H{"text": "tickets"}, owner.ID)
+	expectStatus(t, w, http.StatusCreated)
+	if !strings.HasPrefix(w.Header().Get("ETag"), fmt.Sprintf(`"%v-2-`, task["id"])) {
+		t.Fatalf("ETag=%s", w.Header().Get("ETag"))
+	}
+	sameETag(w)
+	list, order := items()
+	if order != "passport- charger- adapter- tickets- " {
+		t.Fatalf("order=%q", order)
+	}
+
+	// reordering must list every item once
+	ids := []interface{}{list[3]["id"], list[2]["id"], list[1]["id"], list[0]["id"]}
+	expectStatus(t, doJSON(r, "PUT", path+"/checklist/order", gin.H{"ids": ids[:3]}, owner.ID), http.StatusBadRequest)
+	expectStatus(t, doJSON(r, "PUT", path+"/checklist/order", gin.H{"ids": append(ids[:3:3], ids[0])}, owner.ID), http.StatusBadRequest)
+	w = doJSON(r, "PUT", path+"/checklist/order", gin.H{"ids": ids}, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+	sameETag(w)
+	if _, order := items(); order != "tickets- adapter- charger- passport- " {
+		t.Fatalf("reordered=%q", order)
+	}
+
+	item := func(i int) string { return fmt.Sprintf("%s/checklist/%v", path, list[i]["id"]) }
+	expectStatus(t, doJSON(r, "PATCH", item(1), gin.H{"text": " "}, owner.ID), http.StatusBadRequest)
+	expectStatus(t, doJSON(r, "PATCH", item(1), gin.H{"text": "USB charger", "done": true}, owner.ID), http.StatusOK)
+	expectStatus(t, doJSON(r, "POST", item(0)+"/toggle", nil, owner.ID), http.StatusOK)
+	expectStatus(t, doJSON(r, "DELETE", item(2), nil, owner.ID), http.StatusOK)
+	expectStatus(t, doJSON(r, "DELETE", item(2), nil, owner.ID), http.StatusNotFound)
+	if _, order := items(); order != "tickets- USB charger+ passport+ " {
+		t.Fatalf("items=%q", order)
+	}
+
+	// checking the last item finishes the task
+	w = doJSON(r, "POST", item(3)+"/toggle", nil, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+	if data := responseData(t, w); data["task_completed"] != true || data["status"] != "completed" {
+		t.Fatalf("toggle=%v", data)
+	}
+	w = doJSON(r, "GET", path, nil, owner.ID)
+	if got := responseData(t, w); got["status"] != "completed" || fmt.Sprint(got["checklist"]) != "map[done:3 total:3]" {
+		t.Fatalf("task=%v", got)
+	}
+	// unchecking leaves it finished
+	w = doJSON(r, "POST", item(3)+"/toggle", nil, owner.ID)
+	if data := responseData(t, w); data["task_completed"] != false || data["status"] != "completed" {
+		t.Fatalf("untoggle=%v", data)
+	}
+
+	// without the option the task stays open
+	w = doJSON(r, "POST", "/api/tasks", gin.H{"title": "Shop", "project_id": project.ID, "checklist_items": []string{"milk"}}, owner.ID)
+	other := fmt.Sprintf("/api/tasks/%v", responseData(t, w)["id"])
+	w = doJSON(r, "GET", other+"/checklist", nil, owner.ID)
+	milk := responseData(t, w)["items"].([]interface{})[0].(map[string]interface{})
+	w = doJSON(r, "POST", fmt.Sprintf("%s/checklist/%v/toggle", other, milk["id"]), nil, owner.ID)
+	if data := responseData(t, w); data["task_completed"] != false || data["status"] != "pending" {
+		t.Fatalf("toggle=%v", data)
+	}
+}
@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const MaxCommentLength = 10000
+
+// loadVisibleTask parses :id and loads a task the user can see
+func loadVisibleTask(c *gin.Context) (models.Task, bool) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return models.Task{}, false
+	}
+	task, err := findTask(config.DB, uid.(int64), id, false)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return task, false
+	}
+	return task, true
+}
+
+// loadComment loads the :comment_id comment of the task
+func loadComment(c *gin.Context, task models.Task) (models.Comment, bool) {
+	var comment models.Comment
+	id, err := strconv.ParseInt(c.Param("comment_id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid comment id"})
+		return comment, false
+	}
+	if err := config.DB.Where("id = ? AND task_id = ?", id, task.ID).First(&comment).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "comment not found"})
+		return comment, false
+	}
+	return comment, true
+}
+
+func validateCommentBody(c *gin.Context, body string) (string, bool) {
+	body = strings.TrimSpace(body)
+	if body == "" || len(body) > MaxCommentLength {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{
+			"details": fmt.Sprintf("body must be 1-%d characters", MaxCommentLength),
+		})
+		return body, false
+	}
+	return body, true
+}
+
+// notifyMentions notifies users @mentioned in body who can see the task.
+// Names in skip were already notified (e.g. before an edit).
+func notifyMentions(tx *gorm.DB, task models.Task, authorID int64, body string, skip []string) error {
+	already := map[string]bool{}
+	for _, name := range skip {
+		already[name] = true
+	}
+	var names []string
+	for _, name := range helpers.ParseMentions(body) {
+		if !already[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	var users []models.User
+	if err := tx.Where("username IN ?", names).Find(&users).Error; err != nil {
+		return err
+	}
+	for _, u := range users {
+		ok, err := canAccessTask(tx, u.ID, task)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		err = notify(tx, models.Notification{
+			UserID:  u.ID,
+			ActorID: &authorID,
+			TaskID:  &task.ID,
+			Type:    models.NotificationMentioned,
+			Message: fmt.Sprintf("You were mentioned in a comment on %q", task.Title),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetComments(c *gin.Context) {
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := pageParams(c)
+
+	var total int64
+	if err := config.DB.Model(&models.Comment{}).Where("task_id = ?", task.ID).Count(&total).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail count"})
+		return
+	}
+	var comments []models.Comment
+	if err := config.DB.Preload("Author").Where("task_id = ?", task.ID).
+		Order("id asc").Limit(pageSize).Offset((page - 1) * pageSize).
+		Find(&comments).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"comments": comments,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// CreateComment adds a comment. Anyone who can see the task may comment,
+// including viewers.
+func CreateComment(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	body, ok := validateCommentBody(c, in.Body)
+	if !ok {
+		return
+	}
+
+	comment := models.Comment{TaskID: task.ID, UserID: uid.(int64), Body: body}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return notifyMentions(tx, task, uid.(int64), body, nil)
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create comment"})
+		return
+	}
+	if err := config.DB.Preload("Author").First(&comment, comment.ID).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusCreated, "Comment created", comment)
+}
+
+// UpdateComment edits a comment (author only). The previous body is kept as
+// an edit history entry and only newly mentioned users are notified.
+func UpdateComment(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	comment, ok := loadComment(c, task)
+	if !ok {
+		return
+	}
+	if comment.UserID != uid.(int64) {
+		helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "only the author can edit a comment"})
+		return
+	}
+	var in struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	body, ok := validateCommentBody(c, in.Body)
+	if !ok {
+		return
+	}
+	if body == comment.Body {
+		helpers.APIResponse(c, http.StatusOK, "Updated", comment)
+		return
+	}
+
+	previous := comment.Body
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		edit := models.CommentEdit{CommentID: comment.ID, Body: previous, EditedAt: now}
+		if err := tx.Create(&edit).Error; err != nil {
+			return err
+		}
+		comment.Body = body
+		comment.EditedAt = &now
+		if err := tx.Save(&comment).Error; err != nil {
+			return err
+		}
+		return notifyMentions(tx, task, uid.(int64), body, helpers.ParseMentions(previous))
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	if err := config.DB.Preload("Author").First(&comment, comment.ID).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Updated", comment)
+}
+
+// DeleteComment removes a comment; allowed for its author and for users
+// who can edit the task
+func DeleteComment(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	comment, ok := loadComment(c, task)
+	if !ok {
+		return
+	}
+	if comment.UserID != uid.(int64) {
+		canEdit, err := canEditTask(config.DB, uid.(int64), task)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if !canEdit {
+			helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "cannot delete this comment"})
+			return
+		}
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&models.CommentEdit{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&comment).Error
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Deleted", gin.H{"id": comment.ID})
+}
+
+// GetCommentEdits returns a comment's edit history, oldest first
+func GetCommentEdits(c *gin.Context) {
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	comment, ok := loadComment(c, task)
+	if !ok {
+		return
+	}
+	var edits []models.CommentEdit
+	if err := config.DB.Where("comment_id = ?", comment.ID).Order("id asc").Find(&edits).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"edits": edits})
+}
+
+// countComments fills CommentCount for a page of tasks with one query
+func countComments(tx *gorm.DB, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	var rows []struct {
+		TaskID int64
+		Count  int64
+	}
+	if err := tx.Model(&models.Comment{}).Select("task_id, COUNT(*) AS count").
+		Where("task_id IN ?", ids).Group("task_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+	counts := make(map[int64]int64, len(rows))
+	for _, r := range rows {
+		counts[r.TaskID] = r.Count
+	}
+	for i := range tasks {
+		tasks[i].CommentCount = counts[tasks[i].ID]
+	}
+	return nil
+}
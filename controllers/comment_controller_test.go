@@ -0,0 +1,94 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func TestCommentsAndMentions(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.GET("/tasks/:id/comments", controllers.GetComments)
+	api.POST("/tasks/:id/comments", controllers.CreateComment)
+	api.PUT("/tasks/:id/comments/:comment_id", controllers.UpdateComment)
+	api.DELETE("/tasks/:id/comments/:comment_id", controllers.DeleteComment)
+	api.GET("/tasks/:id/comments/:comment_id/edits", controllers.GetCommentEdits)
+
+	author := seedUser(t, "author")
+	member := seedUser(t, "member")
+	outsider := seedUser(t, "outsider")
+
+	project := models.Project{UserID: author.ID, Name: "Discuss"}
+	config.DB.Create(&project)
+	config.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: member.ID, Role: models.ProjectRoleViewer})
+
+	w := doJSON(r, "POST", "/api/tasks", gin.H{"title": "talk", "project_id": project.ID}, author.ID)
+	expectStatus(t, w, http.StatusCreated)
+	taskID := int64(responseData(t, w)["id"].(float64))
+	base := fmt.Sprintf("/api/tasks/%d/comments", taskID)
+
+	mentions := func(userID int64) int64 {
+		var n int64
+		config.DB.Model(&models.Notification{}).
+			Where("user_id = ? AND type = ?", userID, models.NotificationMentioned).Count(&n)
+		return n
+	}
+
+	// only mentioned users with access are notified
+	body := fmt.Sprintf("ping @%s and @%s", member.Username, outsider.Username)
+	w = doJSON(r, "POST", base, gin.H{"body": body}, author.ID)
+	expectStatus(t, w, http.StatusCreated)
+	commentID := int64(responseData(t, w)["id"].(float64))
+	if got := fmt.Sprint(responseData(t, w)["author"]); got != fmt.Sprintf("map[id:%d username:%s]", author.ID, author.Username) {
+		t.Fatalf("author=%s", got)
+	}
+	if mentions(member.ID) != 1 || mentions(outsider.ID) != 0 {
+		t.Fatalf("mention notifications member=%d outsider=%d", mentions(member.ID), mentions(outsider.ID))
+	}
+
+	// viewers may comment but not edit someone else's comment
+	w = doJSON(r, "POST", base, gin.H{"body": "seen"}, member.ID)
+	expectStatus(t, w, http.StatusCreated)
+	w = doJSON(r, "PUT", fmt.Sprintf("%s/%d", base, commentID), gin.H{"body": "hijack"}, member.ID)
+	expectStatus(t, w, http.StatusForbidden)
+
+	// editing keeps history and doesn't notify the same mention twice
+	w = doJSON(r, "PUT", fmt.Sprintf("%s/%d", base, commentID), gin.H{"body": body + " (edited)"}, author.ID)
+	expectStatus(t, w, http.StatusOK)
+	if responseData(t, w)["edited_at"] == nil {
+		t.Fatal("edited_at not set")
+	}
+	if mentions(member.ID) != 1 {
+		t.Fatalf("member notified %d times", mentions(member.ID))
+	}
+	w = doJSON(r, "GET", fmt.Sprintf("%s/%d/edits", base, commentID), nil, member.ID)
+	edits := responseData(t, w)["edits"].([]interface{})
+	if len(edits) != 1 || edits[0].(map[string]interface{})["body"] != body {
+		t.Fatalf("edits=%v", edits)
+	}
+
+	// comment counts are part of the task list
+	w = doJSON(r, "GET", "/api/tasks", nil, member.ID)
+	tasks := responseData(t, w)["tasks"].([]interface{})
+	if n := tasks[0].(map[string]interface{})["comment_count"]; n != float64(2) {
+		t.Fatalf("comment_count=%v want 2", n)
+	}
+
+	// outsiders can't read the thread
+	w = doJSON(r, "GET", base, nil, outsider.ID)
+	expectStatus(t, w, http.StatusNotFound)
+
+	w = doJSON(r, "DELETE", fmt.Sprintf("%s/%d", base, commentID), nil, author.ID)
+	expectStatus(t, w, http.StatusOK)
+	w = doJSON(r, "GET", base, nil, author.ID)
+	if n := len(responseData(t, w)["comments"].([]interface{})); n != 1 {
+		t.Fatalf("comments=%d want 1", n)
+	}
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const MaxBlockersPerTask = 50
+
+// openBlockerSQL matches the blockers of tasks.id that still hold it up:
+// not done and not in the trash
+const openBlockerSQL = "SELECT 1 FROM task_dependencies JOIN tasks AS blocker ON blocker.id = task_dependencies.blocker_id" +
+	" WHERE task_dependencies.task_id = tasks.id AND blocker.deleted_at IS NULL AND blocker.status_category <> 'done'"
+
+// blockedCond matches tasks with open blockers, or without when blocked is
+// false
+func blockedCond(blocked bool) string {
+	if blocked {
+		return "EXISTS (" + openBlockerSQL + ")"
+	}
+	return "NOT EXISTS (" + openBlockerSQL + ")"
+}
+
+// openBlockers returns the ids of the task's blockers that aren't done
+func openBlockers(tx *gorm.DB, taskID int64) ([]int64, error) {
+	var ids []int64
+	err := tx.Model(&models.TaskDependency{}).
+		Joins("JOIN tasks AS blocker ON blocker.id = task_dependencies.blocker_id").
+		Where("task_dependencies.task_id = ? AND blocker.deleted_at IS NULL AND blocker.status_category <> ?", taskID, models.StatusCategoryDone).
+		Order("task_dependencies.blocker_id").Pluck("task_dependencies.blocker_id", &ids).Error
+	return ids, err
+}
+
+// loadBlocked fills Blocked for a page of tasks with one query
+func loadBlocked(tx *gorm.DB, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	var blocked []int64
+	if err := tx.Model(&models.Task{}).Where("tasks.id IN ?", ids).Where(blockedCond(true)).
+		Pluck("tasks.id", &blocked).Error; err != nil {
+		return err
+	}
+	set := make(map[int64]bool, len(blocked))
+	for _, id := range blocked {
+		set[id] = true
+	}
+	for i := range tasks {
+		tasks[i].Blocked = set[tasks[i].ID]
+	}
+	return nil
+}
+
+// dependencyPath looks for a chain of "blocked by" links from one task to
+// another and returns the task ids along it, or nil if there is none. The
+// walk is breadth first, a level of the graph per query.
+func dependencyPath(tx *gorm.DB, from, to int64) ([]int64, error) {
+	parent := map[int64]int64{from: 0}
+	level := []int64{from}
+	for len(level) > 0 {
+		var edges []models.TaskDependency
+		if err := tx.Where("task_id IN ?", level).Find(&edges).Error; err != nil {
+			return nil, err
+		}
+		level = nil
+		for _, e := range edges {
+			if _, seen := parent[e.BlockerID]; seen {
+				continue
+			}
+			parent[e.BlockerID] = e.TaskID
+			if e.BlockerID == to {
+				path := []int64{to}
+				for id := e.TaskID; id != 0; id = parent[id] {
+					path = append([]int64{id}, path...)
+				}
+				return path, nil
+			}
+			level = append(level, e.BlockerID)
+		}
+	}
+	return nil, nil
+}
+
+// notifyUnblocked tells the people on the tasks blocker was holding up
+// that they can start, once blocker is done or trashed. Call it after the
+// change is saved in tx; tasks still waiting for other blockers are skipped.
+func notifyUnblocked(tx *gorm.DB, c *gin.Context, blocker models.Task) error {
+	uid, _ := c.Get("user_id")
+	actor := uid.(int64)
+	var tasks []models.Task
+	if err := tx.Where("id IN (SELECT task_id FROM task_dependencies WHERE blocker_id = ?)", blocker.ID).
+		Where("status_category <> ?", models.StatusCategoryDone).Where(blockedCond(false)).
+		Find(&tasks).Error; err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		userID := t.UserID
+		if t.AssigneeID != nil {
+			userID = *t.AssigneeID
+		}
+		if err := notify(tx, models.Notification{
+			UserID:  userID,
+			ActorID: &actor,
+			TaskID:  &t.ID,
+			Type:    models.NotificationTaskUnblocked,
+			Message: fmt.Sprintf("%q is no longer blocked", t.Title),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishesTask reports whether a change moves a task into a done status
+func finishesTask(before, after models.Task) bool {
+	return before.StatusCategory != models.StatusCategoryDone && after.StatusCategory == models.StatusCategoryDone
+}
+
+// GetTaskDependencies lists the tasks blocking the task and those it
+// blocks, as far as the user can see them
+func GetTaskDependencies(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	var blockedBy, blocking []models.Task
+	err := config.DB.Scopes(visibleTasks(uid.(int64))).
+		Where("tasks.id IN (SELECT blocker_id FROM task_dependencies WHERE task_id = ?)", task.ID).
+		Order("tasks.id").Find(&blockedBy).Error
+	if err == nil {
+		err = config.DB.Scopes(visibleTasks(uid.(int64))).
+			Where("tasks.id IN (SELECT task_id FROM task_dependencies WHERE blocker_id = ?)", task.ID).
+			Order("tasks.id").Find(&blocking).Error
+	}
+	if err == nil {
+		err = decorateTasks(config.DB, blockedBy)
+	}
+	if err == nil {
+		err = decorateTasks(config.DB, blocking)
+	}
+	var open []int64
+	if err == nil {
+		open, err = openBlockers(config.DB, task.ID)
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"task_id":    task.ID,
+		"blocked":    len(open) > 0,
+		"blocked_by": blockedBy,
+		"blocking":   blocking,
+	})
+}
+
+// AddTaskDependency marks the task as blocked by another one. Links that
+// would close a cycle are refused with the cycle.
+func AddTaskDependency(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	var in struct {
+		BlockerID int64 `json:"blocker_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	if in.BlockerID == task.ID {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "a task can't block itself"})
+		return
+	}
+	blocker, err := findTask(config.DB, uid.(int64), in.BlockerID, false)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "blocker not found"})
+		return
+	}
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+
+	dep := models.TaskDependency{TaskID: task.ID, BlockerID: blocker.ID, CreatedBy: uid.(int64)}
+	var tooMany bool
+	var cycle []int64
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// lock both tasks, lower id first, so concurrent links between
+		// them are checked one after the other
+		for _, id := range []int64{min(task.ID, blocker.ID), max(task.ID, blocker.ID)} {
+			if err := tx.Model(&models.Task{}).Where("id = ?", id).UpdateColumn("version", gorm.Expr("version")).Error; err != nil {
+				return err
+			}
+		}
+		var count int64
+		if err := tx.Model(&models.TaskDependency{}).Where("task_id = ?", task.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if tooMany = count >= MaxBlockersPerTask; tooMany {
+			return nil
+		}
+		// the new link closes a cycle if the blocker already waits on the task
+		var err error
+		if cycle, err = dependencyPath(tx, blocker.ID, task.ID); err != nil || cycle != nil {
+			return err
+		}
+		return tx.Where(models.TaskDependency{TaskID: task.ID, BlockerID: blocker.ID}).FirstOrCreate(&dep).Error
+	})
+	switch {
+	case err != nil:
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create dependency"})
+	case tooMany:
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fmt.Sprintf("a task can have at most %d blockers", MaxBlockersPerTask)})
+	case cycle != nil:
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{
+			"details": "the dependency would create a cycle",
+			"cycle":   append([]int64{task.ID}, cycle...),
+		})
+	default:
+		helpers.APIResponse(c, http.StatusCreated, "Dependency added", dep)
+	}
+}
+
+// RemoveTaskDependency unblocks the task from one blocker
+func RemoveTaskDependency(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	blockerID, err := strconv.ParseInt(c.Param("blocker_id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid blocker id"})
+		return
+	}
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	res := config.DB.Where("task_id = ? AND blocker_id = ?", task.ID, blockerID).Delete(&models.TaskDependency{})
+	if res.Error != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "dependency not found"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Dependency removed", gin.H{"task_id": task.ID, "blocker_id": blockerID})
+}
@@ -0,0 +1,121 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func TestTaskDependencies(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+	api.DELETE("/tasks/:id", controllers.DeleteTask)
+	api.POST("/tasks/bulk", controllers.BulkTasks)
+	api.GET("/tasks/:id/dependencies", controllers.GetTaskDependencies)
+	api.POST("/tasks/:id/dependencies", controllers.AddTaskDependency)
+	api.DELETE("/tasks/:id/dependencies/:blocker_id", controllers.RemoveTaskDependency)
+	api.GET("/notifications", controllers.GetNotifications)
+
+	owner := seedUser(t, "planner")
+	member := seedUser(t, "builder")
+	project := models.Project{UserID: owner.ID, Name: "Release"}
+	config.DB.Create(&project)
+	config.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: member.ID, Role: models.ProjectRoleEditor})
+
+	ids := map[string]int64{}
+	for _, title := range []string{"design", "build", "ship", "party"} {
+		w := doJSON(r, "POST", "/api/tasks", gin.H{"title": title, "project_id": project.ID}, member.ID)
+		expectStatus(t, w, http.StatusCreated)
+		ids[title] = int64(responseData(t, w)["id"].(float64))
+	}
+	deps := func(title string) string { return fmt.Sprintf("/api/tasks/%d/dependencies", ids[title]) }
+	block := func(task, blocker string) int {
+		return doJSON(r, "POST", deps(task), gin.H{"blocker_id": ids[blocker]}, owner.ID).Code
+	}
+
+	// design → build → ship, and party waits on both build and ship
+	for _, d := range [][2]string{{"build", "design"}, {"ship", "build"}, {"party", "build"}, {"party", "ship"}} {
+		if code := block(d[0], d[1]); code != http.StatusCreated {
+			t.Fatalf("%s blocked by %s: %d", d[0], d[1], code)
+		}
+	}
+	if code := block("build", "build"); code != http.StatusBadRequest {
+		t.Fatalf("self dependency: %d", code)
+	}
+	w := doJSON(r, "POST", deps("design"), gin.H{"blocker_id": ids["ship"]}, owner.ID)
+	expectStatus(t, w, http.StatusConflict)
+	var body struct {
+		Error struct {
+			Cycle []int64 `json:"cycle"`
+		} `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if fmt.Sprint(body.Error.Cycle) != fmt.Sprint([]int64{ids["design"], ids["ship"], ids["build"], ids["design"]}) {
+		t.Fatalf("cycle=%v", body.Error.Cycle)
+	}
+
+	w = doJSON(r, "GET", deps("build"), nil, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+	data := responseData(t, w)
+	if data["blocked"] != true || len(data["blocked_by"].([]interface{})) != 1 || len(data["blocking"].([]interface{})) != 2 {
+		t.Fatalf("dependencies=%v", data)
+	}
+	w = doJSON(r, "GET", "/api/tasks?blocked=false", nil, owner.ID)
+	if tasks := responseData(t, w)["tasks"].([]interface{}); len(tasks) != 1 || tasks[0].(map[string]interface{})["title"] != "design" {
+		t.Fatalf("unblocked tasks=%v", tasks)
+	}
+	w = doJSON(r, "GET", "/api/tasks?query=blocked:true", nil, owner.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 3 {
+		t.Fatalf("blocked tasks=%d want 3", n)
+	}
+
+	// blocked tasks can't be finished unless forced
+	path := fmt.Sprintf("/api/tasks/%d", ids["build"])
+	expectStatus(t, doJSON(r, "PATCH", path, gin.H{"status": "completed"}, owner.ID), http.StatusConflict)
+	w = doJSON(r, "POST", "/api/tasks/bulk", gin.H{"ids": []int64{ids["build"]}, "action": "complete"}, owner.ID)
+	if n := responseData(t, w)["summary"].(map[string]interface{})["skipped"]; n != float64(1) {
+		t.Fatalf("bulk complete of a blocked task: %v", responseData(t, w))
+	}
+
+	party := fmt.Sprintf("/api/tasks/%d?force=true", ids["party"])
+	expectStatus(t, doJSON(r, "PATCH", party, gin.H{"status": "completed"}, owner.ID), http.StatusOK)
+
+	// finishing a blocker notifies the tasks it frees, not those still waiting
+	expectStatus(t, doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%d", ids["design"]), gin.H{"status": "completed"}, owner.ID), http.StatusOK)
+	expectStatus(t, doJSON(r, "PATCH", path, gin.H{"status": "completed"}, owner.ID), http.StatusOK)
+	w = doJSON(r, "GET", "/api/notifications", nil, member.ID)
+	var got []string
+	for _, n := range responseData(t, w)["notifications"].([]interface{}) {
+		n := n.(map[string]interface{})
+		if n["type"] == models.NotificationTaskUnblocked {
+			got = append(got, n["message"].(string))
+		}
+	}
+	if fmt.Sprint(got) != `["ship" is no longer blocked "build" is no longer blocked]` {
+		t.Fatalf("notifications=%v", got)
+	}
+
+	expectStatus(t, doJSON(r, "DELETE", fmt.Sprintf("%s/%d", deps("party"), ids["ship"]), nil, owner.ID), http.StatusOK)
+	expectStatus(t, doJSON(r, "DELETE", fmt.Sprintf("%s/%d", deps("party"), ids["ship"]), nil, owner.ID), http.StatusNotFound)
+	w = doJSON(r, "GET", "/api/tasks?blocked=true", nil, owner.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 0 {
+		t.Fatalf("blocked tasks=%d want 0", n)
+	}
+
+	// trashing finished blockers doesn't notify again
+	expectStatus(t, doJSON(r, "DELETE", path, nil, owner.ID), http.StatusOK)
+	doJSON(r, "POST", "/api/tasks/bulk", gin.H{"ids": []int64{ids["design"]}, "action": "delete"}, owner.ID)
+	var count int64
+	config.DB.Model(&models.Notification{}).Where("type = ?", models.NotificationTaskUnblocked).Count(&count)
+	if count != 2 {
+		t.Fatalf("unblocked notifications=%d want 2", count)
+	}
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// errStaleTask means the task changed between loading and saving it
+var errStaleTask = errors.New("task was changed by someone else")
+
+// nextVersion bumps a task's version in Update/Updates calls
+var nextVersion = gorm.Expr("version + 1")
+
+// taskETag is the task's entity tag: its id and version, followed by a
+// hash of what responses show from other tables (comments, blockers,
+// tracked time, checklist progress) and of the position, which change
+// without bumping the version
+func taskETag(task models.Task) string {
+	h := fnv.New32a()
+	fmt.Fprint(h, task.CommentCount, task.Blocked, task.TrackedMinutes, task.Checklist.Done, task.Checklist.Total, task.Position)
+	return fmt.Sprintf(`"%d-%d-%08x"`, task.ID, task.Version, h.Sum32())
+}
+
+// versionListed reports whether an If-Match header names the task's
+// current version. Only the id and version of a tag count, so a new
+// comment doesn't fail the next save; tags without the hash match too.
+func versionListed(header string, task models.Task) bool {
+	prefix := fmt.Sprintf(`"%d-%d`, task.ID, task.Version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == prefix+`"` || strings.HasPrefix(tag, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// etagListed reports whether an If-None-Match header names etag, weakly
+func etagListed(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch answers 412 unless the request's If-Match names the task's
+// current version. Requests without the header always pass.
+func checkIfMatch(c *gin.Context, task models.Task) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" || versionListed(header, task) {
+		return true
+	}
+	staleTaskResponse(c, task)
+	return false
+}
+
+// staleTaskResponse reports a lost update: 412 if the client sent If-Match,
+// 409 if it was simply beaten to the save
+func staleTaskResponse(c *gin.Context, task models.Task) {
+	code, message := http.StatusConflict, "Conflict"
+	if c.GetHeader("If-Match") != "" {
+		code, message = http.StatusPreconditionFailed, "Precondition failed"
+	}
+	helpers.ErrorResponse(c, code, message, gin.H{
+		"details": "task was changed by someone else; reload it and try again",
+		"version": task.Version,
+	})
+}
+
+// notModified answers 304 when If-None-Match names the decorated task's
+// ETag
+func notModified(c *gin.Context, task models.Task) bool {
+	etag := taskETag(task)
+	if !etagListed(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	c.Header("ETag", etag)
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// taskResponse writes a single task along with its ETag
+func taskResponse(c *gin.Context, code int, message string, task models.Task) {
+	c.Header("ETag", taskETag(task))
+	helpers.APIResponse(c, code, message, task)
+}
+
+// saveTask writes all of task's columns and bumps its version, provided the
+// stored row is still at task.Version; otherwise it returns errStaleTask
+// and writes nothing.
+func saveTask(tx *gorm.DB, task *models.Task) error {
+	version := task.Version
+	task.Version++
+	res := tx.Model(task).Where("version = ?", version).Select("*").Omit("created_at", "deleted_at").Updates(task)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = errStaleTask
+	}
+	if res.Error != nil {
+		task.Version = version
+	}
+	return res.Error
+}
+
+// bumpVersion records a change to something belonging to the task, such as
+// its checklist, by bumping its version the way saveTask does
+func bumpVersion(tx *gorm.DB, task *models.Task) error {
+	res := tx.Model(task).Where("version = ?", task.Version).Update("version", nextVersion)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return errStaleTask
+	}
+	if res.Error == nil {
+		task.Version++
+	}
+	return res.Error
+}
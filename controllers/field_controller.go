@@ -0,0 +1,562 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxCustomFields     = 50
+	MaxFieldOptions     = 50
+	MaxFieldTextLength  = 1000
+	MaxFieldOptionChars = 50
+)
+
+// projectFields loads a project's custom fields in order
+func projectFields(tx *gorm.DB, projectID int64) ([]models.CustomField, error) {
+	var fields []models.CustomField
+	err := tx.Where("project_id = ?", projectID).Order("position, id").Find(&fields).Error
+	return fields, err
+}
+
+// taskFields loads the custom fields of the task's project
+func taskFields(tx *gorm.DB, task models.Task) ([]models.CustomField, error) {
+	if task.ProjectID == nil {
+		return nil, nil
+	}
+	return projectFields(tx, *task.ProjectID)
+}
+
+func findField(fields []models.CustomField, key string) (models.CustomField, bool) {
+	for _, f := range fields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return models.CustomField{}, false
+}
+
+// fieldValue validates a value for the field and returns the row to store
+// along with the value as tasks show it. ok is false for null and empty
+// values, which clear the field.
+func fieldValue(f models.CustomField, v interface{}) (row models.TaskFieldValue, shown interface{}, ok bool, err error) {
+	row.FieldID = f.ID
+	if v == nil {
+		return row, nil, false, nil
+	}
+	fail := func(want string) (models.TaskFieldValue, interface{}, bool, error) {
+		return row, nil, false, fmt.Errorf("custom field %s must be %s", f.Key, want)
+	}
+	switch f.Type {
+	case models.FieldTypeText, models.FieldTypeURL:
+		s, isString := v.(string)
+		if !isString {
+			return fail("a string")
+		}
+		if s = strings.TrimSpace(s); s == "" {
+			return row, nil, false, nil
+		}
+		if len(s) > MaxFieldTextLength {
+			return fail(fmt.Sprintf("at most %d characters", MaxFieldTextLength))
+		}
+		if f.Type == models.FieldTypeURL {
+			u, err := url.Parse(s)
+			if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+				return fail("an http(s) URL")
+			}
+		}
+		row.Text = &s
+		return row, s, true, nil
+
+	case models.FieldTypeNumber:
+		n, isNumber := v.(float64)
+		if !isNumber || math.IsInf(n, 0) || math.IsNaN(n) {
+			return fail("a number")
+		}
+		row.Number = &n
+		return row, n, true, nil
+
+	case models.FieldTypeCheckbox:
+		b, isBool := v.(bool)
+		if !isBool {
+			return fail("true or false")
+		}
+		n := 0.0
+		if b {
+			n = 1
+		}
+		row.Number = &n
+		return row, b, true, nil
+
+	case models.FieldTypeDate:
+		s, isString := v.(string)
+		if !isString {
+			return fail("a date (YYYY-MM-DD)")
+		}
+		if s = strings.TrimSpace(s); s == "" {
+			return row, nil, false, nil
+		}
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return fail("a date (YYYY-MM-DD)")
+		}
+		row.Date = &d
+		return row, s, true, nil
+
+	case models.FieldTypeSelect:
+		s, isString := v.(string)
+		if !isString {
+			return fail("one of " + strings.Join(f.Options, "|"))
+		}
+		if s = strings.TrimSpace(s); s == "" {
+			return row, nil, false, nil
+		}
+		if indexOf(f.Options, s) < 0 {
+			return fail("one of " + strings.Join(f.Options, "|"))
+		}
+		row.Text = &s
+		return row, s, true, nil
+
+	case models.FieldTypeMultiSelect:
+		list, isList := v.([]interface{})
+		if !isList {
+			return fail("a list of " + strings.Join(f.Options, "|"))
+		}
+		// keep the options' order, without duplicates
+		chosen := map[string]bool{}
+		for _, item := range list {
+			s, isString := item.(string)
+			if !isString || indexOf(f.Options, s) < 0 {
+				return fail("a list of " + strings.Join(f.Options, "|"))
+			}
+			chosen[s] = true
+		}
+		if len(chosen) == 0 {
+			return row, nil, false, nil
+		}
+		values := []string{}
+		for _, o := range f.Options {
+			if chosen[o] {
+				values = append(values, o)
+			}
+		}
+		b, _ := json.Marshal(values)
+		text := string(b)
+		row.Text = &text
+		return row, values, true, nil
+	}
+	return fail("a known type")
+}
+
+// shownValue turns a stored row back into the value tasks show
+func shownValue(f models.CustomField, row models.TaskFieldValue) interface{} {
+	switch {
+	case f.Type == models.FieldTypeCheckbox && row.Number != nil:
+		return *row.Number == 1
+	case f.Type == models.FieldTypeMultiSelect && row.Text != nil:
+		values := []string{}
+		json.Unmarshal([]byte(*row.Text), &values)
+		return values
+	case row.Number != nil:
+		return *row.Number
+	case row.Date != nil:
+		return row.Date.UTC().Format("2006-01-02")
+	case row.Text != nil:
+		return *row.Text
+	}
+	return nil
+}
+
+// taskFieldValues validates a task's custom field values against its
+// project's fields and returns the rows to store and the values as shown.
+// When the task has just moved, values it brought along unchanged are
+// dropped if the new project can't hold them instead of being an error.
+func taskFieldValues(fields []models.CustomField, in, carried map[string]interface{}) ([]models.TaskFieldValue, map[string]interface{}, error) {
+	rows := []models.TaskFieldValue{}
+	shown := map[string]interface{}{}
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		_, isCarried := carried[k]
+		isCarried = isCarried && jsonEqual(carried[k], in[k])
+		f, ok := findField(fields, k)
+		if !ok {
+			if isCarried {
+				continue
+			}
+			if len(fields) == 0 {
+				return nil, nil, fmt.Errorf("the project has no custom field %s", k)
+			}
+			return nil, nil, fmt.Errorf("unknown custom field %s; use %s", k, fieldKeys(fields))
+		}
+		row, v, ok, err := fieldValue(f, in[k])
+		if err != nil {
+			if isCarried {
+				continue
+			}
+			return nil, nil, err
+		}
+		if ok {
+			rows = append(rows, row)
+			shown[k] = v
+		}
+	}
+	return rows, shown, nil
+}
+
+// jsonEqual compares two decoded JSON values, treating numbers alike
+// whatever their Go type
+func jsonEqual(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+func fieldKeys(fields []models.CustomField) string {
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = f.Key
+	}
+	return strings.Join(keys, "|")
+}
+
+// setTaskFields replaces the custom field values of a task
+func setTaskFields(tx *gorm.DB, taskID int64, rows []models.TaskFieldValue) error {
+	if err := tx.Where("task_id = ?", taskID).Delete(&models.TaskFieldValue{}).Error; err != nil {
+		return err
+	}
+	for i := range rows {
+		rows[i].TaskID = taskID
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
+
+// loadCustomFields fills CustomFields for a page of tasks with one query.
+// Values of fields from a project the task has left are ignored.
+func loadCustomFields(tx *gorm.DB, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	var rows []struct {
+		models.TaskFieldValue
+		Key  string
+		Type string
+	}
+	if err := tx.Table("task_field_values").
+		Select("task_field_values.*, custom_fields.key, custom_fields.type").
+		Joins("JOIN custom_fields ON custom_fields.id = task_field_values.field_id").
+		Joins("JOIN tasks ON tasks.id = task_field_values.task_id AND tasks.project_id = custom_fields.project_id").
+		Where("task_field_values.task_id IN ?", ids).Scan(&rows).Error; err != nil {
+		return err
+	}
+	byTask := make(map[int64]map[string]interface{}, len(tasks))
+	for _, r := range rows {
+		if byTask[r.TaskID] == nil {
+			byTask[r.TaskID] = map[string]interface{}{}
+		}
+		byTask[r.TaskID][r.Key] = shownValue(models.CustomField{Type: r.Type}, r.TaskFieldValue)
+	}
+	for i := range tasks {
+		tasks[i].CustomFields = byTask[tasks[i].ID]
+		if tasks[i].CustomFields == nil {
+			tasks[i].CustomFields = map[string]interface{}{}
+		}
+	}
+	return nil
+}
+
+// remapFieldValues follows a task to another project: its values move to
+// the new project's fields of the same key and type, and values that have
+// no such field or don't fit its options are dropped
+func remapFieldValues(tx *gorm.DB, task models.Task) error {
+	fields, err := taskFields(tx, task)
+	if err != nil {
+		return err
+	}
+	var rows []struct {
+		models.TaskFieldValue
+		Key  string
+		Type string
+	}
+	if err := tx.Table("task_field_values").
+		Select("task_field_values.*, custom_fields.key, custom_fields.type").
+		Joins("JOIN custom_fields ON custom_fields.id = task_field_values.field_id").
+		Where("task_field_values.task_id = ?", task.ID).Scan(&rows).Error; err != nil {
+		return err
+	}
+	var keep []models.TaskFieldValue
+	for _, r := range rows {
+		f, ok := findField(fields, r.Key)
+		if !ok || f.Type != r.Type {
+			continue
+		}
+		// round-trip through JSON, as a client would send it
+		b, _ := json.Marshal(shownValue(models.CustomField{Type: r.Type}, r.TaskFieldValue))
+		var v interface{}
+		json.Unmarshal(b, &v)
+		if row, _, ok, err := fieldValue(f, v); err == nil && ok {
+			keep = append(keep, row)
+		}
+	}
+	return setTaskFields(tx, task.ID, keep)
+}
+
+// fieldInput is the body of the custom field routes. Key and type can't
+// change once the field exists.
+type fieldInput struct {
+	Key     string   `json:"key"`
+	Name    string   `json:"name" binding:"required"`
+	Type    string   `json:"type"`
+	Options []string `json:"options"`
+}
+
+// options validates the choices of a select or multi_select field
+func (in fieldInput) options(fieldType string) ([]string, error) {
+	if fieldType != models.FieldTypeSelect && fieldType != models.FieldTypeMultiSelect {
+		if len(in.Options) > 0 {
+			return nil, errors.New("only select and multi_select fields have options")
+		}
+		return nil, nil
+	}
+	if len(in.Options) == 0 || len(in.Options) > MaxFieldOptions {
+		return nil, fmt.Errorf("a %s field has 1 to %d options", fieldType, MaxFieldOptions)
+	}
+	options := make([]string, 0, len(in.Options))
+	for _, o := range in.Options {
+		o = strings.TrimSpace(o)
+		if o == "" || len(o) > MaxFieldOptionChars {
+			return nil, fmt.Errorf("options have 1-%d characters", MaxFieldOptionChars)
+		}
+		if indexOf(options, o) >= 0 {
+			return nil, fmt.Errorf("option %q given more than once", o)
+		}
+		options = append(options, o)
+	}
+	return options, nil
+}
+
+func (in fieldInput) name() (string, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" || len(name) > 50 {
+		return "", errors.New("name must have 1-50 characters")
+	}
+	return name, nil
+}
+
+// projectField loads the field named in the URL, writing the error
+// response itself
+func projectField(c *gin.Context, project models.Project) (models.CustomField, bool) {
+	var field models.CustomField
+	id, err := strconv.ParseInt(c.Param("field_id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid field id"})
+		return field, false
+	}
+	err = config.DB.Where("id = ? AND project_id = ?", id, project.ID).First(&field).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "field not found"})
+		return field, false
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return field, false
+	}
+	return field, true
+}
+
+// GetProjectFields lists the custom fields of a project
+func GetProjectFields(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	if _, _, ok := requireProject(c, uid.(int64), id, ""); !ok {
+		return
+	}
+	fields, err := projectFields(config.DB, id)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"fields": fields})
+}
+
+// CreateProjectField adds a custom field to a project
+func CreateProjectField(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	var in fieldInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	field := models.CustomField{
+		ProjectID: project.ID,
+		Key:       strings.ToLower(strings.TrimSpace(in.Key)),
+		Type:      strings.ToLower(strings.TrimSpace(in.Type)),
+	}
+	if !helpers.IsValidFieldKey(field.Key) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "key must be 1-30 lowercase letters, digits or _, starting with a letter"})
+		return
+	}
+	if !models.IsValidFieldType(field.Type) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "type must be " + strings.Join(models.ValidFieldTypes, "|")})
+		return
+	}
+	var err error
+	if field.Name, err = in.name(); err == nil {
+		field.Options, err = in.options(field.Type)
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+
+	fields, err := projectFields(config.DB, project.ID)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if _, taken := findField(fields, field.Key); taken {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": "the project already has a field " + field.Key})
+		return
+	}
+	if len(fields) >= MaxCustomFields {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fmt.Sprintf("a project can have at most %d custom fields", MaxCustomFields)})
+		return
+	}
+	field.Position = len(fields)
+	if err := config.DB.Create(&field).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create field"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusCreated, "Field created", field)
+}
+
+// UpdateProjectField renames a custom field or changes its options.
+// Options still chosen by tasks can't be removed.
+func UpdateProjectField(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	field, ok := projectField(c, project)
+	if !ok {
+		return
+	}
+	var in fieldInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if in.Key != "" && in.Key != field.Key || in.Type != "" && in.Type != field.Type {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "a field's key and type can't be changed"})
+		return
+	}
+	name, err := in.name()
+	var options []string
+	if err == nil {
+		options, err = in.options(field.Type)
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	for _, o := range field.Options {
+		if indexOf(options, o) >= 0 {
+			continue
+		}
+		q := config.DB.Model(&models.TaskFieldValue{}).Where("task_field_values.field_id = ?", field.ID)
+		if field.Type == models.FieldTypeSelect {
+			q = q.Where("task_field_values.text = ?", o)
+		} else {
+			q = q.Where("task_field_values.text LIKE ? ESCAPE '\\'", "%"+escapeLike(jsonString(o))+"%")
+		}
+		var used int64
+		if err := q.Count(&used).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if used > 0 {
+			helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": fmt.Sprintf("option %q is used by %d tasks", o, used)})
+			return
+		}
+	}
+	field.Name, field.Options = name, options
+	if err := config.DB.Save(&field).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Field updated", field)
+}
+
+// DeleteProjectField removes a custom field and every task's value for it
+func DeleteProjectField(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	field, ok := projectField(c, project)
+	if !ok {
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("field_id = ?", field.ID).Delete(&models.TaskFieldValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&field).Error
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Field deleted", gin.H{"id": field.ID})
+}
+
+// jsonString is s as a JSON string literal, as stored in multi_select values
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
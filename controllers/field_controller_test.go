@@ -0,0 +1,157 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+)
+
+func TestCustomFields(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/projects", controllers.CreateProject)
+	api.GET("/projects/:id/fields", controllers.GetProjectFields)
+	api.POST("/projects/:id/fields", controllers.CreateProjectField)
+	api.PUT("/projects/:id/fields/:field_id", controllers.UpdateProjectField)
+	api.DELETE("/projects/:id/fields/:field_id", controllers.DeleteProjectField)
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+
+	u := seedUser(t, "fielder")
+	w := doJSON(r, "POST", "/api/projects", gin.H{"name": "Support"}, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	project := responseData(t, w)["id"]
+	fields := fmt.Sprintf("/api/projects/%v/fields", project)
+
+	for _, f := range []gin.H{
+		{"key": "ticket", "name": "Ticket", "type": "text"},
+		{"key": "estimate", "name": "Estimate", "type": "number"},
+		{"key": "area", "name": "Area", "type": "select", "options": []string{"api", "web"}},
+		{"key": "labels", "name": "Labels", "type": "multi_select", "options": []string{"bug", "ux", "perf"}},
+		{"key": "billable", "name": "Billable", "type": "checkbox"},
+		{"key": "deadline", "name": "Deadline", "type": "date"},
+		{"key": "link", "name": "Link", "type": "url"},
+	} {
+		expectStatus(t, doJSON(r, "POST", fields, f, u.ID), http.StatusCreated)
+	}
+	expectStatus(t, doJSON(r, "POST", fields, gin.H{"key": "ticket", "name": "Again", "type": "text"}, u.ID), http.StatusConflict)
+	expectStatus(t, doJSON(r, "POST", fields, gin.H{"key": "size", "name": "Size", "type": "select"}, u.ID), http.StatusBadRequest)
+	expectStatus(t, doJSON(r, "POST", fields, gin.H{"key": "Bad Key", "name": "X", "type": "text"}, u.ID), http.StatusBadRequest)
+	w = doJSON(r, "GET", fields, nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	defs := responseData(t, w)["fields"].([]interface{})
+	if len(defs) != 7 {
+		t.Fatalf("fields=%d want 7", len(defs))
+	}
+	area := defs[2].(map[string]interface{})["id"]
+
+	newTask := func(title string, values gin.H) map[string]interface{} {
+		t.Helper()
+		w := doJSON(r, "POST", "/api/tasks", gin.H{"title": title, "project_id": project, "custom_fields": values}, u.ID)
+		expectStatus(t, w, http.StatusCreated)
+		return responseData(t, w)
+	}
+	a := newTask("a", gin.H{"ticket": "SUP-12", "estimate": 3, "area": "api", "labels": []string{"ux", "bug", "ux"},
+		"billable": true, "deadline": "2026-11-01", "link": "https://example.com/t/12"})
+	if got := fmt.Sprint(a["custom_fields"]); got != "map[area:api billable:true deadline:2026-11-01 estimate:3 labels:[bug ux] link:https://example.com/t/12 ticket:SUP-12]" {
+		t.Fatalf("custom_fields=%s", got)
+	}
+	newTask("b", gin.H{"estimate": 8, "area": "web", "labels": []string{"perf"}, "billable": false})
+	newTask("c", nil)
+
+	for _, bad := range []gin.H{
+		{"nope": "x"},
+		{"estimate": "three"},
+		{"area": "mobile"},
+		{"labels": "bug"},
+		{"billable": "yes"},
+		{"deadline": "tomorrow"},
+		{"link": "ftp://example.com"},
+	} {
+		w := doJSON(r, "POST", "/api/tasks", gin.H{"title": "bad", "project_id": project, "custom_fields": bad}, u.ID)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: status %d want 400", bad, w.Code)
+		}
+	}
+
+	// merge patches merge into custom_fields; null clears a value
+	w = doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%v", a["id"]), gin.H{"custom_fields": gin.H{"estimate": 5, "link": nil}}, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	cf := responseData(t, w)["custom_fields"].(map[string]interface{})
+	if cf["estimate"] != float64(5) || cf["link"] != nil || cf["ticket"] != "SUP-12" {
+		t.Fatalf("patched custom_fields=%v", cf)
+	}
+
+	titles := func(query, sort string) string {
+		t.Helper()
+		w := doJSON(r, "GET", "/api/tasks?query="+url.QueryEscape(query)+"&sort="+sort, nil, u.ID)
+		expectStatus(t, w, http.StatusOK)
+		var out []interface{}
+		for _, task := range responseData(t, w)["tasks"].([]interface{}) {
+			out = append(out, task.(map[string]interface{})["title"])
+		}
+		return fmt.Sprint(out)
+	}
+	for _, tc := range []struct{ query, sort, want string }{
+		{"cf.estimate>4", "title", "[a b]"},
+		{"cf.estimate!=5", "title", "[b c]"},
+		{"cf.area:web", "title", "[b]"},
+		{"cf.labels:bug", "title", "[a]"},
+		{"cf.labels!=bug", "title", "[b c]"},
+		{"cf.billable:false", "title", "[b c]"},
+		{"cf.ticket:sup", "title", "[a]"},
+		{"cf.deadline<2026-12-01", "title", "[a]"},
+		{"cf.estimate:none", "title", "[c]"},
+		{"project:" + fmt.Sprint(project), "-cf.estimate", "[b a c]"},
+		{"project:" + fmt.Sprint(project), "cf.area,title", "[a b c]"},
+	} {
+		if got := titles(tc.query, tc.sort); got != tc.want {
+			t.Errorf("query %q sort %s: %s want %s", tc.query, tc.sort, got, tc.want)
+		}
+	}
+	// custom dates are calendar dates, whatever the user's time zone
+	config.DB.Model(&u).Update("time_zone", "America/New_York")
+	for query, want := range map[string]string{
+		"cf.deadline:2026-11-01":  "[a]",
+		"cf.deadline<=2026-10-31": "[]",
+		"cf.deadline>=2026-11-01": "[a]",
+	} {
+		if got := titles(query, "title"); got != want {
+			t.Errorf("New York: query %q: %s want %s", query, got, want)
+		}
+	}
+	config.DB.Model(&u).Update("time_zone", "")
+	w = doJSON(r, "GET", "/api/tasks?query="+url.QueryEscape("cf.missing:x"), nil, u.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+	w = doJSON(r, "GET", "/api/tasks?query="+url.QueryEscape("cf.area>api"), nil, u.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	// options in use can't be removed
+	areaPath := fmt.Sprintf("%s/%v", fields, area)
+	expectStatus(t, doJSON(r, "PUT", areaPath, gin.H{"name": "Area", "options": []string{"api"}}, u.ID), http.StatusConflict)
+	expectStatus(t, doJSON(r, "PUT", areaPath, gin.H{"name": "Area", "type": "text"}, u.ID), http.StatusBadRequest)
+	expectStatus(t, doJSON(r, "PUT", areaPath, gin.H{"name": "Component", "options": []string{"api", "web", "ios"}}, u.ID), http.StatusOK)
+
+	// moving drops the values the other project has no field for
+	w = doJSON(r, "POST", "/api/projects", gin.H{"name": "Elsewhere"}, u.ID)
+	other := responseData(t, w)["id"]
+	expectStatus(t, doJSON(r, "POST", fmt.Sprintf("/api/projects/%v/fields", other),
+		gin.H{"key": "estimate", "name": "Points", "type": "number"}, u.ID), http.StatusCreated)
+	w = doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%v", a["id"]), gin.H{"project_id": other}, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	if got := fmt.Sprint(responseData(t, w)["custom_fields"]); got != "map[estimate:5]" {
+		t.Fatalf("moved custom_fields=%s", got)
+	}
+
+	// deleting a field deletes its values
+	expectStatus(t, doJSON(r, "DELETE", areaPath, nil, u.ID), http.StatusOK)
+	if got := titles("project:"+fmt.Sprint(project), "title"); got != "[b c]" {
+		t.Fatalf("tasks=%s", got)
+	}
+	expectStatus(t, doJSON(r, "GET", "/api/tasks?query="+url.QueryEscape("cf.area:web"), nil, u.ID), http.StatusBadRequest)
+}
@@ -0,0 +1,87 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/internal/testutil"
+	"go-todo-app/models"
+)
+
+var userSeq int64
+
+// newTestAPI returns a router whose /api group authenticates through the
+// X-Test-User header instead of a JWT, so tests can act as several users
+func newTestAPI() (*gin.Engine, *gin.RouterGroup) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	config.C.JWTSecret = "testsecret"
+	config.C.JWTExpiry = time.Hour
+	config.DB = testutil.NewTestDB()
+
+	api := r.Group("/api")
+	api.Use(func(c *gin.Context) {
+		id, _ := strconv.ParseInt(c.GetHeader("X-Test-User"), 10, 64)
+		c.Set("user_id", id)
+		c.Next()
+	})
+	return r, api
+}
+
+// seedUser creates a user with a unique username; the in-memory test
+// database is shared between tests
+func seedUser(t *testing.T, name string) models.User {
+	t.Helper()
+	n := atomic.AddInt64(&userSeq, 1)
+	u := models.User{
+		Username:     fmt.Sprintf("%s%d", name, n),
+		Email:        fmt.Sprintf("%s%d@example.com", name, n),
+		PasswordHash: "x",
+	}
+	if err := config.DB.Create(&u).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	return u
+}
+
+func doJSON(r *gin.Engine, method, path string, body interface{}, userID int64) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", strconv.FormatInt(userID, 10))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// responseData decodes the "data" member of a helpers.Response body
+func responseData(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode body %q: %v", w.Body.String(), err)
+	}
+	return resp.Data
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, code int) {
+	t.Helper()
+	if w.Code != code {
+		t.Fatalf("status=%d want %d body=%s", w.Code, code, w.Body.String())
+	}
+}
@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// memberView is a project member as listed to the project. Emails are
+// only shown to the owner.
+type memberView struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email,omitempty"`
+	Role     string `json:"role"`
+}
+
+// GetProjectMembers lists the project's creator and members
+func GetProjectMembers(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, role, ok := requireProject(c, uid.(int64), id, "")
+	if !ok {
+		return
+	}
+
+	var owner models.User
+	if err := config.DB.First(&owner, project.UserID).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	members := []memberView{{UserID: owner.ID, Username: owner.Username, Email: owner.Email, Role: models.ProjectRoleOwner}}
+
+	var rows []memberView
+	err := config.DB.Table("project_members").
+		Select("users.id AS user_id, users.username, users.email, project_members.role").
+		Joins("JOIN users ON users.id = project_members.user_id").
+		Where("project_members.project_id = ?", project.ID).
+		Order("project_members.id asc").
+		Scan(&rows).Error
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	members = append(members, rows...)
+	if role != models.ProjectRoleOwner {
+		for i := range members {
+			if members[i].UserID != uid.(int64) {
+				members[i].Email = ""
+			}
+		}
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"members": members})
+}
+
+func parseMemberUserID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid user id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// UpdateProjectMember changes a member's role (owners only)
+func UpdateProjectMember(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseMemberUserID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	var in struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	role := strings.ToLower(strings.TrimSpace(in.Role))
+	if !models.IsValidProjectRole(role) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "role must be owner|editor|viewer"})
+		return
+	}
+	if memberID == project.UserID {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "the project creator is always an owner"})
+		return
+	}
+
+	var member models.ProjectMember
+	if err := config.DB.Where("project_id = ? AND user_id = ?", project.ID, memberID).First(&member).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "member not found"})
+		return
+	}
+	member.Role = role
+	if err := config.DB.Save(&member).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Updated", member)
+}
+
+// RemoveProjectMember removes a member. Owners can remove anyone but the
+// creator; any member can remove themselves to leave the project.
+func RemoveProjectMember(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseMemberUserID(c)
+	if !ok {
+		return
+	}
+	minRole := models.ProjectRoleOwner
+	if memberID == uid.(int64) {
+		minRole = ""
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, minRole)
+	if !ok {
+		return
+	}
+	if memberID == project.UserID {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "the project creator cannot be removed"})
+		return
+	}
+
+	var removed int64
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("project_id = ? AND user_id = ?", project.ID, memberID).Delete(&models.ProjectMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		// former members can't keep tasks assigned to them
+		return tx.Model(&models.Task{}).Where("project_id = ? AND assignee_id = ?", project.ID, memberID).
+			Updates(map[string]interface{}{"assignee_id": nil, "version": nextVersion}).Error
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
+		return
+	}
+	if removed == 0 {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "member not found"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Deleted", gin.H{"project_id": project.ID, "user_id": memberID})
+}
+
+// InviteToProject invites a user, found by username or email, to a project
+func InviteToProject(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	if project.IsInbox {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "inbox cannot be shared"})
+		return
+	}
+	var in struct {
+		Identity string `json:"identity" binding:"required"` // username or email
+		Role     string `json:"role"`                        // owner|editor|viewer
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	role := strings.ToLower(strings.TrimSpace(in.Role))
+	if role == "" {
+		role = models.ProjectRoleEditor
+	}
+	if !models.IsValidProjectRole(role) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "role must be owner|editor|viewer"})
+		return
+	}
+
+	identity := strings.TrimSpace(in.Identity)
+	q := config.DB
+	if helpers.IsValidEmail(identity) {
+		q = q.Where("email = ?", strings.ToLower(identity))
+	} else {
+		q = q.Where("username = ?", identity)
+	}
+	var invitee models.User
+	if err := q.First(&invitee).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "user not found"})
+		return
+	}
+	if existing, _ := projectRole(config.DB, invitee.ID, project); existing != "" {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": "user is already a member"})
+		return
+	}
+	var pending int64
+	config.DB.Model(&models.ProjectInvitation{}).
+		Where("project_id = ? AND invitee_id = ? AND status = ?", project.ID, invitee.ID, models.InvitationPending).
+		Count(&pending)
+	if pending > 0 {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": "user already has a pending invitation"})
+		return
+	}
+
+	inv := models.ProjectInvitation{
+		ProjectID: project.ID,
+		InviterID: uid.(int64),
+		InviteeID: invitee.ID,
+		Role:      role,
+		Status:    models.InvitationPending,
+	}
+	if err := config.DB.Create(&inv).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create invitation"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusCreated, "Invitation sent", inv)
+}
+
+// GetProjectInvitations lists pending invitations of a project (owners only)
+func GetProjectInvitations(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	if _, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner); !ok {
+		return
+	}
+	var invitations []models.ProjectInvitation
+	if err := config.DB.Where("project_id = ? AND status = ?", id, models.InvitationPending).
+		Order("id desc").Find(&invitations).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"invitations": invitations})
+}
+
+// RevokeInvitation deletes a pending invitation (owners only)
+func RevokeInvitation(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	invID, err := strconv.ParseInt(c.Param("invitation_id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid invitation id"})
+		return
+	}
+	if _, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner); !ok {
+		return
+	}
+	result := config.DB.Where("id = ? AND project_id = ? AND status = ?", invID, id, models.InvitationPending).
+		Delete(&models.ProjectInvitation{})
+	if result.Error != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "invitation not found"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Deleted", gin.H{"id": invID})
+}
+
+// GetMyInvitations lists pending invitations addressed to the current user
+func GetMyInvitations(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var invitations []models.ProjectInvitation
+	if err := config.DB.Where("invitee_id = ? AND status = ?", uid.(int64), models.InvitationPending).
+		Order("id desc").Find(&invitations).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"invitations": invitations})
+}
+
+func AcceptInvitation(c *gin.Context) {
+	respondToInvitation(c, true)
+}
+
+func DeclineInvitation(c *gin.Context) {
+	respondToInvitation(c, false)
+}
+
+func respondToInvitation(c *gin.Context, accept bool) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid invitation id"})
+		return
+	}
+
+	var inv models.ProjectInvitation
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND invitee_id = ? AND status = ?", id, uid.(int64), models.InvitationPending).
+			First(&inv).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		inv.RespondedAt = &now
+		inv.Status = models.InvitationDeclined
+		if accept {
+			inv.Status = models.InvitationAccepted
+			member := models.ProjectMember{ProjectID: inv.ProjectID, UserID: inv.InviteeID, Role: inv.Role}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&inv).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "invitation not found"})
+			return
+		}
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update invitation"})
+		return
+	}
+	msg := "Invitation declined"
+	if accept {
+		msg = "Invitation accepted"
+	}
+	helpers.APIResponse(c, http.StatusOK, msg, inv)
+}
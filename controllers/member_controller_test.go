@@ -0,0 +1,141 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/controllers"
+)
+
+func setupMemberRouter() *gin.Engine {
+	r, api := newTestAPI()
+	api.POST("/projects", controllers.CreateProject)
+	api.GET("/projects", controllers.GetProjects)
+	api.GET("/projects/:id/members", controllers.GetProjectMembers)
+	api.PUT("/projects/:id/members/:user_id", controllers.UpdateProjectMember)
+	api.DELETE("/projects/:id/members/:user_id", controllers.RemoveProjectMember)
+	api.POST("/projects/:id/invitations", controllers.InviteToProject)
+	api.GET("/invitations", controllers.GetMyInvitations)
+	api.POST("/invitations/:id/accept", controllers.AcceptInvitation)
+	api.POST("/invitations/:id/decline", controllers.DeclineInvitation)
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+	api.DELETE("/tasks/:id", controllers.DeleteTask)
+	return r
+}
+
+func countTasks(t *testing.T, r *gin.Engine, userID int64) int {
+	t.Helper()
+	w := doJSON(r, "GET", "/api/tasks", nil, userID)
+	expectStatus(t, w, http.StatusOK)
+	return len(responseData(t, w)["tasks"].([]interface{}))
+}
+
+func TestProjectSharingRoles(t *testing.T) {
+	r := setupMemberRouter()
+	owner := seedUser(t, "owner")
+	editor := seedUser(t, "editor")
+	viewer := seedUser(t, "viewer")
+
+	w := doJSON(r, "POST", "/api/projects", gin.H{"name": "Team"}, owner.ID)
+	expectStatus(t, w, http.StatusCreated)
+	project := int64(responseData(t, w)["id"].(float64))
+
+	// a private task in the owner's inbox and a shared one in Team
+	w = doJSON(r, "POST", "/api/tasks", gin.H{"title": "private"}, owner.ID)
+	expectStatus(t, w, http.StatusCreated)
+	private := int64(responseData(t, w)["id"].(float64))
+	w = doJSON(r, "POST", "/api/tasks", gin.H{"title": "shared", "project_id": project}, owner.ID)
+	expectStatus(t, w, http.StatusCreated)
+	shared := int64(responseData(t, w)["id"].(float64))
+
+	// only owners can invite
+	w = doJSON(r, "POST", fmt.Sprintf("/api/projects/%d/invitations", project), gin.H{"identity": owner.Username}, editor.ID)
+	expectStatus(t, w, http.StatusNotFound)
+
+	invite := func(u string, role string) {
+		w := doJSON(r, "POST", fmt.Sprintf("/api/projects/%d/invitations", project), gin.H{"identity": u, "role": role}, owner.ID)
+		expectStatus(t, w, http.StatusCreated)
+	}
+	invite(editor.Email, "editor")
+	invite(viewer.Username, "viewer")
+
+	// duplicate invitations are rejected
+	w = doJSON(r, "POST", fmt.Sprintf("/api/projects/%d/invitations", project), gin.H{"identity": viewer.Username}, owner.ID)
+	expectStatus(t, w, http.StatusConflict)
+
+	// nothing is visible before accepting
+	if n := countTasks(t, r, editor.ID); n != 0 {
+		t.Fatalf("editor sees %d tasks before accepting", n)
+	}
+
+	accept := func(userID int64) {
+		w := doJSON(r, "GET", "/api/invitations", nil, userID)
+		invs := responseData(t, w)["invitations"].([]interface{})
+		if len(invs) != 1 {
+			t.Fatalf("pending invitations=%d want 1", len(invs))
+		}
+		id := invs[0].(map[string]interface{})["id"]
+		w = doJSON(r, "POST", fmt.Sprintf("/api/invitations/%v/accept", id), nil, userID)
+		expectStatus(t, w, http.StatusOK)
+	}
+	accept(editor.ID)
+	accept(viewer.ID)
+
+	// members see the shared task but never the private one
+	if n := countTasks(t, r, editor.ID); n != 1 {
+		t.Fatalf("editor sees %d tasks want 1", n)
+	}
+	w = doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%d", private), gin.H{"title": "x"}, editor.ID)
+	expectStatus(t, w, http.StatusNotFound)
+
+	// editors can change and add tasks, viewers can't
+	w = doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%d", shared), gin.H{"status": "completed"}, editor.ID)
+	expectStatus(t, w, http.StatusOK)
+	w = doJSON(r, "POST", "/api/tasks", gin.H{"title": "from editor", "project_id": project}, editor.ID)
+	expectStatus(t, w, http.StatusCreated)
+	w = doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%d", shared), gin.H{"status": "pending"}, viewer.ID)
+	expectStatus(t, w, http.StatusForbidden)
+	w = doJSON(r, "DELETE", fmt.Sprintf("/api/tasks/%d", shared), nil, viewer.ID)
+	expectStatus(t, w, http.StatusForbidden)
+	w = doJSON(r, "POST", "/api/tasks", gin.H{"title": "from viewer", "project_id": project}, viewer.ID)
+	expectStatus(t, w, http.StatusForbidden)
+	if n := countTasks(t, r, viewer.ID); n != 2 {
+		t.Fatalf("viewer sees %d tasks want 2", n)
+	}
+
+	// promote the viewer, then remove the editor
+	w = doJSON(r, "PUT", fmt.Sprintf("/api/projects/%d/members/%d", project, viewer.ID), gin.H{"role": "editor"}, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+	w = doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%d", shared), gin.H{"status": "pending"}, viewer.ID)
+	expectStatus(t, w, http.StatusOK)
+
+	w = doJSON(r, "GET", fmt.Sprintf("/api/projects/%d/members", project), nil, viewer.ID)
+	expectStatus(t, w, http.StatusOK)
+	members := responseData(t, w)["members"].([]interface{})
+	if len(members) != 3 {
+		t.Fatalf("members=%d want 3", len(members))
+	}
+	// members only see their own email
+	for _, m := range members {
+		m := m.(map[string]interface{})
+		if _, shown := m["email"]; shown != (m["user_id"] == float64(viewer.ID)) {
+			t.Fatalf("member %v email shown=%v", m["user_id"], shown)
+		}
+	}
+	w = doJSON(r, "GET", fmt.Sprintf("/api/projects/%d/members", project), nil, owner.ID)
+	for _, m := range responseData(t, w)["members"].([]interface{}) {
+		if m.(map[string]interface{})["email"] == nil {
+			t.Fatal("owner should see member emails")
+		}
+	}
+
+	w = doJSON(r, "DELETE", fmt.Sprintf("/api/projects/%d/members/%d", project, editor.ID), nil, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+	if n := countTasks(t, r, editor.ID); n != 0 {
+		t.Fatalf("removed editor still sees %d tasks", n)
+	}
+}
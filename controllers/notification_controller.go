@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// notify stores a notification for n.UserID. Users are never notified about
+// their own actions.
+func notify(tx *gorm.DB, n models.Notification) error {
+	if n.ActorID != nil && *n.ActorID == n.UserID {
+		return nil
+	}
+	return tx.Create(&n).Error
+}
+
+func GetNotifications(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+
+	page, pageSize := pageParams(c)
+
+	q := config.DB.Where("user_id = ?", uid.(int64))
+	if c.Query("unread") == "true" {
+		q = q.Where("read_at IS NULL")
+	}
+	var total int64
+	if err := q.Model(&models.Notification{}).Count(&total).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail count"})
+		return
+	}
+	var notifications []models.Notification
+	if err := q.Order("id desc").Limit(pageSize).Offset((page - 1) * pageSize).Find(&notifications).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"notifications": notifications,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid notification id"})
+		return
+	}
+	var n models.Notification
+	if err := config.DB.Where("id = ? AND user_id = ?", id, uid.(int64)).First(&n).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "notification not found"})
+		return
+	}
+	if n.ReadAt == nil {
+		now := time.Now()
+		n.ReadAt = &now
+		if err := config.DB.Save(&n).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+			return
+		}
+	}
+	helpers.APIResponse(c, http.StatusOK, "Updated", n)
+}
+
+func MarkAllNotificationsRead(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	result := config.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", uid.(int64)).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Updated", gin.H{"updated": result.RowsAffected})
+}
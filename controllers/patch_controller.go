@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// writableTaskFields are the JSON names of taskDocument's fields; a patch
+// may only change these
+var writableTaskFields = map[string]bool{
+	"title": true, "description": true, "status": true, "priority": true,
+	"project_id": true, "due_at": true, "estimate_minutes": true, "tags": true,
+	"recurrence": true, "checklist_auto_complete": true, "custom_fields": true,
+}
+
+// PatchTask changes some of a task's fields. The body is either an RFC 7396
+// merge patch (application/merge-patch+json, or plain application/json)
+// where null clears a field, or an RFC 6902 JSON Patch
+// (application/json-patch+json). Both apply to the task as GET returns it,
+// so a JSON Patch can test read-only fields such as version.
+func PatchTask(c *gin.Context) {
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "fail read body"})
+		return
+	}
+	tasks := []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	doc, err := json.Marshal(tasks[0])
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail encode task"})
+		return
+	}
+
+	var patched []byte
+	switch c.ContentType() {
+	case MergePatchContentType, binding.MIMEJSON:
+		patched, err = helpers.MergePatch(doc, body)
+	case JSONPatchContentType:
+		patched, err = helpers.JSONPatch(doc, body)
+	default:
+		helpers.ErrorResponse(c, http.StatusUnsupportedMediaType, "Unsupported media type",
+			gin.H{"details": "use " + MergePatchContentType + " or " + JSONPatchContentType})
+		return
+	}
+	if errors.Is(err, helpers.ErrPatchTestFailed) {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": err.Error()})
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+
+	var old, changed map[string]interface{}
+	json.Unmarshal(doc, &old)
+	if err := json.Unmarshal(patched, &changed); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "the patched task must be an object"})
+		return
+	}
+	for _, fields := range []map[string]interface{}{old, changed} {
+		for k := range fields {
+			if !writableTaskFields[k] && !reflect.DeepEqual(old[k], changed[k]) {
+				helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fmt.Sprintf("%s can't be changed", k)})
+				return
+			}
+		}
+	}
+	var in taskDocument
+	if err := json.Unmarshal(patched, &in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	replaceTask(c, task, in)
+}
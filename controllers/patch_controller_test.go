@@ -0,0 +1,108 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func TestReplaceAndPatchTask(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.PUT("/tasks/:id", controllers.UpdateTask)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+	api.GET("/tasks/:id", controllers.GetTask)
+
+	u := seedUser(t, "patcher")
+	w := doJSON(r, "POST", "/api/tasks", gin.H{
+		"title": "draft", "description": "notes", "tags": []string{"x"},
+		"due_at": time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC),
+	}, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	task := responseData(t, w)
+	path := fmt.Sprintf("/api/tasks/%v", task["id"])
+	send := func(method, contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Test-User", strconv.FormatInt(u.ID, 10))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// PUT replaces everything: required fields must be given, the rest clear
+	expectStatus(t, doJSON(r, "PUT", path, gin.H{"title": "final"}, u.ID), http.StatusBadRequest)
+	w = doJSON(r, "PUT", path, gin.H{"title": "final", "status": "pending", "priority": "low", "project_id": task["project_id"]}, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	task = responseData(t, w)
+	if task["description"] != "" || task["due_at"] != nil || len(task["tags"].([]interface{})) != 0 {
+		t.Fatalf("PUT left fields behind: %v", task)
+	}
+
+	// merge patch: only the given fields change, null clears
+	w = send("PATCH", "application/merge-patch+json", `{"description": "again", "tags": ["b", "a"], "due_at": null}`)
+	expectStatus(t, w, http.StatusOK)
+	task = responseData(t, w)
+	if task["title"] != "final" || task["description"] != "again" || fmt.Sprint(task["tags"]) != "[a b]" {
+		t.Fatalf("merge patch result %v", task)
+	}
+
+	// JSON Patch, guarded by a test of the version
+	ops := fmt.Sprintf(`[{"op": "test", "path": "/version", "value": %v},
+		{"op": "add", "path": "/tags/-", "value": "c"},
+		{"op": "remove", "path": "/tags/0"},
+		{"op": "replace", "path": "/priority", "value": "high"}]`, task["version"])
+	w = send("PATCH", "application/json-patch+json", ops)
+	expectStatus(t, w, http.StatusOK)
+	task = responseData(t, w)
+	if task["priority"] != "high" || fmt.Sprint(task["tags"]) != "[b c]" {
+		t.Fatalf("json patch result %v", task)
+	}
+	expectStatus(t, send("PATCH", "application/json-patch+json", ops), http.StatusConflict)
+
+	for _, tc := range []struct {
+		contentType, body string
+		code              int
+	}{
+		{"application/json-patch+json", `[{"op": "replace", "path": "/id", "value": 999999}]`, http.StatusBadRequest},
+		{"application/json-patch+json", `[{"op": "remove", "path": "/nope"}]`, http.StatusBadRequest},
+		{"application/json-patch+json", `{"op": "remove", "path": "/title"}`, http.StatusBadRequest},
+		{"application/merge-patch+json", `{"version": 99}`, http.StatusBadRequest},
+		{"application/merge-patch+json", `{"color": "red"}`, http.StatusBadRequest},
+		{"application/merge-patch+json", `{"project_id": null}`, http.StatusBadRequest},
+		{"application/merge-patch+json", `{"title": 7}`, http.StatusBadRequest},
+		{"application/merge-patch+json", `{"status": "done"}`, http.StatusBadRequest},
+		{"text/plain", `title=x`, http.StatusUnsupportedMediaType},
+	} {
+		if w := send("PATCH", tc.contentType, tc.body); w.Code != tc.code {
+			t.Errorf("%s %s: status %d want %d: %s", tc.contentType, tc.body, w.Code, tc.code, w.Body.String())
+		}
+	}
+	// patches apply to the task as GET returns it, computed fields included
+	id := int64(task["id"].(float64))
+	ended := time.Now().UTC()
+	config.DB.Create(&models.TimeEntry{TaskID: id, UserID: u.ID, StartedAt: ended.Add(-30 * time.Minute), EndedAt: &ended, Seconds: 1800})
+	config.DB.Create(&models.ChecklistItem{TaskID: id, Text: "step", Position: 1})
+	w = doJSON(r, "GET", path, nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	task = responseData(t, w)
+	task["title"] = "echoed"
+	body, _ := json.Marshal(task)
+	w = send("PATCH", "application/merge-patch+json", string(body))
+	expectStatus(t, w, http.StatusOK)
+	if task = responseData(t, w); task["title"] != "echoed" || task["tracked_minutes"] != float64(30) {
+		t.Fatalf("echoed patch result %v", task)
+	}
+	expectStatus(t, send("PATCH", "application/json-patch+json", `[{"op": "test", "path": "/tracked_minutes", "value": 30},
+		{"op": "test", "path": "/checklist/total", "value": 1}]`), http.StatusOK)
+}
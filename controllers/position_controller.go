@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/jobs"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// endPosition returns a position after every task in the task's list
+func endPosition(tx *gorm.DB, task models.Task) (string, error) {
+	var last []string
+	if err := tx.Unscoped().Model(&models.Task{}).Scopes(jobs.TaskList(task)).
+		Order("tasks.position desc").Limit(1).Pluck("tasks.position", &last).Error; err != nil {
+		return "", err
+	}
+	if len(last) == 0 {
+		return helpers.RankAfter(""), nil
+	}
+	return helpers.RankAfter(last[0]), nil
+}
+
+// positionBeside returns a position directly before or after the anchor,
+// ignoring the task being moved
+func positionBeside(tx *gorm.DB, task, anchor models.Task, after bool) (string, error) {
+	q := tx.Model(&models.Task{}).Scopes(jobs.TaskList(task)).Where("tasks.id <> ?", task.ID)
+	if after {
+		q = q.Where("(tasks.position > ? OR (tasks.position = ? AND tasks.id > ?))", anchor.Position, anchor.Position, anchor.ID).
+			Order("tasks.position, tasks.id")
+	} else {
+		q = q.Where("(tasks.position < ? OR (tasks.position = ? AND tasks.id < ?))", anchor.Position, anchor.Position, anchor.ID).
+			Order("tasks.position desc, tasks.id desc")
+	}
+	var neighbor []string
+	if err := q.Limit(1).Pluck("tasks.position", &neighbor).Error; err != nil {
+		return "", err
+	}
+	var n string
+	if len(neighbor) > 0 {
+		n = neighbor[0]
+	}
+	if after && n == "" {
+		return helpers.RankAfter(anchor.Position), nil
+	}
+	if after {
+		return helpers.RankBetween(anchor.Position, n)
+	}
+	if anchor.Position == "" {
+		return "", helpers.ErrInvalidRankRange
+	}
+	return helpers.RankBetween(n, anchor.Position)
+}
+
+// placeBeside is positionBeside, rebalancing the list first when the keys
+// around the anchor have run out of room
+func placeBeside(tx *gorm.DB, task, anchor models.Task, after bool) (string, error) {
+	key, err := positionBeside(tx, task, anchor, after)
+	if !errors.Is(err, helpers.ErrInvalidRankRange) {
+		return key, err
+	}
+	// equal or missing keys around the anchor: respace the list
+	if err := jobs.RebalancePositions(tx, task); err != nil {
+		return "", err
+	}
+	if err := tx.First(&anchor, anchor.ID).Error; err != nil {
+		return "", err
+	}
+	return positionBeside(tx, task, anchor, after)
+}
+
+// MoveTask places a task directly before or after another task of the
+// same list. Only the moved task's position changes, unless the keys
+// around the anchor have run out of room and the list gets rebalanced.
+func MoveTask(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	var in struct {
+		Before *int64 `json:"before"`
+		After  *int64 `json:"after"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if (in.Before == nil) == (in.After == nil) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "give either before or after"})
+		return
+	}
+	anchorID, after := in.Before, false
+	if in.After != nil {
+		anchorID, after = in.After, true
+	}
+
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	anchor, err := findTask(config.DB, uid.(int64), *anchorID, false)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "anchor task not found"})
+		return
+	}
+	sameList := anchor.ID != task.ID && (task.ProjectID != nil && anchor.ProjectID != nil && *task.ProjectID == *anchor.ProjectID ||
+		task.ProjectID == nil && anchor.ProjectID == nil && task.UserID == anchor.UserID)
+	if !sameList {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "anchor must be another task in the same list"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		key, err := placeBeside(tx, task, anchor, after)
+		if err != nil {
+			return err
+		}
+		task.Position = key
+		task.Version++
+		return tx.Model(&task).Updates(map[string]interface{}{"position": key, "version": nextVersion}).Error
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail move"})
+		return
+	}
+	tasks := []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	taskResponse(c, http.StatusOK, "Moved", tasks[0])
+}
@@ -0,0 +1,106 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/jobs"
+	"go-todo-app/models"
+)
+
+func manualOrder(t *testing.T, r *gin.Engine, userID int64) string {
+	t.Helper()
+	w := doJSON(r, "GET", "/api/tasks?sort=manual", nil, userID)
+	expectStatus(t, w, http.StatusOK)
+	var titles []string
+	for _, task := range responseData(t, w)["tasks"].([]interface{}) {
+		titles = append(titles, task.(map[string]interface{})["title"].(string))
+	}
+	return strings.Join(titles, ",")
+}
+
+func TestMoveTask(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.POST("/tasks/:id/move", controllers.MoveTask)
+
+	u := seedUser(t, "sorter")
+	ids := map[string]int64{}
+	for _, title := range []string{"a", "b", "c", "d"} {
+		w := doJSON(r, "POST", "/api/tasks", gin.H{"title": title}, u.ID)
+		expectStatus(t, w, http.StatusCreated)
+		ids[title] = int64(responseData(t, w)["id"].(float64))
+	}
+	if got := manualOrder(t, r, u.ID); got != "a,b,c,d" {
+		t.Fatalf("initial order %s", got)
+	}
+
+	move := func(title, where, anchor string) {
+		t.Helper()
+		w := doJSON(r, "POST", fmt.Sprintf("/api/tasks/%d/move", ids[title]), gin.H{where: ids[anchor]}, u.ID)
+		expectStatus(t, w, http.StatusOK)
+	}
+	move("d", "before", "a")
+	move("a", "after", "c")
+	if got := manualOrder(t, r, u.ID); got != "d,b,c,a" {
+		t.Fatalf("order after moves %s", got)
+	}
+
+	// repeatedly squeezing into the same gap grows the keys until the job
+	// respaces the list without changing the order
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			move("c", "after", "d")
+		} else {
+			move("b", "after", "d")
+		}
+	}
+	var longest int
+	config.DB.Model(&models.Task{}).Select("MAX(LENGTH(position))").Where("user_id = ?", u.ID).Scan(&longest)
+	if longest <= jobs.MaxPositionLength {
+		t.Fatalf("expected long keys, longest is %d", longest)
+	}
+	before := manualOrder(t, r, u.ID)
+	if _, err := jobs.RebalanceLongPositions(); err != nil {
+		t.Fatal(err)
+	}
+	config.DB.Model(&models.Task{}).Select("MAX(LENGTH(position))").Where("user_id = ?", u.ID).Scan(&longest)
+	if longest > 2 {
+		t.Fatalf("keys still %d long after rebalancing", longest)
+	}
+	if got := manualOrder(t, r, u.ID); got != before {
+		t.Fatalf("rebalancing changed the order: %s, was %s", got, before)
+	}
+
+	w := doJSON(r, "POST", fmt.Sprintf("/api/tasks/%d/move", ids["a"]), gin.H{"before": ids["a"]}, u.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+	w = doJSON(r, "GET", "/api/tasks?sort=random", nil, u.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestAppendPositionsStayShort(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.DELETE("/tasks/:id", controllers.DeleteTask)
+
+	u := seedUser(t, "appender")
+	for i := 0; i < 400; i++ {
+		w := doJSON(r, "POST", "/api/tasks", gin.H{"title": fmt.Sprint("task ", i)}, u.ID)
+		expectStatus(t, w, http.StatusCreated)
+		// trashed tasks keep their place in the list
+		if i%10 == 0 {
+			doJSON(r, "DELETE", fmt.Sprintf("/api/tasks/%v", responseData(t, w)["id"]), nil, u.ID)
+		}
+	}
+	var longest int
+	config.DB.Unscoped().Model(&models.Task{}).Select("MAX(LENGTH(position))").Where("user_id = ?", u.ID).Scan(&longest)
+	if longest > jobs.MaxPositionLength {
+		t.Fatalf("positions grew to %d characters", longest)
+	}
+}
@@ -0,0 +1,313 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// ensureInbox returns the user's Inbox project, creating it when missing
+// (users registered before projects existed don't have one yet).
+func ensureInbox(tx *gorm.DB, userID int64) (models.Project, error) {
+	var inbox models.Project
+	err := tx.Where("user_id = ? AND is_inbox = ?", userID, true).First(&inbox).Error
+	if err == nil {
+		return inbox, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return inbox, err
+	}
+	inbox = models.Project{
+		UserID:          userID,
+		Name:            models.InboxProjectName,
+		DefaultPriority: models.TaskPriorityMedium,
+		IsInbox:         true,
+	}
+	err = tx.Create(&inbox).Error
+	return inbox, err
+}
+
+func parseProjectID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid project id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func GetProjects(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	if _, err := ensureInbox(config.DB, uid.(int64)); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create inbox"})
+		return
+	}
+
+	q := config.DB.Where("user_id = ? OR id IN (SELECT project_id FROM project_members WHERE user_id = ?)", uid.(int64), uid.(int64))
+	if c.Query("include_archived") != "true" {
+		q = q.Where("archived_at IS NULL")
+	}
+	var projects []models.Project
+	if err := q.Order("is_inbox desc, id asc").Find(&projects).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	for i := range projects {
+		role, err := projectRole(config.DB, uid.(int64), projects[i])
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		projects[i].Role = role
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"projects": projects})
+}
+
+func CreateProject(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var in struct {
+		Name            string `json:"name" binding:"required,min=1,max=100"`
+		Description     string `json:"description"`
+		Color           string `json:"color"`
+		DefaultPriority string `json:"default_priority"` // low|medium|high
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "name is required"})
+		return
+	}
+	if in.Color != "" && !helpers.IsValidHexColor(in.Color) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "color must be #RRGGBB"})
+		return
+	}
+	p := strings.ToLower(strings.TrimSpace(in.DefaultPriority))
+	if p == "" {
+		p = models.TaskPriorityMedium
+	}
+	if !models.IsValidPriority(p) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "default_priority must be low|medium|high"})
+		return
+	}
+	project := models.Project{
+		UserID:          uid.(int64),
+		Name:            name,
+		Description:     strings.TrimSpace(in.Description),
+		Color:           in.Color,
+		DefaultPriority: p,
+	}
+	if err := config.DB.Create(&project).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create project"})
+		return
+	}
+	project.Role = models.ProjectRoleOwner
+	helpers.APIResponse(c, http.StatusCreated, "Project created", project)
+}
+
+func GetProject(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, role, ok := requireProject(c, uid.(int64), id, "")
+	if !ok {
+		return
+	}
+	project.Role = role
+	helpers.APIResponse(c, http.StatusOK, "OK", project)
+}
+
+func UpdateProject(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	var in struct {
+		Name            *string `json:"name"`
+		Description     *string `json:"description"`
+		Color           *string `json:"color"`
+		DefaultPriority *string `json:"default_priority"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" || len(name) > 100 {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "name must be 1-100 characters"})
+			return
+		}
+		if project.IsInbox && name != project.Name {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "inbox cannot be renamed"})
+			return
+		}
+		project.Name = name
+	}
+	if in.Description != nil {
+		project.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Color != nil {
+		if *in.Color != "" && !helpers.IsValidHexColor(*in.Color) {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "color must be #RRGGBB"})
+			return
+		}
+		project.Color = *in.Color
+	}
+	if in.DefaultPriority != nil {
+		p := strings.ToLower(strings.TrimSpace(*in.DefaultPriority))
+		if !models.IsValidPriority(p) {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "default_priority must be low|medium|high"})
+			return
+		}
+		project.DefaultPriority = p
+	}
+	if err := config.DB.Save(&project).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	project.Role = models.ProjectRoleOwner
+	helpers.APIResponse(c, http.StatusOK, "Updated", project)
+}
+
+func ArchiveProject(c *gin.Context) {
+	setProjectArchived(c, true)
+}
+
+func UnarchiveProject(c *gin.Context) {
+	setProjectArchived(c, false)
+}
+
+func setProjectArchived(c *gin.Context, archived bool) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	if project.IsInbox {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "inbox cannot be archived"})
+		return
+	}
+	if archived {
+		now := time.Now()
+		project.ArchivedAt = &now
+	} else {
+		project.ArchivedAt = nil
+	}
+	if err := config.DB.Save(&project).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	msg := "Unarchived"
+	if archived {
+		msg = "Archived"
+	}
+	project.Role = models.ProjectRoleOwner
+	helpers.APIResponse(c, http.StatusOK, msg, project)
+}
+
+// DeleteProject removes a project along with its memberships and moves each
+// task to its creator's Inbox
+func DeleteProject(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	if project.IsInbox {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "inbox cannot be deleted"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// trashed tasks move too, so a restore doesn't land in a missing project
+		var creators []int64
+		if err := tx.Unscoped().Model(&models.Task{}).Where("project_id = ?", project.ID).
+			Distinct().Pluck("user_id", &creators).Error; err != nil {
+			return err
+		}
+		for _, creator := range creators {
+			inbox, err := ensureInbox(tx, creator)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Task{}).Where("project_id = ? AND user_id = ?", project.ID, creator).
+				Updates(map[string]interface{}{"project_id": inbox.ID, "version": nextVersion}).Error; err != nil {
+				return err
+			}
+			// statuses the inbox doesn't have fall back to its first of
+			// the same category
+			workflow, _, err := projectWorkflow(tx, inbox)
+			if err != nil {
+				return err
+			}
+			if err := fitTasksToWorkflow(tx, projectTasks(inbox.ID), workflow, nil); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.TaskStatus{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("field_id IN (SELECT id FROM custom_fields WHERE project_id = ?)", project.ID).
+			Delete(&models.TaskFieldValue{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.CustomField{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.BoardLimit{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.ProjectMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.ProjectInvitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&project).Error
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Deleted", gin.H{"id": project.ID})
+}
+
+// GetProjectTasks lists the tasks of a single project with the same
+// pagination and filters as GetTasks
+func GetProjectTasks(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	if _, _, ok := requireProject(c, uid.(int64), id, ""); !ok {
+		return
+	}
+	listTasks(c, config.DB.Where("tasks.project_id = ?", id))
+}
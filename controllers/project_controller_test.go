@@ -0,0 +1,91 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func setupProjectRouter() *gin.Engine {
+	r, api := newTestAPI()
+	api.GET("/projects", controllers.GetProjects)
+	api.POST("/projects", controllers.CreateProject)
+	api.DELETE("/projects/:id", controllers.DeleteProject)
+	api.POST("/projects/:id/archive", controllers.ArchiveProject)
+	api.GET("/projects/:id/tasks", controllers.GetProjectTasks)
+	api.POST("/tasks", controllers.CreateTask)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+	return r
+}
+
+func TestProjectsAndInbox(t *testing.T) {
+	r := setupProjectRouter()
+	u := seedUser(t, "proj")
+
+	// tasks without a project land in the inbox
+	w := doJSON(r, "POST", "/api/tasks", gin.H{"title": "loose"}, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	task := responseData(t, w)
+
+	var inbox models.Project
+	if err := config.DB.Where("user_id = ? AND is_inbox = ?", u.ID, true).First(&inbox).Error; err != nil {
+		t.Fatalf("inbox not created: %v", err)
+	}
+	if int64(task["project_id"].(float64)) != inbox.ID {
+		t.Fatalf("task project_id=%v want inbox %d", task["project_id"], inbox.ID)
+	}
+
+	// project default priority applies to new tasks
+	w = doJSON(r, "POST", "/api/projects", gin.H{"name": "Work", "default_priority": "high"}, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	work := int64(responseData(t, w)["id"].(float64))
+
+	w = doJSON(r, "POST", "/api/tasks", gin.H{"title": "report", "project_id": work}, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	if p := responseData(t, w)["priority"]; p != "high" {
+		t.Fatalf("priority=%v want high", p)
+	}
+
+	// move the inbox task into Work
+	w = doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%v", task["id"]), gin.H{"project_id": work}, u.ID)
+	expectStatus(t, w, http.StatusOK)
+
+	w = doJSON(r, "GET", fmt.Sprintf("/api/projects/%d/tasks", work), nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 2 {
+		t.Fatalf("project tasks=%d want 2", n)
+	}
+
+	// archived projects are hidden and reject new tasks
+	w = doJSON(r, "POST", fmt.Sprintf("/api/projects/%d/archive", work), nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	w = doJSON(r, "GET", "/api/projects", nil, u.ID)
+	if n := len(responseData(t, w)["projects"].([]interface{})); n != 1 {
+		t.Fatalf("visible projects=%d want 1", n)
+	}
+	w = doJSON(r, "POST", "/api/tasks", gin.H{"title": "late", "project_id": work}, u.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	// deleting a project moves its tasks back to the inbox
+	w = doJSON(r, "DELETE", fmt.Sprintf("/api/projects/%d", work), nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	var n int64
+	config.DB.Model(&models.Task{}).Where("project_id = ?", inbox.ID).Count(&n)
+	if n != 2 {
+		t.Fatalf("inbox tasks=%d want 2", n)
+	}
+
+	// the inbox itself cannot be deleted
+	w = doJSON(r, "DELETE", fmt.Sprintf("/api/projects/%d", inbox.ID), nil, u.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	// other users cannot see the project
+	other := seedUser(t, "other")
+	w = doJSON(r, "GET", fmt.Sprintf("/api/projects/%d/tasks", inbox.ID), nil, other.ID)
+	expectStatus(t, w, http.StatusNotFound)
+}
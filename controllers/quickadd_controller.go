@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"go-todo-app/quickadd"
+	"gorm.io/gorm"
+)
+
+// projectByName finds a project the user can see by its name, ignoring
+// case; underscores stand for spaces. The user's own projects come first.
+func projectByName(tx *gorm.DB, userID int64, name string) (*int64, error) {
+	name = strings.ToLower(name)
+	var projects []models.Project
+	if err := tx.Where("user_id = ? OR id IN (SELECT project_id FROM project_members WHERE user_id = ?)", userID, userID).
+		Where("LOWER(name) IN ?", []string{name, strings.ReplaceAll(name, "_", " ")}).
+		Order("archived_at IS NULL desc, id asc").Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 {
+		return nil, nil
+	}
+	for _, p := range projects {
+		if p.UserID == userID {
+			return &p.ID, nil
+		}
+	}
+	return &projects[0].ID, nil
+}
+
+// QuickAddTask creates a task from one line of text, e.g.
+//
+//	Pay rent every 1st !high #finance @home due friday 9am
+//
+// See package quickadd for the syntax. Dates are read in the user's time
+// zone. The response holds the task and the parse, whose tokens let
+// clients highlight what each part of the line was read as.
+func QuickAddTask(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var in struct {
+		Text      string `json:"text" binding:"required"`
+		ProjectID *int64 `json:"project_id"` // used when the text names no @project
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	loc, err := userLocation(config.DB, uid.(int64))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	parsed, err := quickadd.Parse(strings.TrimSpace(in.Text), time.Now().In(loc))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if len(parsed.Title) > 255 {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "title can be at most 255 characters"})
+		return
+	}
+	tags, err := normalizeTags(parsed.Tags)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	projectID := in.ProjectID
+	if parsed.Project != "" {
+		if projectID, err = projectByName(config.DB, uid.(int64), parsed.Project); err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if projectID == nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fmt.Sprintf("no project named %q", parsed.Project)})
+			return
+		}
+	}
+	project, err := resolveTaskProject(config.DB, uid.(int64), projectID)
+	if err != nil {
+		projectErrorResponse(c, err)
+		return
+	}
+	workflow, _, err := projectWorkflow(config.DB, project)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	status := firstInCategory(workflow, models.StatusCategoryTodo)
+	task := models.Task{
+		UserID:         uid.(int64),
+		ProjectID:      &project.ID,
+		Title:          parsed.Title,
+		Priority:       parsed.Priority,
+		Status:         status.Key,
+		StatusCategory: status.Category,
+		Recurrence:     parsed.Recurrence,
+		Version:        1,
+		Tags:           tags,
+	}
+	if task.Priority == "" {
+		task.Priority = project.DefaultPriority
+	}
+	if parsed.DueAt != nil {
+		due := parsed.DueAt.UTC()
+		task.DueAt = &due
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		position, err := endPosition(tx, task)
+		if err != nil {
+			return err
+		}
+		task.Position = position
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		if err := setTaskTags(tx, task.ID, tags); err != nil {
+			return err
+		}
+		return recordTaskActivity(tx, c, nil, &task)
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create task"})
+		return
+	}
+	c.Header("ETag", taskETag(task))
+	helpers.APIResponse(c, http.StatusCreated, "Task created", gin.H{"task": task, "parsed": parsed})
+}
@@ -0,0 +1,109 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func TestQuickAdd(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks/quick", controllers.QuickAddTask)
+	api.PUT("/me/time-zone", controllers.SetTimeZone)
+
+	user := seedUser(t, "quick")
+	home := models.Project{UserID: user.ID, Name: "Home", DefaultPriority: models.TaskPriorityLow}
+	config.DB.Create(&home)
+	expectStatus(t, doJSON(r, "PUT", "/api/me/time-zone", gin.H{"time_zone": "Mars/Olympus"}, user.ID), http.StatusBadRequest)
+	expectStatus(t, doJSON(r, "PUT", "/api/me/time-zone", gin.H{"time_zone": "Asia/Tokyo"}, user.ID), http.StatusOK)
+
+	w := doJSON(r, "POST", "/api/tasks/quick", gin.H{"text": "Pay rent every 1st !high #Finance @home due friday 9am"}, user.ID)
+	expectStatus(t, w, http.StatusCreated)
+	data := responseData(t, w)
+	task := data["task"].(map[string]interface{})
+	if task["title"] != "Pay rent" || task["priority"] != "high" || task["project_id"] != float64(home.ID) ||
+		task["recurrence"] != "monthly:1" || fmt.Sprint(task["tags"]) != "[finance]" {
+		t.Fatalf("task=%v", task)
+	}
+	// 9am in Tokyo on a Friday
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	due, _ := time.Parse(time.RFC3339, task["due_at"].(string))
+	if due = due.In(tokyo); due.Weekday() != time.Friday || due.Hour() != 9 || !due.After(time.Now()) {
+		t.Fatalf("due_at=%s", due)
+	}
+	var kinds []string
+	for _, tok := range data["parsed"].(map[string]interface{})["tokens"].([]interface{}) {
+		kinds = append(kinds, tok.(map[string]interface{})["kind"].(string))
+	}
+	if got := strings.Join(kinds, ","); got != "title,recurrence,priority,tag,project,due" {
+		t.Fatalf("tokens=%s", got)
+	}
+
+	// without a priority the project's default applies
+	w = doJSON(r, "POST", "/api/tasks/quick", gin.H{"text": "Fix sink", "project_id": home.ID}, user.ID)
+	if task := responseData(t, w)["task"].(map[string]interface{}); task["priority"] != "low" || task["due_at"] != nil {
+		t.Fatalf("task=%v", task)
+	}
+	for _, text := range []string{"Plan @garage", "#only #tags", "x !high @home @work"} {
+		if w := doJSON(r, "POST", "/api/tasks/quick", gin.H{"text": text}, user.ID); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d want 400", text, w.Code)
+		}
+	}
+}
+
+func TestRecurringTasks(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+
+	user := seedUser(t, "repeat")
+	project := models.Project{UserID: user.ID, Name: "Chores"}
+	config.DB.Create(&project)
+	expectStatus(t, doJSON(r, "POST", "/api/tasks", gin.H{"title": "x", "recurrence": "fortnightly"}, user.ID), http.StatusBadRequest)
+
+	w := doJSON(r, "POST", "/api/tasks", gin.H{
+		"title": "Water plants", "project_id": project.ID, "tags": []string{"garden"},
+		"recurrence": "Weekly/2", "due_at": "2026-01-05T08:00:00Z", "checklist_items": []string{"fertilize"},
+	}, user.ID)
+	expectStatus(t, w, http.StatusCreated)
+	first := responseData(t, w)
+	if first["recurrence"] != "weekly/2" {
+		t.Fatalf("recurrence=%v", first["recurrence"])
+	}
+
+	// finishing it creates the next occurrence that is still ahead
+	w = doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%v", first["id"]), gin.H{"status": "completed"}, user.ID)
+	expectStatus(t, w, http.StatusOK)
+	if done := responseData(t, w); done["recurrence"] != "" {
+		t.Fatalf("finished task keeps recurrence %v", done["recurrence"])
+	}
+	w = doJSON(r, "GET", fmt.Sprintf("/api/tasks?project_id=%d&status=pending", project.ID), nil, user.ID)
+	tasks := responseData(t, w)["tasks"].([]interface{})
+	if len(tasks) != 1 {
+		t.Fatalf("open tasks=%v", tasks)
+	}
+	next := tasks[0].(map[string]interface{})
+	due, _ := time.Parse(time.RFC3339, next["due_at"].(string))
+	if next["title"] != "Water plants" || next["recurrence"] != "weekly/2" || fmt.Sprint(next["tags"]) != "[garden]" ||
+		fmt.Sprint(next["checklist"]) != "map[done:0 total:1]" ||
+		!due.After(time.Now()) || due.Weekday() != time.Monday || due.Hour() != 8 || int(due.Sub(time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)).Hours())%(14*24) != 0 {
+		t.Fatalf("next=%v", next)
+	}
+
+	// reopening and finishing again doesn't repeat twice
+	id := fmt.Sprintf("/api/tasks/%v", first["id"])
+	expectStatus(t, doJSON(r, "PATCH", id, gin.H{"status": "pending"}, user.ID), http.StatusOK)
+	expectStatus(t, doJSON(r, "PATCH", id, gin.H{"status": "completed"}, user.ID), http.StatusOK)
+	w = doJSON(r, "GET", fmt.Sprintf("/api/tasks?project_id=%d", project.ID), nil, user.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 2 {
+		t.Fatalf("tasks=%d", n)
+	}
+}
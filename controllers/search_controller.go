@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// Highlights come back from the database wrapped in these control
+// characters and are turned into <mark> tags after the text is escaped
+const (
+	markStart = "\x02"
+	markEnd   = "\x03"
+)
+
+type searchResult struct {
+	Task    models.Task `json:"task"`
+	Rank    float64     `json:"rank"`
+	Title   string      `json:"title_highlight"`
+	Snippet string      `json:"snippet"`
+}
+
+// searchRow is what the search query scans into
+type searchRow struct {
+	models.Task
+	SearchRank     float64
+	TitleHighlight string
+	Snippet        string
+}
+
+// highlight HTML-escapes text and turns the match markers into <mark> tags
+func highlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, markStart, "<mark>")
+	return strings.ReplaceAll(s, markEnd, "</mark>")
+}
+
+// matchTasks restricts q to tasks matching the search terms on the
+// current database
+func matchTasks(q *gorm.DB, terms []helpers.SearchTerm) *gorm.DB {
+	if config.DB.Dialector.Name() == "postgres" {
+		return q.Joins("CROSS JOIN to_tsquery('english', ?) AS query", helpers.TSQuery(terms)).
+			Where("tasks.search_vector @@ query")
+	}
+	return q.Joins("JOIN tasks_fts ON tasks_fts.rowid = tasks.id").
+		Where("tasks_fts MATCH ?", helpers.FTS5Query(terms))
+}
+
+// selectMatches adds the rank and highlights to a matchTasks query
+func selectMatches(q *gorm.DB) *gorm.DB {
+	if config.DB.Dialector.Name() == "postgres" {
+		opts := "StartSel=" + markStart + ", StopSel=" + markEnd
+		return q.Select("tasks.*, ts_rank(tasks.search_vector, query) AS search_rank," +
+			" ts_headline('english', tasks.title, query, 'HighlightAll=true, " + opts + "') AS title_highlight," +
+			" ts_headline('english', coalesce(tasks.description, ''), query, 'MaxWords=24, MinWords=8, " + opts + "') AS snippet")
+	}
+	// bm25 is lower for better matches; title hits weigh more
+	return q.Select("tasks.*, -bm25(tasks_fts, 10.0, 1.0) AS search_rank,"+
+		" highlight(tasks_fts, 0, ?, ?) AS title_highlight,"+
+		" snippet(tasks_fts, 1, ?, ?, '…', 16) AS snippet",
+		markStart, markEnd, markStart, markEnd)
+}
+
+// SearchTasks finds visible tasks whose title or description match q, best
+// matches first. Quoted phrases, prefix* terms and the task list filters
+// are supported.
+func SearchTasks(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	if !config.SearchEnabled {
+		helpers.ErrorResponse(c, http.StatusNotImplemented, "Not implemented", gin.H{"details": "search is not available"})
+		return
+	}
+	terms := helpers.ParseSearchQuery(c.Query("q"))
+	if len(terms) == 0 {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "q must contain at least one word"})
+		return
+	}
+	q := config.DB.Model(&models.Task{}).Scopes(visibleTasks(uid.(int64)))
+	f, err := queryTaskFilter(c)
+	if err == nil {
+		q, err = f.apply(q, uid.(int64))
+	}
+	if err != nil {
+		filterErrorResponse(c, err)
+		return
+	}
+	q = matchTasks(q, terms).Session(&gorm.Session{})
+
+	page, pageSize := pageParams(c)
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail count"})
+		return
+	}
+	var rows []searchRow
+	if err := selectMatches(q).Order("search_rank desc, tasks.id desc").Limit(pageSize).Offset((page - 1) * pageSize).
+		Scan(&rows).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+
+	tasks := make([]models.Task, len(rows))
+	for i, r := range rows {
+		tasks[i] = r.Task
+	}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	results := make([]searchResult, len(rows))
+	for i, r := range rows {
+		results[i] = searchResult{
+			Task:    tasks[i],
+			Rank:    r.SearchRank,
+			Title:   highlight(r.TitleHighlight),
+			Snippet: highlight(r.Snippet),
+		}
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"results": results,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
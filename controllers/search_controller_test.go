@@ -0,0 +1,76 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/internal/testutil"
+)
+
+func TestSearchTasks(t *testing.T) {
+	r, api := newTestAPI()
+	if !config.SearchEnabled {
+		if testutil.FTS5 {
+			t.Fatal("built with sqlite_fts5 but search is disabled")
+		}
+		t.Skip("SQLite without FTS5; run with -tags sqlite_fts5")
+	}
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks/search", controllers.SearchTasks)
+
+	u := seedUser(t, "seeker")
+	other := seedUser(t, "hider")
+	for _, in := range []gin.H{
+		{"title": "Quarterly report", "description": "Draft the <b>numbers</b> for finance", "priority": "high"},
+		{"title": "Book flights", "description": "The report on travel costs is due"},
+		{"title": "Report card", "description": "sign it", "priority": "low"},
+	} {
+		expectStatus(t, doJSON(r, "POST", "/api/tasks", in, u.ID), http.StatusCreated)
+	}
+	doJSON(r, "POST", "/api/tasks", gin.H{"title": "Secret report"}, other.ID)
+
+	search := func(query string, userID int64) []map[string]interface{} {
+		t.Helper()
+		w := doJSON(r, "GET", "/api/tasks/search?"+query, nil, userID)
+		expectStatus(t, w, http.StatusOK)
+		var out []map[string]interface{}
+		for _, res := range responseData(t, w)["results"].([]interface{}) {
+			out = append(out, res.(map[string]interface{}))
+		}
+		return out
+	}
+	title := func(res map[string]interface{}) string {
+		return res["task"].(map[string]interface{})["title"].(string)
+	}
+
+	// title matches rank above description matches; other users' tasks stay hidden
+	results := search("q=report", u.ID)
+	if len(results) != 3 || title(results[2]) != "Book flights" {
+		t.Fatalf("results=%v", results)
+	}
+	if results[0]["title_highlight"] != "<mark>Report</mark> card" && results[0]["title_highlight"] != "Quarterly <mark>report</mark>" {
+		t.Fatalf("title_highlight=%v", results[0]["title_highlight"])
+	}
+
+	results = search("q="+url.QueryEscape(`"quarterly report"`), u.ID)
+	if len(results) != 1 {
+		t.Fatalf("phrase matched %d tasks", len(results))
+	}
+	// snippets are escaped before highlighting
+	if s := results[0]["snippet"].(string); !strings.Contains(s, "&lt;b&gt;") {
+		t.Fatalf("snippet not escaped: %s", s)
+	}
+
+	if n := len(search("q=fin*", u.ID)); n != 1 {
+		t.Fatalf("prefix matched %d tasks", n)
+	}
+	if n := len(search("q=report&priority=low", u.ID)); n != 1 {
+		t.Fatalf("filtered search matched %d tasks", n)
+	}
+	expectStatus(t, doJSON(r, "GET", "/api/tasks/search?q=%22%22", nil, u.ID), http.StatusBadRequest)
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// MaxSnoozeDays caps how far ahead a task can be snoozed
+const MaxSnoozeDays = 365
+
+// setTaskFlags stores the changed star, pin or snooze columns of task,
+// logs them against before and writes the task. Setting a flag to the
+// value it has is a no-op.
+func setTaskFlags(c *gin.Context, before, task models.Task, changes map[string]interface{}) {
+	if len(changes) > 0 {
+		changes["version"] = nextVersion
+		task.Version++
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&task).Updates(changes).Error; err != nil {
+				return err
+			}
+			return recordTaskActivity(tx, c, &before, &task)
+		})
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+			return
+		}
+	}
+	tasks := []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	taskResponse(c, http.StatusOK, "Updated", tasks[0])
+}
+
+// setTaskFlag sets the task's starred or pinned flag
+func setTaskFlag(c *gin.Context, column string, value bool) {
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	before := task
+	flag := &task.Starred
+	if column == "pinned" {
+		flag = &task.Pinned
+	}
+	changes := map[string]interface{}{}
+	if *flag != value {
+		changes[column] = value
+		*flag = value
+	}
+	setTaskFlags(c, before, task, changes)
+}
+
+// StarTask marks a task as important; list them with starred=true
+func StarTask(c *gin.Context) { setTaskFlag(c, "starred", true) }
+
+func UnstarTask(c *gin.Context) { setTaskFlag(c, "starred", false) }
+
+// PinTask keeps a task at the top of the task lists
+func PinTask(c *gin.Context) { setTaskFlag(c, "pinned", true) }
+
+func UnpinTask(c *gin.Context) { setTaskFlag(c, "pinned", false) }
+
+// parseSnoozeUntil reads an RFC 3339 time, or a date meaning the start of
+// that day in the user's time zone
+func parseSnoozeUntil(value string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	return t, err == nil
+}
+
+// SnoozeTask hides a task from the task lists until the given time. When
+// it passes the task shows up again and its assignee, or its owner, is
+// notified.
+func SnoozeTask(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		Until string `json:"until" binding:"required"` // RFC 3339 or YYYY-MM-DD
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	loc, err := userLocation(config.DB, uid.(int64))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	until, ok := parseSnoozeUntil(strings.TrimSpace(in.Until), loc)
+	if !ok {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "until must be an RFC 3339 time or a YYYY-MM-DD date"})
+		return
+	}
+	now := time.Now()
+	switch {
+	case !until.After(now):
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "until must be in the future"})
+		return
+	case until.After(now.AddDate(0, 0, MaxSnoozeDays)):
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "a task can be snoozed for at most 365 days"})
+		return
+	case task.StatusCategory == models.StatusCategoryDone:
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "finished tasks cannot be snoozed"})
+		return
+	}
+	until = until.UTC()
+	before := task
+	task.SnoozedUntil = &until
+	setTaskFlags(c, before, task, map[string]interface{}{"snoozed_until": until})
+}
+
+// UnsnoozeTask brings a snoozed task back right away, without a
+// notification
+func UnsnoozeTask(c *gin.Context) {
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	before := task
+	changes := map[string]interface{}{}
+	if task.SnoozedUntil != nil {
+		changes["snoozed_until"] = nil
+		task.SnoozedUntil = nil
+	}
+	setTaskFlags(c, before, task, changes)
+}
@@ -0,0 +1,156 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/jobs"
+	"go-todo-app/models"
+)
+
+func TestStarPinSnooze(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.PUT("/tasks/:id/star", controllers.StarTask)
+	api.DELETE("/tasks/:id/star", controllers.UnstarTask)
+	api.PUT("/tasks/:id/pin", controllers.PinTask)
+	api.DELETE("/tasks/:id/pin", controllers.UnpinTask)
+	api.PUT("/tasks/:id/snooze", controllers.SnoozeTask)
+	api.DELETE("/tasks/:id/snooze", controllers.UnsnoozeTask)
+	api.GET("/tasks/:id/history", controllers.GetTaskHistory)
+
+	user := seedUser(t, "sleeper")
+	viewer := seedUser(t, "onlooker")
+	project := models.Project{UserID: user.ID, Name: "Home"}
+	config.DB.Create(&project)
+	config.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: viewer.ID, Role: models.ProjectRoleViewer})
+
+	var paths []string
+	for _, title := range []string{"Laundry", "Taxes", "Plants"} {
+		w := doJSON(r, "POST", "/api/tasks", gin.H{"title": title, "project_id": project.ID}, user.ID)
+		expectStatus(t, w, http.StatusCreated)
+		paths = append(paths, fmt.Sprintf("/api/tasks/%v", responseData(t, w)["id"]))
+	}
+	titles := func(query string) string {
+		t.Helper()
+		w := doJSON(r, "GET", "/api/tasks"+query, nil, user.ID)
+		expectStatus(t, w, http.StatusOK)
+		var out []string
+		for _, task := range responseData(t, w)["tasks"].([]interface{}) {
+			out = append(out, task.(map[string]interface{})["title"].(string))
+		}
+		return strings.Join(out, ",")
+	}
+	if got := titles(""); got != "Plants,Taxes,Laundry" {
+		t.Fatalf("tasks=%s", got)
+	}
+
+	// pinned tasks come first, whatever the sort
+	w := doJSON(r, "PUT", paths[0]+"/pin", nil, user.ID)
+	expectStatus(t, w, http.StatusOK)
+	if task := responseData(t, w); task["pinned"] != true || task["version"] != float64(2) {
+		t.Fatalf("pin=%v", task)
+	}
+	expectStatus(t, doJSON(r, "PUT", paths[0]+"/pin", nil, viewer.ID), http.StatusForbidden)
+	if got := titles(""); got != "Laundry,Plants,Taxes" {
+		t.Fatalf("pinned first=%s", got)
+	}
+	if got := titles("?sort=title"); got != "Laundry,Plants,Taxes" {
+		t.Fatalf("pinned first by title=%s", got)
+	}
+	w = doJSON(r, "GET", "/api/tasks?page_size=2&cursor=", nil, user.ID)
+	next := responseData(t, w)["pagination"].(map[string]interface{})["next_cursor"].(string)
+	if got := titles("?page_size=2&cursor=" + next); got != "Taxes" {
+		t.Fatalf("second page=%s", got)
+	}
+
+	// starring is idempotent
+	expectStatus(t, doJSON(r, "PUT", paths[1]+"/star", nil, user.ID), http.StatusOK)
+	w = doJSON(r, "PUT", paths[1]+"/star", nil, user.ID)
+	if task := responseData(t, w); task["starred"] != true || task["version"] != float64(2) {
+		t.Fatalf("star=%v", task)
+	}
+	if got := titles("?starred=true"); got != "Taxes" {
+		t.Fatalf("starred=%s", got)
+	}
+	expectStatus(t, doJSON(r, "DELETE", paths[1]+"/star", nil, user.ID), http.StatusOK)
+	if got := titles("?starred=true"); got != "" {
+		t.Fatalf("unstarred=%s", got)
+	}
+	expectStatus(t, doJSON(r, "GET", "/api/tasks?starred=maybe", nil, user.ID), http.StatusBadRequest)
+
+	// snoozed tasks are hidden until they wake
+	for _, until := range []string{"", "soon", "2020-01-01", time.Now().AddDate(2, 0, 0).Format(time.RFC3339)} {
+		expectStatus(t, doJSON(r, "PUT", paths[2]+"/snooze", gin.H{"until": until}, user.ID), http.StatusBadRequest)
+	}
+	w = doJSON(r, "PUT", paths[2]+"/snooze", gin.H{"until": time.Now().AddDate(0, 0, 7).Format("2006-01-02")}, user.ID)
+	expectStatus(t, w, http.StatusOK)
+	if responseData(t, w)["snoozed_until"] == nil {
+		t.Fatal("snoozed_until not set")
+	}
+	if got := titles(""); got != "Laundry,Taxes" {
+		t.Fatalf("snoozed hidden=%s", got)
+	}
+	if got := titles("?include_snoozed=true"); got != "Laundry,Plants,Taxes" {
+		t.Fatalf("include_snoozed=%s", got)
+	}
+	if got := titles("?snoozed=true"); got != "Plants" {
+		t.Fatalf("snoozed=%s", got)
+	}
+	expectStatus(t, doJSON(r, "DELETE", paths[2]+"/snooze", nil, user.ID), http.StatusOK)
+	if got := titles(""); got != "Laundry,Plants,Taxes" {
+		t.Fatalf("unsnoozed=%s", got)
+	}
+
+	// flag changes show up in the history, repeated ones only once
+	history := func(path string) string {
+		t.Helper()
+		w := doJSON(r, "GET", path+"/history", nil, user.ID)
+		expectStatus(t, w, http.StatusOK)
+		var out []string
+		for _, e := range responseData(t, w)["activity"].([]interface{}) {
+			e := e.(map[string]interface{})
+			if e["action"] == "updated" {
+				out = append(out, fmt.Sprintf("%v:%v", e["field"], e["new_value"]))
+			}
+		}
+		return strings.Join(out, ",")
+	}
+	if got := history(paths[0]); got != "pinned:true" {
+		t.Fatalf("pin history=%s", got)
+	}
+	if got := history(paths[1]); got != "starred:false,starred:true" {
+		t.Fatalf("star history=%s", got)
+	}
+	if got := history(paths[2]); !strings.HasPrefix(got, "snoozed_until:<nil>,snoozed_until:2") {
+		t.Fatalf("snooze history=%s", got)
+	}
+
+	// the wake job clears ended snoozes and tells the user
+	w = doJSON(r, "PUT", paths[1]+"/snooze", gin.H{"until": time.Now().Add(time.Hour).Format(time.RFC3339)}, user.ID)
+	expectStatus(t, w, http.StatusOK)
+	if n, err := jobs.WakeSnoozedTasks(); err != nil || n != 0 {
+		t.Fatalf("woke %d early: %v", n, err)
+	}
+	config.DB.Model(&models.Task{}).Where("title = ?", "Taxes").Update("snoozed_until", time.Now().Add(-time.Minute).UTC())
+	if n, err := jobs.WakeSnoozedTasks(); err != nil || n != 1 {
+		t.Fatalf("woke %d: %v", n, err)
+	}
+	var task models.Task
+	config.DB.Where("title = ?", "Taxes").First(&task)
+	var notes []models.Notification
+	config.DB.Where("user_id = ? AND type = ?", user.ID, models.NotificationSnoozeEnded).Find(&notes)
+	if task.SnoozedUntil != nil || len(notes) != 1 || *notes[0].TaskID != task.ID {
+		t.Fatalf("task=%v notifications=%v", task.SnoozedUntil, notes)
+	}
+	if n, _ := jobs.WakeSnoozedTasks(); n != 0 {
+		t.Fatalf("woke %d twice", n)
+	}
+}
@@ -0,0 +1,440 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const MaxWorkflowStatuses = 20
+
+// Where a workflow comes from
+const (
+	workflowProject = "project"
+	workflowUser    = "user"
+	workflowDefault = "default"
+)
+
+// userWorkflow loads the user's default workflow, falling back to
+// models.DefaultWorkflow
+func userWorkflow(tx *gorm.DB, userID int64) ([]models.TaskStatus, string, error) {
+	var statuses []models.TaskStatus
+	if err := tx.Where("user_id = ?", userID).Order("position").Find(&statuses).Error; err != nil {
+		return nil, "", err
+	}
+	if len(statuses) == 0 {
+		return models.DefaultWorkflow, workflowDefault, nil
+	}
+	return statuses, workflowUser, nil
+}
+
+// projectWorkflow loads the project's workflow, falling back to its
+// owner's
+func projectWorkflow(tx *gorm.DB, project models.Project) ([]models.TaskStatus, string, error) {
+	var statuses []models.TaskStatus
+	if err := tx.Where("project_id = ?", project.ID).Order("position").Find(&statuses).Error; err != nil {
+		return nil, "", err
+	}
+	if len(statuses) == 0 {
+		return userWorkflow(tx, project.UserID)
+	}
+	return statuses, workflowProject, nil
+}
+
+// taskWorkflow returns the workflow that applies to the task
+func taskWorkflow(tx *gorm.DB, task models.Task) ([]models.TaskStatus, error) {
+	if task.ProjectID == nil {
+		statuses, _, err := userWorkflow(tx, task.UserID)
+		return statuses, err
+	}
+	var project models.Project
+	if err := tx.First(&project, *task.ProjectID).Error; err != nil {
+		return nil, err
+	}
+	statuses, _, err := projectWorkflow(tx, project)
+	return statuses, err
+}
+
+func findStatus(workflow []models.TaskStatus, key string) (models.TaskStatus, bool) {
+	for _, s := range workflow {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return models.TaskStatus{}, false
+}
+
+// firstInCategory returns the workflow's first status in category, or its
+// first status when there is none. Valid workflows have todo and done
+// statuses.
+func firstInCategory(workflow []models.TaskStatus, category string) models.TaskStatus {
+	for _, s := range workflow {
+		if s.Category == category {
+			return s
+		}
+	}
+	return workflow[0]
+}
+
+func statusKeys(workflow []models.TaskStatus) string {
+	keys := make([]string, len(workflow))
+	for i, s := range workflow {
+		keys[i] = s.Key
+	}
+	return strings.Join(keys, "|")
+}
+
+// canTransition reports whether the workflow lets a task move from one
+// status to another. Tasks in a status the workflow doesn't know may go
+// anywhere.
+func canTransition(workflow []models.TaskStatus, from, to string) bool {
+	s, ok := findStatus(workflow, from)
+	if !ok || s.Transitions == nil || from == to {
+		return true
+	}
+	for _, next := range s.Transitions {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// setTaskStatus moves the task to status, checking the workflow's
+// transitions. Errors describe why the status isn't allowed.
+func setTaskStatus(workflow []models.TaskStatus, task *models.Task, status string) error {
+	s, ok := findStatus(workflow, status)
+	if !ok {
+		return fmt.Errorf("status must be %s", statusKeys(workflow))
+	}
+	if !canTransition(workflow, task.Status, status) {
+		return fmt.Errorf("a task can't move from %s to %s", task.Status, status)
+	}
+	task.Status, task.StatusCategory = s.Key, s.Category
+	return nil
+}
+
+// fitStatus keeps the task's status if the workflow has it and otherwise
+// picks the first status of the same category, e.g. after a move to a
+// project with another workflow
+func fitStatus(workflow []models.TaskStatus, task *models.Task) {
+	s, ok := findStatus(workflow, task.Status)
+	if !ok {
+		s = firstInCategory(workflow, task.StatusCategory)
+	}
+	task.Status, task.StatusCategory = s.Key, s.Category
+}
+
+// fitTasksToWorkflow updates every task in scope, trashed ones included, for
+// a new workflow: a status named in migrate moves to the status it maps to,
+// others the workflow lacks go to the first status of their category, and
+// categories are brought up to date.
+func fitTasksToWorkflow(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, workflow []models.TaskStatus, migrate map[string]string) error {
+	var used []struct {
+		Status         string
+		StatusCategory string
+	}
+	if err := tx.Unscoped().Model(&models.Task{}).Scopes(scope).Distinct("status", "status_category").
+		Scan(&used).Error; err != nil {
+		return err
+	}
+	// find every task to change before changing any, so that migrating
+	// a→b and b→c doesn't take a's tasks on to c
+	type change struct {
+		ids    []int64
+		target models.TaskStatus
+	}
+	var changes []change
+	for _, u := range used {
+		target, ok := findStatus(workflow, u.Status)
+		if to, mapped := migrate[u.Status]; mapped {
+			target, _ = findStatus(workflow, to)
+		} else if !ok {
+			target = firstInCategory(workflow, u.StatusCategory)
+		}
+		if target.Key == u.Status && target.Category == u.StatusCategory {
+			continue
+		}
+		ch := change{target: target}
+		if err := tx.Unscoped().Model(&models.Task{}).Scopes(scope).
+			Where("status = ? AND status_category = ?", u.Status, u.StatusCategory).Pluck("tasks.id", &ch.ids).Error; err != nil {
+			return err
+		}
+		changes = append(changes, ch)
+	}
+	for _, ch := range changes {
+		if err := tx.Unscoped().Model(&models.Task{}).Where("id IN ?", ch.ids).
+			Updates(map[string]interface{}{"status": ch.target.Key, "status_category": ch.target.Category, "version": nextVersion}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workflowInput is the body of the workflow PUT routes
+type workflowInput struct {
+	Statuses []struct {
+		Key         string   `json:"key"`
+		Name        string   `json:"name"`
+		Category    string   `json:"category"` // todo|doing|done
+		Transitions []string `json:"transitions"`
+	} `json:"statuses"`
+	// Migrate maps statuses tasks are in to the new status they should
+	// take; unmapped statuses missing from the workflow keep their category
+	Migrate map[string]string `json:"migrate"`
+}
+
+// statuses validates the input and returns it as a workflow
+func (in workflowInput) statuses() ([]models.TaskStatus, error) {
+	if len(in.Statuses) == 0 || len(in.Statuses) > MaxWorkflowStatuses {
+		return nil, fmt.Errorf("a workflow has 1 to %d statuses", MaxWorkflowStatuses)
+	}
+	workflow := make([]models.TaskStatus, len(in.Statuses))
+	categories := map[string]bool{}
+	for i, s := range in.Statuses {
+		key := strings.ToLower(strings.TrimSpace(s.Key))
+		if !helpers.IsValidStatusKey(key) {
+			return nil, fmt.Errorf("invalid status key %q: use lowercase letters, digits, - and _", s.Key)
+		}
+		if _, dup := findStatus(workflow[:i], key); dup {
+			return nil, fmt.Errorf("status %q given more than once", key)
+		}
+		name := strings.TrimSpace(s.Name)
+		if name == "" || len(name) > 50 {
+			return nil, fmt.Errorf("status %q needs a name of 1-50 characters", key)
+		}
+		category := strings.ToLower(strings.TrimSpace(s.Category))
+		if !models.IsValidStatusCategory(category) {
+			return nil, fmt.Errorf("status %q: category must be todo|doing|done", key)
+		}
+		categories[category] = true
+		workflow[i] = models.TaskStatus{Key: key, Name: name, Category: category, Position: i, Transitions: s.Transitions}
+	}
+	if !categories[models.StatusCategoryTodo] || !categories[models.StatusCategoryDone] {
+		return nil, errors.New("a workflow needs at least one todo and one done status")
+	}
+	for i, s := range workflow {
+		if s.Transitions == nil {
+			continue
+		}
+		next := make([]string, 0, len(s.Transitions))
+		for _, key := range s.Transitions {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if _, ok := findStatus(workflow, key); !ok {
+				return nil, fmt.Errorf("status %q: unknown transition to %q", s.Key, key)
+			}
+			next = append(next, key)
+		}
+		workflow[i].Transitions = next
+	}
+	return workflow, nil
+}
+
+// projectTasks scopes to the tasks in a project
+func projectTasks(projectID int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("tasks.project_id = ?", projectID)
+	}
+}
+
+// inheritingTasks scopes to the tasks that follow a user's default
+// workflow: their tasks outside projects and those in projects they own
+// without a workflow of their own
+func inheritingTasks(userID int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("((tasks.project_id IS NULL AND tasks.user_id = ?)"+
+			" OR tasks.project_id IN (SELECT id FROM projects WHERE user_id = ?"+
+			" AND id NOT IN (SELECT project_id FROM task_statuses WHERE project_id IS NOT NULL)))",
+			userID, userID)
+	}
+}
+
+// replaceWorkflow stores workflow in place of the statuses matching where
+// and refits the tasks in scope to it (or to fallback when workflow is
+// empty, i.e. the statuses are being removed)
+func replaceWorkflow(where map[string]interface{}, scope func(*gorm.DB) *gorm.DB, workflow []models.TaskStatus,
+	fallback func(tx *gorm.DB) ([]models.TaskStatus, string, error), migrate map[string]string) ([]models.TaskStatus, string, error) {
+	source := ""
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(where).Delete(&models.TaskStatus{}).Error; err != nil {
+			return err
+		}
+		if len(workflow) > 0 {
+			if err := tx.Create(&workflow).Error; err != nil {
+				return err
+			}
+		}
+		var err error
+		workflow, source, err = fallback(tx)
+		if err != nil {
+			return err
+		}
+		for from, to := range migrate {
+			if _, ok := findStatus(workflow, to); !ok {
+				return fmt.Errorf("%w: %q maps to unknown status %q", errBadMigration, from, to)
+			}
+		}
+		return fitTasksToWorkflow(tx, scope, workflow, migrate)
+	})
+	return workflow, source, err
+}
+
+var errBadMigration = errors.New("migrate")
+
+func workflowResponse(c *gin.Context, message string, workflow []models.TaskStatus, source string) {
+	helpers.APIResponse(c, http.StatusOK, message, gin.H{"statuses": workflow, "source": source})
+}
+
+func workflowErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, errBadMigration) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update workflow"})
+}
+
+// GetProjectStatuses returns the workflow tasks in the project follow
+func GetProjectStatuses(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, "")
+	if !ok {
+		return
+	}
+	workflow, source, err := projectWorkflow(config.DB, project)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	workflowResponse(c, "OK", workflow, source)
+}
+
+// SetProjectStatuses gives the project its own workflow. Tasks in statuses
+// it lacks are migrated.
+func SetProjectStatuses(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	var in workflowInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	workflow, err := in.statuses()
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	for i := range workflow {
+		workflow[i].ProjectID = &project.ID
+	}
+	workflow, source, err := replaceWorkflow(map[string]interface{}{"project_id": project.ID}, projectTasks(project.ID), workflow,
+		func(tx *gorm.DB) ([]models.TaskStatus, string, error) { return projectWorkflow(tx, project) }, in.Migrate)
+	if err != nil {
+		workflowErrorResponse(c, err)
+		return
+	}
+	workflowResponse(c, "Workflow updated", workflow, source)
+}
+
+// DeleteProjectStatuses drops the project's own workflow so it follows its
+// owner's again. An optional body maps statuses as for SetProjectStatuses.
+func DeleteProjectStatuses(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, ok := parseProjectID(c)
+	if !ok {
+		return
+	}
+	project, _, ok := requireProject(c, uid.(int64), id, models.ProjectRoleOwner)
+	if !ok {
+		return
+	}
+	var in workflowInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&in); err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+			return
+		}
+	}
+	workflow, source, err := replaceWorkflow(map[string]interface{}{"project_id": project.ID}, projectTasks(project.ID), nil,
+		func(tx *gorm.DB) ([]models.TaskStatus, string, error) { return projectWorkflow(tx, project) }, in.Migrate)
+	if err != nil {
+		workflowErrorResponse(c, err)
+		return
+	}
+	workflowResponse(c, "Workflow reset", workflow, source)
+}
+
+// GetStatuses returns the user's default workflow
+func GetStatuses(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	workflow, source, err := userWorkflow(config.DB, uid.(int64))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	workflowResponse(c, "OK", workflow, source)
+}
+
+// SetStatuses sets the user's default workflow, which applies to their
+// projects without one of their own
+func SetStatuses(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	userID := uid.(int64)
+	var in workflowInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	workflow, err := in.statuses()
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	for i := range workflow {
+		workflow[i].UserID = &userID
+	}
+	workflow, source, err := replaceWorkflow(map[string]interface{}{"user_id": userID}, inheritingTasks(userID), workflow,
+		func(tx *gorm.DB) ([]models.TaskStatus, string, error) { return userWorkflow(tx, userID) }, in.Migrate)
+	if err != nil {
+		workflowErrorResponse(c, err)
+		return
+	}
+	workflowResponse(c, "Workflow updated", workflow, source)
+}
+
+// DeleteStatuses goes back to the built-in pending/completed workflow
+func DeleteStatuses(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	userID := uid.(int64)
+	var in workflowInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&in); err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+			return
+		}
+	}
+	workflow, source, err := replaceWorkflow(map[string]interface{}{"user_id": userID}, inheritingTasks(userID), nil,
+		func(tx *gorm.DB) ([]models.TaskStatus, string, error) { return userWorkflow(tx, userID) }, in.Migrate)
+	if err != nil {
+		workflowErrorResponse(c, err)
+		return
+	}
+	workflowResponse(c, "Workflow reset", workflow, source)
+}
@@ -0,0 +1,137 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/controllers"
+)
+
+func TestProjectStatuses(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/projects", controllers.CreateProject)
+	api.GET("/projects/:id/statuses", controllers.GetProjectStatuses)
+	api.PUT("/projects/:id/statuses", controllers.SetProjectStatuses)
+	api.DELETE("/projects/:id/statuses", controllers.DeleteProjectStatuses)
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+	api.POST("/tasks/bulk", controllers.BulkTasks)
+
+	u := seedUser(t, "workflow")
+	w := doJSON(r, "POST", "/api/projects", gin.H{"name": "Sprint"}, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	project := responseData(t, w)["id"]
+	statuses := fmt.Sprintf("/api/projects/%v/statuses", project)
+
+	newTask := func(title string, fields gin.H) map[string]interface{} {
+		t.Helper()
+		body := gin.H{"title": title, "project_id": project}
+		for k, v := range fields {
+			body[k] = v
+		}
+		w := doJSON(r, "POST", "/api/tasks", body, u.ID)
+		expectStatus(t, w, http.StatusCreated)
+		return responseData(t, w)
+	}
+	patch := func(task map[string]interface{}, body gin.H) *httptest.ResponseRecorder {
+		return doJSON(r, "PATCH", fmt.Sprintf("/api/tasks/%v", task["id"]), body, u.ID)
+	}
+	old := newTask("old", gin.H{"status": "completed"})
+	if old["status_category"] != "done" {
+		t.Fatalf("default workflow category %v", old["status_category"])
+	}
+
+	// projects start with the default workflow
+	w = doJSON(r, "GET", statuses, nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	if src := responseData(t, w)["source"]; src != "default" {
+		t.Fatalf("source=%v", src)
+	}
+
+	for _, bad := range []gin.H{
+		{"statuses": []gin.H{{"key": "open", "name": "Open", "category": "todo"}}},
+		{"statuses": []gin.H{{"key": "Open Now", "name": "Open", "category": "todo"}, {"key": "done", "name": "Done", "category": "done"}}},
+		{"statuses": []gin.H{{"key": "open", "name": "Open", "category": "todo", "transitions": []string{"nope"}}, {"key": "done", "name": "Done", "category": "done"}}},
+		{"statuses": []gin.H{{"key": "open", "name": "Open", "category": "todo"}, {"key": "done", "name": "Done", "category": "done"}}, "migrate": gin.H{"completed": "shipped"}},
+	} {
+		if w := doJSON(r, "PUT", statuses, bad, u.ID); w.Code != http.StatusBadRequest {
+			t.Errorf("%v: status %d want 400", bad, w.Code)
+		}
+	}
+
+	// existing tasks migrate: mapped statuses move, the rest keep their category
+	pending := newTask("pending", nil)
+	w = doJSON(r, "PUT", statuses, gin.H{
+		"statuses": []gin.H{
+			{"key": "open", "name": "Open", "category": "todo", "transitions": []string{"in-progress", "cancelled"}},
+			{"key": "in-progress", "name": "In progress", "category": "doing", "transitions": []string{"open", "done"}},
+			{"key": "done", "name": "Done", "category": "done", "transitions": []string{"open"}},
+			{"key": "cancelled", "name": "Cancelled", "category": "done"},
+		},
+		"migrate": gin.H{"pending": "in-progress"},
+	}, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	if src := responseData(t, w)["source"]; src != "project" {
+		t.Fatalf("source=%v", src)
+	}
+	w = doJSON(r, "GET", "/api/tasks?sort=title", nil, u.ID)
+	got := map[string]string{}
+	for _, task := range responseData(t, w)["tasks"].([]interface{}) {
+		task := task.(map[string]interface{})
+		got[task["title"].(string)] = fmt.Sprint(task["status"], "/", task["status_category"])
+	}
+	if got["old"] != "done/done" || got["pending"] != "in-progress/doing" {
+		t.Fatalf("after migration %v", got)
+	}
+
+	// new tasks start in the first todo status; transitions are enforced
+	task := newTask("new", nil)
+	if task["status"] != "open" {
+		t.Fatalf("new task status %v", task["status"])
+	}
+	expectStatus(t, patch(task, gin.H{"status": "done"}), http.StatusBadRequest)
+	expectStatus(t, patch(task, gin.H{"status": "completed"}), http.StatusBadRequest)
+	expectStatus(t, patch(task, gin.H{"status": "in-progress"}), http.StatusOK)
+
+	// the category filter spans statuses
+	w = doJSON(r, "GET", "/api/tasks?category=doing", nil, u.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 2 {
+		t.Fatalf("doing tasks=%d want 2", n)
+	}
+	w = doJSON(r, "GET", "/api/tasks?query=category!=done", nil, u.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 2 {
+		t.Fatalf("open tasks=%d want 2", n)
+	}
+
+	// bulk complete uses the workflow's first done status, if allowed
+	w = doJSON(r, "POST", "/api/tasks/bulk", gin.H{"ids": []interface{}{task["id"], pending["id"]}, "action": "complete"}, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	if n := responseData(t, w)["summary"].(map[string]interface{})["updated"]; n != float64(2) {
+		t.Fatalf("completed %v want 2", n)
+	}
+	opened := newTask("opened", nil)
+	w = doJSON(r, "POST", "/api/tasks/bulk", gin.H{"ids": []interface{}{opened["id"]}, "action": "complete"}, u.ID)
+	if n := responseData(t, w)["summary"].(map[string]interface{})["skipped"]; n != float64(1) {
+		t.Fatalf("open→done should be skipped, summary %v", responseData(t, w)["summary"])
+	}
+
+	// moving to a project on another workflow keeps the category
+	w = doJSON(r, "POST", "/api/projects", gin.H{"name": "Plain"}, u.ID)
+	plain := responseData(t, w)["id"]
+	w = patch(task, gin.H{"project_id": plain})
+	expectStatus(t, w, http.StatusOK)
+	if moved := responseData(t, w); moved["status"] != "completed" || moved["status_category"] != "done" {
+		t.Fatalf("moved task %v/%v", moved["status"], moved["status_category"])
+	}
+
+	// dropping the workflow refits the remaining tasks to the default
+	expectStatus(t, doJSON(r, "DELETE", statuses, nil, u.ID), http.StatusOK)
+	w = doJSON(r, "GET", "/api/tasks?status=pending", nil, u.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 1 {
+		t.Fatalf("pending tasks=%d want 1", n)
+	}
+}
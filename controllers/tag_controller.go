@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const MaxTagsPerTask = 20
+
+// normalizeTags lowercases, trims and de-duplicates tag names and checks
+// them against the tag name rules
+func normalizeTags(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(n), "#"))
+		if !helpers.IsValidTagName(n) {
+			return nil, fmt.Errorf("invalid tag %q: use 1-50 letters, digits, - or _", n)
+		}
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	if len(out) > MaxTagsPerTask {
+		return nil, fmt.Errorf("a task can have at most %d tags", MaxTagsPerTask)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// setTaskTags replaces the tags of a task, creating missing tags
+func setTaskTags(tx *gorm.DB, taskID int64, names []string) error {
+	if err := tx.Where("task_id = ?", taskID).Delete(&models.TaskTag{}).Error; err != nil {
+		return err
+	}
+	for _, name := range names {
+		tag := models.Tag{Name: name}
+		if err := tx.Where("name = ?", name).FirstOrCreate(&tag).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.TaskTag{TaskID: taskID, TagID: tag.ID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTags fills Tags for a page of tasks with one query
+func loadTags(tx *gorm.DB, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	var rows []struct {
+		TaskID int64
+		Name   string
+	}
+	if err := tx.Table("task_tags").Select("task_tags.task_id, tags.name").
+		Joins("JOIN tags ON tags.id = task_tags.tag_id").
+		Where("task_tags.task_id IN ?", ids).Order("tags.name").Scan(&rows).Error; err != nil {
+		return err
+	}
+	byTask := make(map[int64][]string, len(tasks))
+	for _, r := range rows {
+		byTask[r.TaskID] = append(byTask[r.TaskID], r.Name)
+	}
+	for i := range tasks {
+		tasks[i].Tags = byTask[tasks[i].ID]
+		if tasks[i].Tags == nil {
+			tasks[i].Tags = []string{}
+		}
+	}
+	return nil
+}
+
+// mergeTags returns current with the given tags added or removed
+func mergeTags(current, tags []string, remove bool) []string {
+	drop := map[string]bool{}
+	for _, t := range tags {
+		drop[t] = true
+	}
+	out := []string{}
+	for _, t := range current {
+		if !drop[t] {
+			out = append(out, t)
+		}
+	}
+	if !remove {
+		out = append(out, tags...)
+		sort.Strings(out)
+	}
+	return out
+}
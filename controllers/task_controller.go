@@ -1,14 +1,19 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go-todo-app/config"
+	"go-todo-app/filter"
 	"go-todo-app/helpers"
 	"go-todo-app/models"
+	"gorm.io/gorm"
 )
 
 const (
@@ -17,20 +22,308 @@ const (
 	MaxPageSize     = 100
 )
 
+var errProjectArchived = errors.New("project is archived")
+
+// pageParams reads page and page_size, falling back to the defaults
+func pageParams(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", strconv.Itoa(DefaultPage)))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(DefaultPageSize)))
+	if page < 1 {
+		page = DefaultPage
+	}
+	if pageSize < 1 || pageSize > MaxPageSize {
+		pageSize = DefaultPageSize
+	}
+	return page, pageSize
+}
+
+// resolveTaskProject returns the project a task should live in: the given
+// project when set, the user's Inbox otherwise. The user needs an owner or
+// editor role, and archived projects don't accept tasks.
+func resolveTaskProject(tx *gorm.DB, userID int64, projectID *int64) (models.Project, error) {
+	if projectID == nil {
+		return ensureInbox(tx, userID)
+	}
+	project, role, err := findAccessibleProject(tx, userID, *projectID)
+	if err != nil {
+		return project, err
+	}
+	if !models.CanEditTasks(role) {
+		return project, errForbidden
+	}
+	if project.ArchivedAt != nil {
+		return project, errProjectArchived
+	}
+	return project, nil
+}
+
+// decorateTasks fills the computed, non-stored fields of tasks for a response
+func decorateTasks(tx *gorm.DB, tasks []models.Task) error {
+	if err := countComments(tx, tasks); err != nil {
+		return err
+	}
+	if err := loadTags(tx, tasks); err != nil {
+		return err
+	}
+	if err := loadBlocked(tx, tasks); err != nil {
+		return err
+	}
+	if err := loadTrackedTime(tx, tasks); err != nil {
+		return err
+	}
+	if err := loadChecklist(tx, tasks); err != nil {
+		return err
+	}
+	return loadCustomFields(tx, tasks)
+}
+
+// moveTask puts the task at the end of project, unassigning it when the
+// assignee isn't a member of the new project and fitting its status to the
+// project's workflow
+func moveTask(tx *gorm.DB, task *models.Task, project models.Project) error {
+	if task.ProjectID != nil && *task.ProjectID == project.ID {
+		return nil
+	}
+	task.ProjectID = &project.ID
+	position, err := endPosition(tx, *task)
+	if err != nil {
+		return err
+	}
+	task.Position = position
+	workflow, _, err := projectWorkflow(tx, project)
+	if err != nil {
+		return err
+	}
+	fitStatus(workflow, task)
+	if task.AssigneeID == nil {
+		return nil
+	}
+	ok, err := canAccessTask(tx, *task.AssigneeID, *task)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		task.AssigneeID = nil
+	}
+	return nil
+}
+
+// taskFilter holds the list filters shared by the task listings and bulk
+// operations
+type taskFilter struct {
+	Status    string `json:"status"`
+	Category  string `json:"category"` // todo|doing|done
+	Priority  string `json:"priority"`
+	Assignee  string `json:"assignee"` // me|none|<user id>
+	ProjectID *int64 `json:"project_id"`
+	ParentID  *int64 `json:"parent_id"` // subtasks of this task
+	Tag       string `json:"tag"`
+	Blocked   *bool  `json:"blocked"` // with (or without) open blockers
+	Starred   *bool  `json:"starred"`
+	Pinned    *bool  `json:"pinned"`
+	Snoozed   *bool  `json:"snoozed"` // snoozed until later (or awake)
+	Query     string `json:"query"`   // filter expression, see package filter
+}
+
+// queryTaskFilter reads a taskFilter from the query string
+func queryTaskFilter(c *gin.Context) (taskFilter, error) {
+	f := taskFilter{
+		Status:   c.Query("status"),
+		Category: c.Query("category"),
+		Priority: c.Query("priority"),
+		Assignee: c.Query("assignee"),
+		Tag:      c.Query("tag"),
+		Query:    c.Query("query"),
+	}
+	if v := c.Query("project_id"); v != "" {
+		projectID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, errors.New("invalid project_id")
+		}
+		f.ProjectID = &projectID
+	}
+	if v := c.Query("parent_id"); v != "" {
+		parentID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, errors.New("invalid parent_id")
+		}
+		f.ParentID = &parentID
+	}
+	flags := []struct {
+		name string
+		dst  **bool
+	}{{"blocked", &f.Blocked}, {"starred", &f.Starred}, {"pinned", &f.Pinned}, {"snoozed", &f.Snoozed}}
+	for _, flag := range flags {
+		if v := c.Query(flag.name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return f, errors.New(flag.name + " must be true|false")
+			}
+			*flag.dst = &b
+		}
+	}
+	return f, nil
+}
+
+// apply adds the filter conditions to q. Errors describe invalid values;
+// expression errors are *filter.Error.
+func (f taskFilter) apply(q *gorm.DB, userID int64) (*gorm.DB, error) {
+	if s := f.Status; s != "" {
+		s = strings.ToLower(s)
+		if !helpers.IsValidStatusKey(s) {
+			return q, errors.New("invalid status")
+		}
+		q = q.Where("tasks.status = ?", s)
+	}
+	if cat := f.Category; cat != "" {
+		cat = strings.ToLower(cat)
+		if !models.IsValidStatusCategory(cat) {
+			return q, errors.New("category must be todo|doing|done")
+		}
+		q = q.Where("tasks.status_category = ?", cat)
+	}
+	if p := f.Priority; p != "" {
+		p = strings.ToLower(p)
+		if !models.IsValidPriority(p) {
+			return q, errors.New("priority must be low|medium|high")
+		}
+		q = q.Where("tasks.priority = ?", p)
+	}
+	if a := f.Assignee; a != "" {
+		switch a {
+		case "me":
+			q = q.Where("tasks.assignee_id = ?", userID)
+		case "none":
+			q = q.Where("tasks.assignee_id IS NULL")
+		default:
+			assignee, err := strconv.ParseInt(a, 10, 64)
+			if err != nil {
+				return q, errors.New("assignee must be me|none|<user id>")
+			}
+			q = q.Where("tasks.assignee_id = ?", assignee)
+		}
+	}
+	if f.ProjectID != nil {
+		q = q.Where("tasks.project_id = ?", *f.ProjectID)
+	}
+	if f.ParentID != nil {
+		q = q.Where("tasks.parent_id = ?", *f.ParentID)
+	}
+	if t := f.Tag; t != "" {
+		q = q.Where("tasks.id IN (SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE tags.name = ?)",
+			strings.ToLower(strings.TrimPrefix(t, "#")))
+	}
+	if f.Blocked != nil {
+		q = q.Where(blockedCond(*f.Blocked))
+	}
+	if f.Starred != nil {
+		q = q.Where("tasks.starred = ?", *f.Starred)
+	}
+	if f.Pinned != nil {
+		q = q.Where("tasks.pinned = ?", *f.Pinned)
+	}
+	if f.Snoozed != nil {
+		if *f.Snoozed {
+			q = q.Where("tasks.snoozed_until > ?", time.Now().UTC())
+		} else {
+			q = q.Scopes(awakeTasks)
+		}
+	}
+	if f.Query != "" {
+		expr, err := filter.Parse(f.Query)
+		if err != nil {
+			return q, err
+		}
+		loc, err := userLocation(config.DB, userID)
+		if err != nil {
+			return q, err
+		}
+		cond, err := translateFilter(expr, userID, loc)
+		if err != nil {
+			return q, err
+		}
+		q = q.Where(cond.sql, cond.args...)
+	}
+	return q, nil
+}
+
+// projectErrorResponse maps resolveTaskProject errors to a response
+func projectErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "project not found"})
+	case errors.Is(err, errProjectArchived):
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "project is archived"})
+	case errors.Is(err, errForbidden):
+		helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "read-only access to project"})
+	default:
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail resolve project"})
+	}
+}
+
 func CreateTask(c *gin.Context) {
 	uid, _ := c.Get("user_id")
 	var in struct {
-		Title       string `json:"title" binding:"required,min=1"`
-		Description string `json:"description"`
-		Priority    string `json:"priority"` // low|medium|high
+		Title       string     `json:"title" binding:"required,min=1"`
+		Description string     `json:"description"`
+		Priority    string     `json:"priority"` // low|medium|high
+		Status      string     `json:"status"`   // defaults to the workflow's first todo status
+		ProjectID   *int64     `json:"project_id"`
+		ParentID    *int64     `json:"parent_id"` // makes the task a subtask of another in its project
+		Tags        []string   `json:"tags"`
+		DueAt       *time.Time `json:"due_at"`
+		Estimate    *int       `json:"estimate_minutes"`
+		Recurrence  string     `json:"recurrence"` // e.g. weekly:mon,fri; see package recurrence
+
+		ChecklistItems []string `json:"checklist_items"`
+		AutoComplete   bool     `json:"checklist_auto_complete"`
+
+		CustomFields map[string]interface{} `json:"custom_fields"`
 	}
 	if err := c.ShouldBindJSON(&in); err != nil {
 		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
 		return
 	}
+	if err := checkEstimate(in.Estimate); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	rule, err := normalizeRecurrence(in.Recurrence)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if len(in.ChecklistItems) > MaxChecklistItems {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": errChecklistFull.Error()})
+		return
+	}
+	checklist := make([]string, len(in.ChecklistItems))
+	for i, text := range in.ChecklistItems {
+		if checklist[i], err = checklistText(text); err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+			return
+		}
+	}
+	tags, err := normalizeTags(in.Tags)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	project, err := resolveTaskProject(config.DB, uid.(int64), in.ProjectID)
+	if err != nil {
+		projectErrorResponse(c, err)
+		return
+	}
+	if in.ParentID != nil {
+		parent, err := findTask(config.DB, uid.(int64), *in.ParentID, false)
+		if err != nil || parent.ProjectID == nil || *parent.ProjectID != project.ID {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "parent must be a task in the same project"})
+			return
+		}
+	}
 	p := strings.ToLower(strings.TrimSpace(in.Priority))
 	if p == "" {
-		p = models.TaskPriorityMedium
+		p = project.DefaultPriority
 	}
 	if !models.IsValidPriority(p) {
 		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "priority must be low|medium|high"})
@@ -38,128 +331,440 @@ func CreateTask(c *gin.Context) {
 	}
 	task := models.Task{
 		UserID:      uid.(int64),
+		ProjectID:   &project.ID,
+		ParentID:    in.ParentID,
 		Title:       strings.TrimSpace(in.Title),
 		Description: strings.TrimSpace(in.Description),
 		Priority:    p,
+		DueAt:       in.DueAt,
+		Version:     1,
+		Tags:        tags,
+
+		EstimateMinutes: in.Estimate,
+		Recurrence:      rule,
+		AutoComplete:    in.AutoComplete,
+	}
+	workflow, _, err := projectWorkflow(config.DB, project)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	status := firstInCategory(workflow, models.StatusCategoryTodo)
+	if s := strings.ToLower(strings.TrimSpace(in.Status)); s != "" {
+		var ok bool
+		if status, ok = findStatus(workflow, s); !ok {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "status must be " + statusKeys(workflow)})
+			return
+		}
+	}
+	task.Status, task.StatusCategory = status.Key, status.Category
+	fields, err := projectFields(config.DB, project.ID)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	values, shown, err := taskFieldValues(fields, in.CustomFields, nil)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
 	}
-	if err := config.DB.Create(&task).Error; err != nil {
+	task.CustomFields = shown
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		position, err := endPosition(tx, task)
+		if err != nil {
+			return err
+		}
+		task.Position = position
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		if err := setTaskTags(tx, task.ID, tags); err != nil {
+			return err
+		}
+		if err := setTaskFields(tx, task.ID, values); err != nil {
+			return err
+		}
+		if _, err := addChecklistItems(tx, task.ID, checklist); err != nil {
+			return err
+		}
+		return recordTaskActivity(tx, c, nil, &task)
+	})
+	if err != nil {
 		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create task"})
 		return
 	}
-	helpers.APIResponse(c, http.StatusCreated, "Task created", task)
+	task.Checklist = models.ChecklistProgress{Total: int64(len(checklist))}
+	taskResponse(c, http.StatusCreated, "Task created", task)
+}
+
+// GetTask returns one task. If-None-Match with its ETag gets a 304.
+func GetTask(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	task, err := findTask(config.DB, uid.(int64), id, false)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	tasks := []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if notModified(c, tasks[0]) {
+		return
+	}
+	taskResponse(c, http.StatusOK, "OK", tasks[0])
 }
 
 func GetTasks(c *gin.Context) {
 	uid, _ := c.Get("user_id")
+	listTasks(c, config.DB.Scopes(visibleTasks(uid.(int64))))
+}
 
-	// Pagination
-	page, _ := strconv.Atoi(c.DefaultQuery("page", strconv.Itoa(DefaultPage)))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(DefaultPageSize)))
-	if page < 1 {
-		page = DefaultPage
+// taskCursor is the signed payload of a task list cursor. It points at
+// the last (or, walking backwards, the first) task of the previous page.
+type taskCursor struct {
+	ID   int64  `json:"id"`
+	Prev bool   `json:"prev,omitempty"`
+	Sort string `json:"sort"`
+}
+
+// awakeTasks leaves out tasks that are snoozed until later
+func awakeTasks(db *gorm.DB) *gorm.DB {
+	return db.Where("(tasks.snoozed_until IS NULL OR tasks.snoozed_until <= ?)", time.Now().UTC())
+}
+
+// listTasks applies the common filters, sorting and pagination to q and
+// writes the task list. Pages are numbered (page, page_size) unless a
+// cursor parameter is given, which switches to keyset pagination; an
+// empty cursor asks for the first page. Pinned tasks come first, and
+// snoozed tasks are left out unless include_snoozed=true or the snoozed
+// filter is given.
+func listTasks(c *gin.Context, q *gorm.DB) {
+	uid, _ := c.Get("user_id")
+
+	// Filters
+	f, err := queryTaskFilter(c)
+	if err == nil {
+		q, err = f.apply(q, uid.(int64))
 	}
-	if pageSize < 1 || pageSize > MaxPageSize {
-		pageSize = DefaultPageSize
+	if err != nil {
+		filterErrorResponse(c, err)
+		return
+	}
+	if f.Snoozed == nil && c.Query("include_snoozed") != "true" {
+		q = q.Scopes(awakeTasks)
 	}
-	offset := (page - 1) * pageSize
+	order, err := parseTaskSort(c.Query("sort"), c.Query("nulls"))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	writeTaskList(c, q, order.pinnedFirst(), "")
+}
 
-	var tasks []models.Task
-	var total int64
-	q := config.DB.Where("user_id = ?", uid.(int64))
+// writeTaskList paginates the filtered query q in the given order and
+// writes the result, split into groups when groupBy is set
+func writeTaskList(c *gin.Context, q *gorm.DB, order taskSort, groupBy string) {
+	q = q.Model(&models.Task{}).Session(&gorm.Session{})
 
-	// Filters
-	if s := c.Query("status"); s != "" {
-		s = strings.ToLower(s)
-		if !models.IsValidStatus(s) {
-			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "status must be pending|completed"})
+	cursor, cursorMode := c.GetQuery("cursor")
+	page, pageSize := pageParams(c)
+	withTotal := c.DefaultQuery("count", strconv.FormatBool(!cursorMode)) == "true"
+	var total int64
+	if withTotal {
+		if err := q.Count(&total).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail count"})
 			return
 		}
-		q = q.Where("status = ?", s)
 	}
-	if p := c.Query("priority"); p != "" {
-		p = strings.ToLower(p)
-		if !models.IsValidPriority(p) {
-			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "priority must be low|medium|high"})
+
+	var tasks []models.Task
+	pagination := gin.H{"page_size": pageSize}
+	if !cursorMode {
+		if err := q.Order(order.orderBy(false)).Limit(pageSize).Offset((page - 1) * pageSize).Find(&tasks).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
 			return
 		}
-		q = q.Where("priority = ?", p)
-	}
+		pagination["page"] = page
+		if withTotal {
+			pagination["total"] = total
+			pagination["total_pages"] = (total + int64(pageSize) - 1) / int64(pageSize)
+		}
+	} else {
+		var cur taskCursor
+		if cursor != "" {
+			if err := helpers.DecodeCursor(cursor, &cur); err != nil || cur.Sort != order.key {
+				helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid cursor for this sort order"})
+				return
+			}
+			var n int64
+			if err := config.DB.Unscoped().Model(&models.Task{}).Where("id = ?", cur.ID).Count(&n).Error; err != nil || n == 0 {
+				helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "cursor is no longer valid"})
+				return
+			}
+			q = q.Select("tasks.*").Joins("JOIN tasks AS cur ON cur.id = ?", cur.ID).Where(order.after("cur", cur.Prev))
+		}
+		if err := q.Order(order.orderBy(cur.Prev)).Limit(pageSize + 1).Find(&tasks).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		more := len(tasks) > pageSize
+		if more {
+			tasks = tasks[:pageSize]
+		}
+		if cur.Prev {
+			for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+				tasks[i], tasks[j] = tasks[j], tasks[i]
+			}
+		}
 
-	// Get total count
-	if err := q.Model(&models.Task{}).Count(&total).Error; err != nil {
-		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail count"})
-		return
+		var next, prev interface{}
+		if len(tasks) > 0 {
+			first, last := tasks[0].ID, tasks[len(tasks)-1].ID
+			// forward: more pages after this one, and before it unless this
+			// is the first; backwards the other way round
+			if cur.Prev && more || !cur.Prev && cursor != "" {
+				prev = helpers.EncodeCursor(taskCursor{ID: first, Prev: true, Sort: order.key})
+			}
+			if !cur.Prev && more || cur.Prev {
+				next = helpers.EncodeCursor(taskCursor{ID: last, Sort: order.key})
+			}
+		}
+		pagination["next_cursor"] = next
+		pagination["prev_cursor"] = prev
+		if withTotal {
+			pagination["total"] = total
+		}
 	}
 
-	// Get paginated tasks
-	if err := q.Order("id desc").Limit(pageSize).Offset(offset).Find(&tasks).Error; err != nil {
+	if err := decorateTasks(config.DB, tasks); err != nil {
 		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
 		return
 	}
-
+	if groupBy != "" {
+		helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+			"group_by":   groupBy,
+			"groups":     groupTasks(tasks, groupBy),
+			"pagination": pagination,
+		})
+		return
+	}
 	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
-		"tasks": tasks,
-		"pagination": gin.H{
-			"page":        page,
-			"page_size":   pageSize,
-			"total":       total,
-			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
-		},
+		"tasks":      tasks,
+		"pagination": pagination,
 	})
 }
 
-func UpdateTask(c *gin.Context) {
+// taskDocument holds the fields clients write directly. PUT replaces a
+// task's fields with one; PATCH reads one out of the patched task.
+type taskDocument struct {
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	Status      string     `json:"status" binding:"required"`   // a status of the task's workflow
+	Priority    string     `json:"priority" binding:"required"` // low|medium|high
+	ProjectID   *int64     `json:"project_id" binding:"required"`
+	DueAt       *time.Time `json:"due_at"`
+	Estimate    *int       `json:"estimate_minutes"`
+	Recurrence  string     `json:"recurrence"`
+	Tags        []string   `json:"tags"`
+
+	AutoComplete bool `json:"checklist_auto_complete"`
+
+	// CustomFields maps custom field keys of the project to values
+	CustomFields map[string]interface{} `json:"custom_fields"`
+}
+
+// editableTask loads the task named in the URL for a change, with its
+// tags and custom fields, writing the error response itself
+func editableTask(c *gin.Context) (models.Task, bool) {
 	uid, _ := c.Get("user_id")
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return models.Task{}, false
+	}
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return task, false
+	}
+	if !checkIfMatch(c, task) {
+		return task, false
+	}
+	tasks := []models.Task{task}
+	err = loadTags(config.DB, tasks)
+	if err == nil {
+		err = loadCustomFields(config.DB, tasks)
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return task, false
+	}
+	return tasks[0], true
+}
+
+// UpdateTask replaces a task's writable fields: title, status, priority and
+// project_id are required, and a description, due date, estimate,
+// recurrence, tags or custom fields left out are cleared. PATCH changes
+// only some fields.
+func UpdateTask(c *gin.Context) {
+	task, ok := editableTask(c)
+	if !ok {
+		return
+	}
+	var in taskDocument
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
 		return
 	}
+	replaceTask(c, task, in)
+}
 
-	var task models.Task
-	if err := config.DB.Where("id = ? AND user_id = ?", id, uid.(int64)).First(&task).Error; err != nil {
-		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "task not found"})
+// replaceTask validates doc, stores it as task's new fields and writes the
+// response
+func replaceTask(c *gin.Context, task models.Task, doc taskDocument) {
+	uid, _ := c.Get("user_id")
+	before := task
+	title := strings.TrimSpace(doc.Title)
+	if title == "" {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "title is required"})
 		return
 	}
-	var in struct {
-		Title       *string `json:"title"`
-		Description *string `json:"description"`
-		Status      *string `json:"status"`   // pending|completed
-		Priority    *string `json:"priority"` // low|medium|high
+	p := strings.ToLower(strings.TrimSpace(doc.Priority))
+	if !models.IsValidPriority(p) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "priority must be low|medium|high"})
+		return
 	}
-	if err := c.ShouldBindJSON(&in); err != nil {
+	tags, err := normalizeTags(doc.Tags)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if err := checkEstimate(doc.Estimate); err != nil {
 		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
 		return
 	}
-	if in.Title != nil {
-		task.Title = strings.TrimSpace(*in.Title)
+	rule, err := normalizeRecurrence(doc.Recurrence)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
 	}
-	if in.Description != nil {
-		task.Description = strings.TrimSpace(*in.Description)
+	moved := task.ProjectID == nil || *task.ProjectID != *doc.ProjectID
+	if moved {
+		project, err := resolveTaskProject(config.DB, uid.(int64), doc.ProjectID)
+		if err != nil {
+			projectErrorResponse(c, err)
+			return
+		}
+		if err := moveTask(config.DB, &task, project); err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
 	}
-	if in.Status != nil {
-		s := strings.ToLower(strings.TrimSpace(*in.Status))
-		if !models.IsValidStatus(s) {
-			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "status must be pending|completed"})
+	// a move keeps the status, or one of its category, unless another is
+	// asked for
+	if s := strings.ToLower(strings.TrimSpace(doc.Status)); s != before.Status {
+		workflow, err := taskWorkflow(config.DB, task)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if err := setTaskStatus(workflow, &task, s); err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
 			return
 		}
-		task.Status = s
 	}
-	if in.Priority != nil {
-		p := strings.ToLower(strings.TrimSpace(*in.Priority))
-		if !models.IsValidPriority(p) {
-			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "priority must be low|medium|high"})
+	fields, err := taskFields(config.DB, task)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	var carried map[string]interface{}
+	if moved {
+		carried = before.CustomFields
+	}
+	values, shown, err := taskFieldValues(fields, doc.CustomFields, carried)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	// finishing a task that waits on others takes force=true
+	if finishesTask(before, task) && c.Query("force") != "true" {
+		blockers, err := openBlockers(config.DB, task.ID)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if len(blockers) > 0 {
+			helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{
+				"details":  "the task is blocked by open tasks; use force=true to finish it anyway",
+				"blockers": blockers,
+			})
 			return
 		}
-		task.Priority = p
 	}
-	if err := config.DB.Save(&task).Error; err != nil {
+	task.Title = title
+	task.Description = strings.TrimSpace(doc.Description)
+	task.Priority = p
+	task.DueAt = doc.DueAt
+	task.EstimateMinutes = doc.Estimate
+	task.Recurrence = rule
+	task.AutoComplete = doc.AutoComplete
+	task.Tags = tags
+	task.CustomFields = shown
+	finished := finishesTask(before, task)
+	if finished {
+		task.Recurrence = "" // moves on to the next occurrence
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveTask(tx, &task); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(before.Tags, task.Tags) {
+			if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+				return err
+			}
+		}
+		if moved || !jsonEqual(before.CustomFields, task.CustomFields) {
+			if err := setTaskFields(tx, task.ID, values); err != nil {
+				return err
+			}
+		}
+		if finished {
+			if err := notifyUnblocked(tx, c, task); err != nil {
+				return err
+			}
+			if err := repeatTask(tx, c, rule, task); err != nil {
+				return err
+			}
+		}
+		return recordTaskActivity(tx, c, &before, &task)
+	})
+	if errors.Is(err, errStaleTask) {
+		staleTaskResponse(c, task)
+		return
+	}
+	if err != nil {
 		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
 		return
 	}
-	helpers.APIResponse(c, http.StatusOK, "Updated", task)
+	tasks := []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	taskResponse(c, http.StatusOK, "Updated", tasks[0])
 }
 
+// DeleteTask moves a task to the trash. Comments and attachments are kept
+// until the trash is purged.
 func DeleteTask(c *gin.Context) {
 	uid, _ := c.Get("user_id")
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -168,13 +773,36 @@ func DeleteTask(c *gin.Context) {
 		return
 	}
 
-	result := config.DB.Where("id = ? AND user_id = ?", id, uid.(int64)).Delete(&models.Task{})
-	if result.Error != nil {
-		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	if !checkIfMatch(c, task) {
 		return
 	}
-	if result.RowsAffected == 0 {
-		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "task not found"})
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("version = ?", task.Version).Delete(&task)
+		if res.Error == nil && res.RowsAffected == 0 {
+			return errStaleTask
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+		// a finished task already told its dependents when it was done
+		if task.StatusCategory != models.StatusCategoryDone {
+			if err := notifyUnblocked(tx, c, task); err != nil {
+				return err
+			}
+		}
+		return recordTaskActivity(tx, c, &task, nil)
+	})
+	if errors.Is(err, errStaleTask) {
+		staleTaskResponse(c, task)
+		return
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
 		return
 	}
 
@@ -3,8 +3,11 @@ package controllers_test
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -84,3 +87,279 @@ func TestTaskCRUD(t *testing.T) {
 		t.Fatal("pagination field missing in response")
 	}
 }
+
+func TestGetTasksSorting(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+
+	u := seedUser(t, "sortfan")
+	due := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	for _, in := range []gin.H{
+		{"title": "b", "priority": "low", "due_at": due},
+		{"title": "a", "priority": "high"},
+		{"title": "c", "priority": "medium", "due_at": due.Add(-time.Hour)},
+		{"title": "d", "priority": "high", "due_at": due},
+	} {
+		expectStatus(t, doJSON(r, "POST", "/api/tasks", in, u.ID), http.StatusCreated)
+	}
+	order := func(query string) string {
+		t.Helper()
+		w := doJSON(r, "GET", "/api/tasks?"+query, nil, u.ID)
+		expectStatus(t, w, http.StatusOK)
+		var titles string
+		for _, task := range responseData(t, w)["tasks"].([]interface{}) {
+			titles += task.(map[string]interface{})["title"].(string)
+		}
+		return titles
+	}
+
+	for query, want := range map[string]string{
+		"":                              "dcab",
+		"sort=-priority,title":          "adcb",
+		"sort=priority":                 "bcda", // high ties: newest first
+		"sort=due_at,title":             "cbda",
+		"sort=due_at,title&nulls=first": "acbd",
+		"sort=-due_at,-title":           "dbca",
+	} {
+		if got := order(query); got != want {
+			t.Errorf("%q: got %s want %s", query, got, want)
+		}
+	}
+
+	// an explicit null clears the due date; leaving the field out keeps it
+	w := doJSON(r, "GET", "/api/tasks?sort=due_at", nil, u.ID)
+	first := responseData(t, w)["tasks"].([]interface{})[0].(map[string]interface{})
+	path := fmt.Sprintf("/api/tasks/%v", first["id"])
+	w = doJSON(r, "PATCH", path, gin.H{"title": "c"}, u.ID)
+	if responseData(t, w)["due_at"] == nil {
+		t.Fatal("due_at cleared by an update without it")
+	}
+	w = doJSON(r, "PATCH", path, gin.H{"due_at": nil}, u.ID)
+	if responseData(t, w)["due_at"] != nil {
+		t.Fatal("due_at not cleared by null")
+	}
+
+	for _, query := range []string{"sort=password_hash", "sort=title,title", "sort=due_at&nulls=middle"} {
+		expectStatus(t, doJSON(r, "GET", "/api/tasks?"+query, nil, u.ID), http.StatusBadRequest)
+	}
+}
+
+func TestGetTasksCursorPagination(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+
+	u := seedUser(t, "scroller")
+	due := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	priorities := []string{"low", "medium", "high"}
+	for i := 0; i < 11; i++ {
+		in := gin.H{"title": fmt.Sprintf("t%02d", i), "priority": priorities[i%3]}
+		if i%4 != 0 {
+			in["due_at"] = due.Add(time.Duration(i%5) * time.Hour)
+		}
+		expectStatus(t, doJSON(r, "POST", "/api/tasks", in, u.ID), http.StatusCreated)
+	}
+
+	for _, sort := range []string{"", "-priority,due_at", "due_at,-title&nulls=first", "status,-updated_at"} {
+		base := "/api/tasks?page_size=4&sort=" + sort
+		w := doJSON(r, "GET", "/api/tasks?page_size=100&sort="+sort, nil, u.ID)
+		var want []string
+		for _, task := range responseData(t, w)["tasks"].([]interface{}) {
+			want = append(want, task.(map[string]interface{})["title"].(string))
+		}
+
+		// walk forward, then back from the end
+		var got []string
+		var pages []string
+		cursor := ""
+		for {
+			w = doJSON(r, "GET", base+"&cursor="+cursor, nil, u.ID)
+			expectStatus(t, w, http.StatusOK)
+			data := responseData(t, w)
+			var titles []string
+			for _, task := range data["tasks"].([]interface{}) {
+				titles = append(titles, task.(map[string]interface{})["title"].(string))
+			}
+			got = append(got, titles...)
+			pages = append(pages, fmt.Sprint(titles))
+			p := data["pagination"].(map[string]interface{})
+			if _, ok := p["total"]; ok {
+				t.Fatal("cursor pages count only when asked")
+			}
+			next, _ := p["next_cursor"].(string)
+			if next == "" {
+				cursor, _ = p["prev_cursor"].(string)
+				break
+			}
+			cursor = next
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("sort %q: cursor walk %v, want %v", sort, got, want)
+		}
+		for i := len(pages) - 2; i >= 0; i-- {
+			w = doJSON(r, "GET", base+"&cursor="+cursor, nil, u.ID)
+			data := responseData(t, w)
+			var titles []string
+			for _, task := range data["tasks"].([]interface{}) {
+				titles = append(titles, task.(map[string]interface{})["title"].(string))
+			}
+			if fmt.Sprint(titles) != pages[i] {
+				t.Fatalf("sort %q: page %d backwards is %v, want %s", sort, i, titles, pages[i])
+			}
+			cursor, _ = data["pagination"].(map[string]interface{})["prev_cursor"].(string)
+		}
+		if cursor != "" {
+			t.Fatalf("sort %q: prev_cursor on the first page", sort)
+		}
+	}
+
+	// new tasks don't shift a cursor walk, and cursors are tied to their sort
+	w := doJSON(r, "GET", "/api/tasks?page_size=4&cursor=", nil, u.ID)
+	next := responseData(t, w)["pagination"].(map[string]interface{})["next_cursor"].(string)
+	doJSON(r, "POST", "/api/tasks", gin.H{"title": "late"}, u.ID)
+	w = doJSON(r, "GET", "/api/tasks?page_size=4&cursor="+next, nil, u.ID)
+	if first := responseData(t, w)["tasks"].([]interface{})[0].(map[string]interface{})["title"]; first != "t06" {
+		t.Fatalf("second page starts at %v, want t06", first)
+	}
+	expectStatus(t, doJSON(r, "GET", "/api/tasks?sort=title&cursor="+next, nil, u.ID), http.StatusBadRequest)
+	expectStatus(t, doJSON(r, "GET", "/api/tasks?cursor="+next+"x", nil, u.ID), http.StatusBadRequest)
+}
+
+func TestGetTasksFilterQuery(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+
+	u := seedUser(t, "querier")
+	due := func(day string) time.Time {
+		d, _ := time.Parse("2006-01-02", day)
+		return d.Add(12 * time.Hour)
+	}
+	for _, in := range []gin.H{
+		{"title": "a", "priority": "high", "due_at": due("2026-10-30"), "tags": []string{"work"}},
+		{"title": "b", "priority": "high", "tags": []string{"someday"}},
+		{"title": "c", "priority": "low", "due_at": due("2026-11-05")},
+		{"title": "d 100%", "priority": "medium", "due_at": due("2026-11-01"), "description": "Call the bank"},
+	} {
+		expectStatus(t, doJSON(r, "POST", "/api/tasks", in, u.ID), http.StatusCreated)
+	}
+	titles := func(expr string) string {
+		t.Helper()
+		w := doJSON(r, "GET", "/api/tasks?sort=title&query="+url.QueryEscape(expr), nil, u.ID)
+		expectStatus(t, w, http.StatusOK)
+		var out []string
+		for _, task := range responseData(t, w)["tasks"].([]interface{}) {
+			out = append(out, task.(map[string]interface{})["title"].(string))
+		}
+		return strings.Join(out, ",")
+	}
+
+	for expr, want := range map[string]string{
+		`priority:high AND (status:pending OR due<2026-11-01) AND NOT tag:someday`: "a",
+		`priority>=medium`:                "a,b,d 100%",
+		`priority<high tag!=work`:         "c,d 100%",
+		`due:2026-11-01`:                  "d 100%",
+		`due<=2026-11-01`:                 "a,d 100%",
+		`due>2026-11-01 OR due:none`:      "b,c",
+		`NOT due<2026-11-01`:              "b,c,d 100%",
+		`due!=2026-11-01`:                 "a,b,c",
+		`title:"100%"`:                    "d 100%",
+		`title:"%"`:                       "d 100%",
+		`description:BANK`:                "d 100%",
+		`assignee:none AND project!=none`: "a,b,c,d 100%",
+		`assignee:me`:                     "",
+	} {
+		if got := titles(expr); got != want {
+			t.Errorf("%s: got %q want %q", expr, got, want)
+		}
+	}
+
+	for expr, pos := range map[string]float64{
+		`priority:urgent`:             1,
+		`status:pending AND owner:me`: 20,
+		`due<soon`:                    1,
+		`(status:pending`:             16,
+		`status<pending`:              1,
+	} {
+		w := doJSON(r, "GET", "/api/tasks?query="+url.QueryEscape(expr), nil, u.ID)
+		expectStatus(t, w, http.StatusBadRequest)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if got := resp["error"].(map[string]interface{})["position"]; got != pos {
+			t.Errorf("%s: error at %v, want %v: %s", expr, got, pos, w.Body.String())
+		}
+	}
+}
+
+func TestTaskETags(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks/:id", controllers.GetTask)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+	api.DELETE("/tasks/:id", controllers.DeleteTask)
+	api.POST("/tasks/:id/comments", controllers.CreateComment)
+
+	u := seedUser(t, "tabber")
+	send := func(method, path string, body interface{}, header, value string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", fmt.Sprint(u.ID))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/tasks", gin.H{"title": "shared"}, "", "")
+	expectStatus(t, w, http.StatusCreated)
+	path := fmt.Sprintf("/api/tasks/%v", responseData(t, w)["id"])
+	v1 := w.Header().Get("ETag")
+	if v1 == "" || responseData(t, w)["version"] != 1.0 {
+		t.Fatalf("new task: etag %q body %s", v1, w.Body.String())
+	}
+
+	w = send("GET", path, nil, "If-None-Match", v1)
+	expectStatus(t, w, http.StatusNotModified)
+	if w.Body.Len() != 0 {
+		t.Fatal("304 with a body")
+	}
+	expectStatus(t, send("GET", path, nil, "If-None-Match", `"0-0", W/`+v1), http.StatusNotModified)
+
+	// the first tab saves; the second, still holding v1, is refused
+	w = send("PATCH", path, gin.H{"title": "tab one"}, "If-Match", v1)
+	expectStatus(t, w, http.StatusOK)
+	v2 := w.Header().Get("ETag")
+	if v2 == v1 {
+		t.Fatal("etag unchanged by an update")
+	}
+	expectStatus(t, send("PATCH", path, gin.H{"title": "tab two"}, "If-Match", v1), http.StatusPreconditionFailed)
+	expectStatus(t, send("DELETE", path, nil, "If-Match", v1), http.StatusPreconditionFailed)
+	expectStatus(t, send("PATCH", path, gin.H{"title": "tab two"}, "If-Match", "W/"+v2), http.StatusPreconditionFailed)
+
+	w = send("GET", path, nil, "If-None-Match", v1)
+	expectStatus(t, w, http.StatusOK)
+	if responseData(t, w)["title"] != "tab one" || w.Header().Get("ETag") != v2 {
+		t.Fatalf("after conflict: %s", w.Body.String())
+	}
+
+	// a comment changes the body but not the version
+	expectStatus(t, send("POST", path+"/comments", gin.H{"body": "looks good"}, "", ""), http.StatusCreated)
+	w = send("GET", path, nil, "If-None-Match", v2)
+	expectStatus(t, w, http.StatusOK)
+	if responseData(t, w)["comment_count"] != 1.0 || w.Header().Get("ETag") == v2 {
+		t.Fatalf("after comment: etag %s body %s", w.Header().Get("ETag"), w.Body.String())
+	}
+	v3 := w.Header().Get("ETag")
+	expectStatus(t, send("GET", path, nil, "If-None-Match", v3), http.StatusNotModified)
+	expectStatus(t, send("PATCH", path, gin.H{"priority": "high"}, "If-Match", v2), http.StatusOK)
+	expectStatus(t, send("DELETE", path, nil, "If-Match", "*"), http.StatusOK)
+	expectStatus(t, send("GET", path, nil, "", ""), http.StatusNotFound)
+}
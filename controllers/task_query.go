@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/filter"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+)
+
+// filterErrorResponse writes a 400 for an invalid filter, with the
+// position of the problem for filter expression errors
+func filterErrorResponse(c *gin.Context, err error) {
+	var fe *filter.Error
+	if errors.As(err, &fe) {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fe.Msg, "position": fe.Pos})
+		return
+	}
+	helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+}
+
+// sqlCond is a parameterized SQL condition
+type sqlCond struct {
+	sql  string
+	args []interface{}
+}
+
+// translateFilter turns a filter expression into a parameterized condition
+// on tasks. Values only ever reach the database as arguments. Conditions
+// on optional columns are false rather than NULL for empty values, so NOT
+// behaves as expected. Dates are whole days in loc, the user's time zone.
+func translateFilter(n filter.Node, userID int64, loc *time.Location) (sqlCond, error) {
+	switch n := n.(type) {
+	case *filter.And, *filter.Or:
+		var l, r filter.Node
+		op := " AND "
+		if and, ok := n.(*filter.And); ok {
+			l, r = and.Left, and.Right
+		} else {
+			or := n.(*filter.Or)
+			l, r, op = or.Left, or.Right, " OR "
+		}
+		left, err := translateFilter(l, userID, loc)
+		if err != nil {
+			return left, err
+		}
+		right, err := translateFilter(r, userID, loc)
+		if err != nil {
+			return right, err
+		}
+		return sqlCond{"(" + left.sql + op + right.sql + ")", append(left.args, right.args...)}, nil
+	case *filter.Not:
+		x, err := translateFilter(n.X, userID, loc)
+		return sqlCond{"(NOT " + x.sql + ")", x.args}, err
+	case *filter.Cond:
+		return translateCond(n, userID, loc)
+	}
+	return sqlCond{}, errors.New("unknown filter node")
+}
+
+var sqlOps = map[string]string{":": "=", "=": "=", "!=": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+func translateCond(c *filter.Cond, userID int64, loc *time.Location) (sqlCond, error) {
+	fail := func(msg string) (sqlCond, error) { return sqlCond{}, &filter.Error{Pos: c.Pos, Msg: msg} }
+	equality := c.Op == ":" || c.Op == "=" || c.Op == "!="
+	value := strings.ToLower(c.Value)
+	if key, ok := strings.CutPrefix(c.Field, "cf."); ok {
+		return customFieldCond(c, key, userID, loc)
+	}
+
+	switch c.Field {
+	case "status":
+		if !equality {
+			return fail("status supports : and !=")
+		}
+		if !helpers.IsValidStatusKey(value) {
+			return fail("invalid status")
+		}
+		return sqlCond{"tasks.status " + sqlOps[c.Op] + " ?", []interface{}{value}}, nil
+
+	case "category":
+		if !equality {
+			return fail("category supports : and !=")
+		}
+		if !models.IsValidStatusCategory(value) {
+			return fail("category must be todo|doing|done")
+		}
+		return sqlCond{"tasks.status_category " + sqlOps[c.Op] + " ?", []interface{}{value}}, nil
+
+	case "priority":
+		rank := indexOf(models.ValidTaskPriorities, value)
+		if rank < 0 {
+			return fail("priority must be low|medium|high")
+		}
+		return sqlCond{rankExpr("tasks.priority", models.ValidTaskPriorities) + " " + sqlOps[c.Op] + " ?", []interface{}{rank + 1}}, nil
+
+	case "tag":
+		if !equality {
+			return fail("tag supports : and !=")
+		}
+		in := "IN"
+		if c.Op == "!=" {
+			in = "NOT IN"
+		}
+		return sqlCond{"tasks.id " + in + " (SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE tags.name = ?)",
+			[]interface{}{strings.TrimPrefix(value, "#")}}, nil
+
+	case "assignee", "project":
+		if !equality {
+			return fail(c.Field + " supports : and !=")
+		}
+		column := "tasks." + c.Field + "_id"
+		var id int64
+		switch {
+		case value == "none":
+			if c.Op == "!=" {
+				return sqlCond{column + " IS NOT NULL", nil}, nil
+			}
+			return sqlCond{column + " IS NULL", nil}, nil
+		case value == "me" && c.Field == "assignee":
+			id = userID
+		default:
+			var err error
+			if id, err = strconv.ParseInt(value, 10, 64); err != nil {
+				if c.Field == "assignee" {
+					return fail("assignee must be me|none|<user id>")
+				}
+				return fail("project must be none|<project id>")
+			}
+		}
+		return nullableCond(column, c.Op, id), nil
+
+	case "due", "due_at", "created", "created_at", "updated", "updated_at":
+		column := "tasks." + strings.TrimSuffix(c.Field, "_at") + "_at"
+		if value == "none" {
+			switch {
+			case column == "tasks.created_at":
+				return fail("created is never empty")
+			case c.Op == ":" || c.Op == "=":
+				return sqlCond{column + " IS NULL", nil}, nil
+			case c.Op == "!=":
+				return sqlCond{column + " IS NOT NULL", nil}, nil
+			}
+			return fail("none only supports : and !=")
+		}
+		return dateCond(c, column, time.Now().In(loc))
+
+	case "blocked":
+		if !equality || value != "true" && value != "false" {
+			return fail("blocked is true or false, compared with : or !=")
+		}
+		return sqlCond{blockedCond((value == "true") != (c.Op == "!=")), nil}, nil
+
+	case "title", "description":
+		column := "tasks." + c.Field
+		switch c.Op {
+		case ":":
+			return sqlCond{"LOWER(" + column + ") LIKE ? ESCAPE '\\'", []interface{}{"%" + escapeLike(value) + "%"}}, nil
+		case "=", "!=":
+			return sqlCond{column + " " + sqlOps[c.Op] + " ?", []interface{}{c.Value}}, nil
+		}
+		return fail(c.Field + " supports : (contains), = and !=")
+	}
+	return fail("unknown field " + strconv.Quote(c.Field) +
+		"; use status, category, priority, tag, assignee, project, due, created, updated, blocked, title, description or cf.<custom field>")
+}
+
+// customFieldCond compares the custom field key of tasks' projects. How
+// depends on the field's type, so the key must have one type across the
+// projects the user can see. Like tags, != also matches tasks without a
+// value.
+func customFieldCond(c *filter.Cond, key string, userID int64, loc *time.Location) (sqlCond, error) {
+	fail := func(msg string) (sqlCond, error) { return sqlCond{}, &filter.Error{Pos: c.Pos, Msg: msg} }
+	var types []string
+	if err := config.DB.Model(&models.CustomField{}).
+		Where("custom_fields.key = ? AND (custom_fields.project_id IN (SELECT id FROM projects WHERE user_id = ?)"+
+			" OR custom_fields.project_id IN (SELECT project_id FROM project_members WHERE user_id = ?))", key, userID, userID).
+		Distinct().Pluck("type", &types).Error; err != nil {
+		return sqlCond{}, err
+	}
+	switch len(types) {
+	case 0:
+		return fail("unknown custom field " + strconv.Quote(key))
+	case 1:
+	default:
+		return fail("custom field " + strconv.Quote(key) + " has a different type in different projects")
+	}
+	fieldType := types[0]
+	exists := func(cond sqlCond, negate bool) sqlCond {
+		sql := "EXISTS (SELECT 1 FROM task_field_values v JOIN custom_fields f ON f.id = v.field_id" +
+			" WHERE v.task_id = tasks.id AND f.project_id = tasks.project_id AND f.key = ? AND f.type = ?"
+		if cond.sql != "" {
+			sql += " AND " + cond.sql
+		}
+		sql += ")"
+		if negate {
+			sql = "NOT " + sql
+		}
+		return sqlCond{sql, append([]interface{}{key, fieldType}, cond.args...)}
+	}
+
+	value := strings.ToLower(c.Value)
+	equality := c.Op == ":" || c.Op == "=" || c.Op == "!="
+	negate := c.Op == "!="
+	if value == "none" {
+		if !equality {
+			return fail("none only supports : and !=")
+		}
+		return exists(sqlCond{}, !negate), nil
+	}
+	switch fieldType {
+	case models.FieldTypeNumber:
+		n, err := strconv.ParseFloat(c.Value, 64)
+		if err != nil {
+			return fail(key + " is a number")
+		}
+		if negate {
+			return exists(sqlCond{"v.number = ?", []interface{}{n}}, true), nil
+		}
+		return exists(sqlCond{"v.number " + sqlOps[c.Op] + " ?", []interface{}{n}}, false), nil
+
+	case models.FieldTypeDate:
+		// custom dates are calendar dates stored as midnight UTC, compared
+		// with the user's today on the same calendar
+		y, m, d := time.Now().In(loc).Date()
+		now := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		if negate {
+			eq := *c
+			eq.Op = ":"
+			cond, err := dateCond(&eq, "v.date", now)
+			return exists(cond, true), err
+		}
+		cond, err := dateCond(c, "v.date", now)
+		return exists(cond, false), err
+
+	case models.FieldTypeCheckbox:
+		if !equality || value != "true" && value != "false" {
+			return fail(key + " is true or false, compared with : or !=")
+		}
+		return exists(sqlCond{"v.number = 1", nil}, (value == "false") != negate), nil
+
+	case models.FieldTypeSelect:
+		if !equality {
+			return fail(key + " supports : and !=")
+		}
+		return exists(sqlCond{"LOWER(v.text) = ?", []interface{}{value}}, negate), nil
+
+	case models.FieldTypeMultiSelect:
+		if !equality {
+			return fail(key + " supports : (includes) and !=")
+		}
+		return exists(sqlCond{"LOWER(v.text) LIKE ? ESCAPE '\\'", []interface{}{"%" + escapeLike(jsonString(value)) + "%"}}, negate), nil
+	}
+
+	// text and url
+	switch c.Op {
+	case ":":
+		return exists(sqlCond{"LOWER(v.text) LIKE ? ESCAPE '\\'", []interface{}{"%" + escapeLike(value) + "%"}}, false), nil
+	case "=", "!=":
+		return exists(sqlCond{"v.text = ?", []interface{}{c.Value}}, negate), nil
+	}
+	return fail(key + " supports : (contains), = and !=")
+}
+
+// nullableCond compares an optional column; NULL never equals a value and
+// always differs from it
+func nullableCond(column, op string, v interface{}) sqlCond {
+	if op == "!=" {
+		return sqlCond{"(" + column + " IS NULL OR " + column + " <> ?)", []interface{}{v}}
+	}
+	return sqlCond{"(" + column + " IS NOT NULL AND " + column + " " + sqlOps[op] + " ?)", []interface{}{v}}
+}
+
+// relativeDay resolves today, tomorrow, yesterday and today+N / today-N
+// (days) to the start of that day in now's location
+func relativeDay(v string, now time.Time) (time.Time, bool) {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	switch v {
+	case "today":
+		return today, true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	}
+	rest, ok := strings.CutPrefix(v, "today")
+	if !ok || len(rest) < 2 || rest[0] != '+' && rest[0] != '-' {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(rest)
+	if err != nil || n < -3650 || n > 3650 {
+		return time.Time{}, false
+	}
+	return today.AddDate(0, 0, n), true
+}
+
+// dateCond compares a time column with a date (a whole day in now's
+// location, possibly relative to now) or an RFC 3339 timestamp
+func dateCond(c *filter.Cond, column string, now time.Time) (sqlCond, error) {
+	if t, err := time.Parse(time.RFC3339, c.Value); err == nil {
+		return nullableCond(column, c.Op, t.UTC()), nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", c.Value, now.Location())
+	if err != nil {
+		var ok bool
+		if day, ok = relativeDay(strings.ToLower(c.Value), now); !ok {
+			return sqlCond{}, &filter.Error{Pos: c.Pos, Msg: "dates must be YYYY-MM-DD, today[+-N], tomorrow, yesterday, an RFC 3339 time or none"}
+		}
+	}
+	next := day.AddDate(0, 0, 1).UTC()
+	day = day.UTC()
+	switch c.Op {
+	case ":", "=":
+		return sqlCond{"(" + column + " IS NOT NULL AND " + column + " >= ? AND " + column + " < ?)", []interface{}{day, next}}, nil
+	case "!=":
+		return sqlCond{"(" + column + " IS NULL OR " + column + " < ? OR " + column + " >= ?)", []interface{}{day, next}}, nil
+	case "<", ">=":
+		return nullableCond(column, c.Op, day), nil
+	case "<=":
+		return nullableCond(column, "<", next), nil
+	default: // >
+		return nullableCond(column, ">=", next), nil
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func indexOf(list []string, v string) int {
+	for i, s := range list {
+		if s == v {
+			return i
+		}
+	}
+	return -1
+}
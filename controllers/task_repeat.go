@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/models"
+	"go-todo-app/recurrence"
+	"gorm.io/gorm"
+)
+
+// normalizeRecurrence checks a task's repeat rule and returns it in its
+// canonical form; empty means the task doesn't repeat
+func normalizeRecurrence(rule string) (string, error) {
+	if rule == "" {
+		return "", nil
+	}
+	return recurrence.Normalize(rule)
+}
+
+// repeatTask creates the next occurrence of a recurring task that was just
+// finished: a copy in the first todo status with its checklist unchecked,
+// due on the rule's first date after the old due date that is still ahead.
+// The finished task gives its rule up to the copy, so reopening and
+// finishing it again doesn't repeat twice. Dates follow the owner's time
+// zone.
+func repeatTask(tx *gorm.DB, c *gin.Context, rule string, done models.Task) error {
+	if rule == "" {
+		return nil
+	}
+	r, err := recurrence.Parse(rule)
+	if err != nil {
+		return err
+	}
+	loc, err := userLocation(tx, done.UserID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().In(loc)
+	base := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if done.DueAt != nil {
+		base = done.DueAt.In(loc)
+	}
+	due := r.After(base, now).UTC()
+
+	workflow, err := taskWorkflow(tx, done)
+	if err != nil {
+		return err
+	}
+	status := firstInCategory(workflow, models.StatusCategoryTodo)
+	tasks := []models.Task{done}
+	if err := loadTags(tx, tasks); err != nil {
+		return err
+	}
+	next := models.Task{
+		UserID:         done.UserID,
+		ProjectID:      done.ProjectID,
+		AssigneeID:     done.AssigneeID,
+		ParentID:       done.ParentID,
+		Title:          done.Title,
+		Description:    done.Description,
+		Priority:       done.Priority,
+		Status:         status.Key,
+		StatusCategory: status.Category,
+		DueAt:          &due,
+		Version:        1,
+		Tags:           tasks[0].Tags,
+
+		EstimateMinutes: done.EstimateMinutes,
+		Recurrence:      rule,
+		AutoComplete:    done.AutoComplete,
+		Starred:         done.Starred,
+		Pinned:          done.Pinned,
+	}
+	position, err := endPosition(tx, next)
+	if err != nil {
+		return err
+	}
+	next.Position = position
+	if err := tx.Create(&next).Error; err != nil {
+		return err
+	}
+	if err := setTaskTags(tx, next.ID, next.Tags); err != nil {
+		return err
+	}
+	var values []models.TaskFieldValue
+	if err := tx.Where("task_id = ?", done.ID).Find(&values).Error; err != nil {
+		return err
+	}
+	if err := setTaskFields(tx, next.ID, values); err != nil {
+		return err
+	}
+	var texts []string
+	if err := tx.Model(&models.ChecklistItem{}).Where("task_id = ?", done.ID).Order("position, id").
+		Pluck("text", &texts).Error; err != nil {
+		return err
+	}
+	if _, err := addChecklistItems(tx, next.ID, texts); err != nil {
+		return err
+	}
+	return recordTaskActivity(tx, c, nil, &next)
+}
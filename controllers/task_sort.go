@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+)
+
+const MaxSortFields = 5
+
+// sortField describes a column clients may sort tasks by. expr refers to
+// the tasks table as {t} so it can be applied to a cursor row too.
+type sortField struct {
+	expr     string
+	nullable bool
+}
+
+// taskSortFields whitelists the sort keys. Priority and status sort by
+// meaning rather than alphabetically: ascending is low→high and, by status
+// category, todo→doing→done.
+var taskSortFields = map[string]sortField{
+	"id":               {expr: "{t}.id"},
+	"title":            {expr: "{t}.title"},
+	"priority":         {expr: rankExpr("{t}.priority", models.ValidTaskPriorities)},
+	"status":           {expr: rankExpr("{t}.status_category", models.ValidStatusCategories)},
+	"position":         {expr: "{t}.position"},
+	"created_at":       {expr: "{t}.created_at"},
+	"updated_at":       {expr: "{t}.updated_at", nullable: true},
+	"due_at":           {expr: "{t}.due_at", nullable: true},
+	"estimate_minutes": {expr: "{t}.estimate_minutes", nullable: true},
+	"project_id":       {expr: "{t}.project_id", nullable: true},
+	"assignee_id":      {expr: "{t}.assignee_id", nullable: true},
+}
+
+// rankExpr maps values to their index in order, unknown values first
+func rankExpr(column string, order []string) string {
+	var b strings.Builder
+	b.WriteString("CASE " + column)
+	for i, v := range order {
+		fmt.Fprintf(&b, " WHEN '%s' THEN %d", v, i+1)
+	}
+	b.WriteString(" ELSE 0 END")
+	return b.String()
+}
+
+// customFieldSort sorts by the value of a custom field. A field's values
+// are all in the column for its type, so sorting by each column in turn
+// sorts by the value whatever the type is.
+func customFieldSort(key string) ([]sortField, bool) {
+	if !helpers.IsValidFieldKey(key) {
+		return nil, false
+	}
+	var fields []sortField
+	for _, column := range []string{"number", "date", "text"} {
+		fields = append(fields, sortField{
+			expr: fmt.Sprintf("(SELECT v.%s FROM task_field_values v JOIN custom_fields f ON f.id = v.field_id"+
+				" WHERE v.task_id = {t}.id AND f.project_id = {t}.project_id AND f.key = '%s')", column, key),
+			nullable: true,
+		})
+	}
+	return fields, true
+}
+
+// sortTerm is one ORDER BY expression
+type sortTerm struct {
+	expr     string
+	desc     bool
+	nullable bool
+}
+
+func (t sortTerm) on(table string) string {
+	return strings.ReplaceAll(t.expr, "{t}", table)
+}
+
+// taskSort is a parsed sort parameter. key identifies it in cursors.
+type taskSort struct {
+	key   string
+	terms []sortTerm
+}
+
+// parseTaskSort parses a sort parameter like "-priority,due_at". A leading
+// "-" sorts descending; nulls is "first" or "last" (the default) for fields
+// that may be empty. The task id is added as a final tie-breaker so the
+// order is total and pages are stable.
+func parseTaskSort(param, nulls string) (taskSort, error) {
+	if param == "" {
+		param = "-id"
+	}
+	if param == "manual" {
+		param = "position"
+	}
+	nullsFirst := false
+	switch nulls {
+	case "", "last":
+	case "first":
+		nullsFirst = true
+	default:
+		return taskSort{}, errors.New("nulls must be first|last")
+	}
+
+	s := taskSort{key: param + ";nulls=" + nulls}
+	seen := map[string]bool{}
+	for _, key := range strings.Split(param, ",") {
+		key = strings.TrimSpace(key)
+		desc := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+		f, ok := taskSortFields[key]
+		fields := []sortField{f}
+		if field, custom := strings.CutPrefix(key, "cf."); custom {
+			fields, ok = customFieldSort(field)
+		}
+		if !ok {
+			return s, fmt.Errorf("cannot sort by %q; use %s|cf.<custom field>", key, strings.Join(sortableTaskFields(), "|"))
+		}
+		if seen[key] {
+			return s, fmt.Errorf("%q given more than once", key)
+		}
+		seen[key] = true
+		for _, f := range fields {
+			if f.nullable {
+				// portable NULLS FIRST/LAST
+				s.terms = append(s.terms, sortTerm{expr: fmt.Sprintf("CASE WHEN %s IS NULL THEN 1 ELSE 0 END", f.expr), desc: nullsFirst})
+			}
+			s.terms = append(s.terms, sortTerm{expr: f.expr, desc: desc, nullable: f.nullable})
+		}
+	}
+	if len(seen) > MaxSortFields {
+		return s, fmt.Errorf("at most %d sort fields", MaxSortFields)
+	}
+	if !seen["id"] {
+		s.terms = append(s.terms, sortTerm{expr: "{t}.id", desc: true})
+	}
+	return s, nil
+}
+
+// pinnedFirst returns the order with pinned tasks moved to the front
+func (s taskSort) pinnedFirst() taskSort {
+	s.key = "pinned," + s.key
+	s.terms = append([]sortTerm{{expr: "CASE WHEN {t}.pinned THEN 0 ELSE 1 END"}}, s.terms...)
+	return s
+}
+
+// orderBy returns the ORDER BY clause, or its reverse for walking backwards
+func (s taskSort) orderBy(reverse bool) string {
+	clauses := make([]string, len(s.terms))
+	for i, t := range s.terms {
+		dir := "asc"
+		if t.desc != reverse {
+			dir = "desc"
+		}
+		clauses[i] = t.on("tasks") + " " + dir
+	}
+	return strings.Join(clauses, ", ")
+}
+
+// after returns a condition matching the tasks that come after the task
+// joined as cur in this order, or before it when reverse is set
+func (s taskSort) after(cur string, reverse bool) string {
+	var ors []string
+	var eqs []string
+	for _, t := range s.terms {
+		a, b := t.on("tasks"), t.on(cur)
+		op := ">"
+		if t.desc != reverse {
+			op = "<"
+		}
+		ors = append(ors, "("+strings.Join(append(eqs, fmt.Sprintf("%s %s %s", a, op, b)), " AND ")+")")
+		eq := fmt.Sprintf("%s = %s", a, b)
+		if t.nullable {
+			eq = fmt.Sprintf("(%s OR (%s IS NULL AND %s IS NULL))", eq, a, b)
+		}
+		eqs = append(eqs, eq)
+	}
+	return "(" + strings.Join(ors, " OR ") + ")"
+}
+
+func sortableTaskFields() []string {
+	keys := make([]string, 0, len(taskSortFields))
+	for k := range taskSortFields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
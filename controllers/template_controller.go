@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxTemplateTasks    = 100
+	MaxTemplateDepth    = 4
+	MaxDueOffsetDays    = 3650
+	MaxTemplateVariable = 200
+)
+
+// checkTemplateTasks validates a template's task tree in place, trimming
+// titles and normalizing priorities and tags, and returns how many tasks
+// it holds
+func checkTemplateTasks(tasks []models.TemplateTask, depth int) (int, error) {
+	if depth > MaxTemplateDepth {
+		return 0, fmt.Errorf("subtasks can nest at most %d levels deep", MaxTemplateDepth)
+	}
+	n := 0
+	for i := range tasks {
+		t := &tasks[i]
+		t.Title = strings.TrimSpace(t.Title)
+		if t.Title == "" {
+			return 0, errors.New("every task needs a title")
+		}
+		t.Priority = strings.ToLower(strings.TrimSpace(t.Priority))
+		if t.Priority != "" && !models.IsValidPriority(t.Priority) {
+			return 0, fmt.Errorf("%q: priority must be low|medium|high", t.Title)
+		}
+		tags, err := normalizeTags(t.Tags)
+		if err != nil {
+			return 0, fmt.Errorf("%q: %v", t.Title, err)
+		}
+		t.Tags = tags
+		if err := checkEstimate(t.EstimateMinutes); err != nil {
+			return 0, fmt.Errorf("%q: %v", t.Title, err)
+		}
+		if d := t.DueOffsetDays; d != nil && (*d < -MaxDueOffsetDays || *d > MaxDueOffsetDays) {
+			return 0, fmt.Errorf("%q: due_offset_days must be between -%d and %d", t.Title, MaxDueOffsetDays, MaxDueOffsetDays)
+		}
+		sub, err := checkTemplateTasks(t.Subtasks, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		n += 1 + sub
+	}
+	return n, nil
+}
+
+// templateVariables lists the placeholders used in the tasks' titles and
+// descriptions, in order of first appearance
+func templateVariables(tasks []models.TemplateTask) []string {
+	var text strings.Builder
+	var walk func([]models.TemplateTask)
+	walk = func(tasks []models.TemplateTask) {
+		for _, t := range tasks {
+			text.WriteString(t.Title + "\n" + t.Description + "\n")
+			walk(t.Subtasks)
+		}
+	}
+	walk(tasks)
+	vars := helpers.ParsePlaceholders(text.String())
+	if vars == nil {
+		vars = []string{}
+	}
+	return vars
+}
+
+type templateInput struct {
+	Name        string                `json:"name" binding:"required"`
+	Description string                `json:"description"`
+	Tasks       []models.TemplateTask `json:"tasks" binding:"required"`
+}
+
+// applyTo validates the input and stores it in t, writing the error
+// response itself
+func (in templateInput) applyTo(c *gin.Context, t *models.Template) bool {
+	t.Name = strings.TrimSpace(in.Name)
+	if t.Name == "" || len(t.Name) > 100 {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "name must be 1-100 characters"})
+		return false
+	}
+	n, err := checkTemplateTasks(in.Tasks, 1)
+	if err == nil && n == 0 {
+		err = errors.New("a template needs at least one task")
+	}
+	if err == nil && n > MaxTemplateTasks {
+		err = fmt.Errorf("a template can have at most %d tasks", MaxTemplateTasks)
+	}
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return false
+	}
+	t.Description = strings.TrimSpace(in.Description)
+	t.Tasks = in.Tasks
+	t.Variables = templateVariables(t.Tasks)
+	return true
+}
+
+// templateNameTaken reports whether the user has another template called
+// name
+func templateNameTaken(t models.Template) (bool, error) {
+	var n int64
+	err := config.DB.Model(&models.Template{}).
+		Where("user_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", t.UserID, t.Name, t.ID).Count(&n).Error
+	return n > 0, err
+}
+
+// findTemplate loads one of the user's templates named in the URL, writing
+// the error response itself
+func findTemplate(c *gin.Context) (models.Template, bool) {
+	uid, _ := c.Get("user_id")
+	var t models.Template
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid template id"})
+		return t, false
+	}
+	if err := config.DB.Where("id = ? AND user_id = ?", id, uid.(int64)).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "template not found"})
+		} else {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		}
+		return t, false
+	}
+	t.Variables = templateVariables(t.Tasks)
+	return t, true
+}
+
+func GetTemplates(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var templates []models.Template
+	if err := config.DB.Where("user_id = ?", uid.(int64)).Order("name asc, id asc").Find(&templates).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	for i := range templates {
+		templates[i].Variables = templateVariables(templates[i].Tasks)
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"templates": templates})
+}
+
+func GetTemplate(c *gin.Context) {
+	t, ok := findTemplate(c)
+	if !ok {
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", t)
+}
+
+func CreateTemplate(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var in templateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	t := models.Template{UserID: uid.(int64)}
+	if !in.applyTo(c, &t) {
+		return
+	}
+	taken, err := templateNameTaken(t)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if taken {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": "a template with this name already exists"})
+		return
+	}
+	if err := config.DB.Create(&t).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create template"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusCreated, "Template created", t)
+}
+
+// UpdateTemplate replaces a template's name, description and tasks
+func UpdateTemplate(c *gin.Context) {
+	t, ok := findTemplate(c)
+	if !ok {
+		return
+	}
+	var in templateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if !in.applyTo(c, &t) {
+		return
+	}
+	taken, err := templateNameTaken(t)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if taken {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": "a template with this name already exists"})
+		return
+	}
+	if err := config.DB.Save(&t).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update template"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Template updated", t)
+}
+
+func DeleteTemplate(c *gin.Context) {
+	t, ok := findTemplate(c)
+	if !ok {
+		return
+	}
+	if err := config.DB.Delete(&t).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete template"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Template deleted", gin.H{"id": t.ID})
+}
+
+// plannedTask is a template task ready to be created: placeholders filled
+// in and the due date resolved. parent indexes the plan, -1 for top level.
+type plannedTask struct {
+	task   models.Task
+	parent int
+}
+
+// planTemplate flattens the task tree depth first, parents before their
+// subtasks
+func planTemplate(tasks []models.TemplateTask, parent int, start time.Time, vars map[string]string, plan []plannedTask) ([]plannedTask, error) {
+	for _, t := range tasks {
+		title := strings.TrimSpace(helpers.FillPlaceholders(t.Title, vars))
+		if title == "" || len(title) > 255 {
+			return nil, fmt.Errorf("%q: titles must be 1-255 characters once filled in", t.Title)
+		}
+		task := models.Task{
+			Title:           title,
+			Description:     strings.TrimSpace(helpers.FillPlaceholders(t.Description, vars)),
+			Priority:        t.Priority,
+			EstimateMinutes: t.EstimateMinutes,
+			Tags:            append([]string{}, t.Tags...),
+		}
+		if t.DueOffsetDays != nil {
+			due := start.AddDate(0, 0, *t.DueOffsetDays).UTC()
+			task.DueAt = &due
+		}
+		plan = append(plan, plannedTask{task: task, parent: parent})
+		var err error
+		if plan, err = planTemplate(t.Subtasks, len(plan)-1, start, vars, plan); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// InstantiateTemplate creates the template's tasks in a project, all or
+// none. Due dates are set relative to start_date (today by default) and
+// every variable the template uses must be given.
+func InstantiateTemplate(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	t, ok := findTemplate(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		ProjectID *int64            `json:"project_id"`
+		StartDate string            `json:"start_date"` // YYYY-MM-DD or an RFC 3339 time
+		Variables map[string]string `json:"variables"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&in); err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+			return
+		}
+	}
+	var missing []string
+	for _, v := range t.Variables {
+		if _, ok := in.Variables[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "variables missing", "missing": missing})
+		return
+	}
+	for name, value := range in.Variables {
+		if indexOf(t.Variables, name) < 0 {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fmt.Sprintf("the template has no variable %q", name)})
+			return
+		}
+		if len(value) > MaxTemplateVariable {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": fmt.Sprintf("variables can be at most %d characters", MaxTemplateVariable)})
+			return
+		}
+	}
+	loc, err := userLocation(config.DB, uid.(int64))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	start, _ := relativeDay("today", time.Now().In(loc))
+	if in.StartDate != "" {
+		if start, err = time.ParseInLocation("2006-01-02", in.StartDate, loc); err != nil {
+			if start, err = time.Parse(time.RFC3339, in.StartDate); err != nil {
+				helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "start_date must be YYYY-MM-DD or an RFC 3339 time"})
+				return
+			}
+		}
+	}
+	plan, err := planTemplate(t.Tasks, -1, start, in.Variables, nil)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+
+	project, err := resolveTaskProject(config.DB, uid.(int64), in.ProjectID)
+	if err != nil {
+		projectErrorResponse(c, err)
+		return
+	}
+	workflow, _, err := projectWorkflow(config.DB, project)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	status := firstInCategory(workflow, models.StatusCategoryTodo)
+	tasks := make([]models.Task, len(plan))
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for i, p := range plan {
+			task := p.task
+			task.UserID = uid.(int64)
+			task.ProjectID = &project.ID
+			task.Status, task.StatusCategory = status.Key, status.Category
+			task.Version = 1
+			if task.Priority == "" {
+				task.Priority = project.DefaultPriority
+			}
+			if p.parent >= 0 {
+				task.ParentID = &tasks[p.parent].ID
+			}
+			position, err := endPosition(tx, task)
+			if err != nil {
+				return err
+			}
+			task.Position = position
+			if err := tx.Create(&task).Error; err != nil {
+				return err
+			}
+			if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+				return err
+			}
+			if err := recordTaskActivity(tx, c, nil, &task); err != nil {
+				return err
+			}
+			tasks[i] = task
+		}
+		return nil
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create tasks"})
+		return
+	}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusCreated, "Template instantiated", gin.H{"template_id": t.ID, "tasks": tasks})
+}
@@ -0,0 +1,122 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func TestTemplates(t *testing.T) {
+	r, api := newTestAPI()
+	api.GET("/templates", controllers.GetTemplates)
+	api.POST("/templates", controllers.CreateTemplate)
+	api.GET("/templates/:id", controllers.GetTemplate)
+	api.PUT("/templates/:id", controllers.UpdateTemplate)
+	api.DELETE("/templates/:id", controllers.DeleteTemplate)
+	api.POST("/templates/:id/instantiate", controllers.InstantiateTemplate)
+	api.GET("/tasks", controllers.GetTasks)
+
+	user := seedUser(t, "planner")
+	other := seedUser(t, "stranger")
+	project := models.Project{UserID: user.ID, Name: "Releases", DefaultPriority: models.TaskPriorityLow}
+	config.DB.Create(&project)
+
+	onboarding := gin.H{
+		"name": "Release",
+		"tasks": []gin.H{{
+			"title":           "Release {{version}}",
+			"priority":        "HIGH",
+			"due_offset_days": 7,
+			"tags":            []string{"Release"},
+			"subtasks": []gin.H{
+				{"title": "Changelog for {{ version }}", "due_offset_days": 5, "estimate_minutes": 30},
+				{"title": "Announce", "description": "Post in {{channel}}"},
+			},
+		}},
+	}
+	w := doJSON(r, "POST", "/api/templates", onboarding, user.ID)
+	expectStatus(t, w, http.StatusCreated)
+	tpl := responseData(t, w)
+	if fmt.Sprint(tpl["variables"]) != "[version channel]" {
+		t.Fatalf("variables=%v", tpl["variables"])
+	}
+	path := fmt.Sprintf("/api/templates/%v", tpl["id"])
+	expectStatus(t, doJSON(r, "POST", "/api/templates", gin.H{"name": "release", "tasks": []gin.H{{"title": "x"}}}, user.ID), http.StatusConflict)
+	for _, bad := range []gin.H{
+		{"name": "empty", "tasks": []gin.H{}},
+		{"name": "untitled", "tasks": []gin.H{{"title": " "}}},
+		{"name": "urgent", "tasks": []gin.H{{"title": "x", "priority": "urgent"}}},
+		{"name": "far", "tasks": []gin.H{{"title": "x", "due_offset_days": 5000}}},
+		{"name": "deep", "tasks": []gin.H{{"title": "1", "subtasks": []gin.H{{"title": "2", "subtasks": []gin.H{{"title": "3", "subtasks": []gin.H{{"title": "4", "subtasks": []gin.H{{"title": "5"}}}}}}}}}}},
+	} {
+		if w := doJSON(r, "POST", "/api/templates", bad, user.ID); w.Code != http.StatusBadRequest {
+			t.Errorf("%v: status %d want 400", bad["name"], w.Code)
+		}
+	}
+	expectStatus(t, doJSON(r, "GET", path, nil, other.ID), http.StatusNotFound)
+
+	// every variable must be given, and only those
+	instantiate := func(body gin.H) *httptest.ResponseRecorder {
+		return doJSON(r, "POST", path+"/instantiate", body, user.ID)
+	}
+	w = instantiate(gin.H{"project_id": project.ID, "variables": gin.H{"version": "2.0"}})
+	expectStatus(t, w, http.StatusBadRequest)
+	if body := w.Body.String(); !strings.Contains(body, `"missing":["channel"]`) {
+		t.Fatalf("body=%s", body)
+	}
+	expectStatus(t, instantiate(gin.H{"project_id": project.ID, "variables": gin.H{"version": "2.0", "channel": "#news", "owner": "me"}}), http.StatusBadRequest)
+
+	w = instantiate(gin.H{"project_id": project.ID, "start_date": "2026-05-01", "variables": gin.H{"version": "2.0", "channel": "#news"}})
+	expectStatus(t, w, http.StatusCreated)
+	tasks := responseData(t, w)["tasks"].([]interface{})
+	if len(tasks) != 3 {
+		t.Fatalf("tasks=%v", tasks)
+	}
+	root := tasks[0].(map[string]interface{})
+	if root["title"] != "Release 2.0" || root["priority"] != "high" || root["due_at"] != "2026-05-08T00:00:00Z" || fmt.Sprint(root["tags"]) != "[release]" {
+		t.Fatalf("root=%v", root)
+	}
+	changelog, announce := tasks[1].(map[string]interface{}), tasks[2].(map[string]interface{})
+	if changelog["title"] != "Changelog for 2.0" || changelog["parent_id"] != root["id"] || changelog["priority"] != "low" || changelog["estimate_minutes"] != float64(30) {
+		t.Fatalf("changelog=%v", changelog)
+	}
+	if announce["description"] != "Post in #news" || announce["due_at"] != nil || announce["parent_id"] != root["id"] {
+		t.Fatalf("announce=%v", announce)
+	}
+	w = doJSON(r, "GET", fmt.Sprintf("/api/tasks?project_id=%d&parent_id=%v", project.ID, root["id"]), nil, user.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 2 {
+		t.Fatalf("subtasks=%d", n)
+	}
+
+	// start dates are days in the user's time zone
+	config.DB.Model(&user).Update("time_zone", "Pacific/Kiritimati")
+	loc, _ := time.LoadLocation("Pacific/Kiritimati")
+	y, m, d := time.Now().In(loc).Date()
+	for start, due := range map[string]string{
+		"2026-05-01": "2026-05-07T10:00:00Z",
+		"":           time.Date(y, m, d+7, 0, 0, 0, 0, loc).UTC().Format(time.RFC3339),
+	} {
+		w = instantiate(gin.H{"project_id": project.ID, "start_date": start, "variables": gin.H{"version": "2.1", "channel": "#news"}})
+		expectStatus(t, w, http.StatusCreated)
+		if got := responseData(t, w)["tasks"].([]interface{})[0].(map[string]interface{})["due_at"]; got != due {
+			t.Errorf("start %q: due_at=%v want %s", start, got, due)
+		}
+	}
+
+	// updates replace the tree; the template can then be removed
+	w = doJSON(r, "PUT", path, gin.H{"name": "Hotfix", "tasks": []gin.H{{"title": "Patch"}}}, user.ID)
+	expectStatus(t, w, http.StatusOK)
+	if vars := responseData(t, w)["variables"].([]interface{}); len(vars) != 0 {
+		t.Fatalf("variables=%v", vars)
+	}
+	expectStatus(t, doJSON(r, "DELETE", path, nil, user.ID), http.StatusOK)
+	expectStatus(t, doJSON(r, "GET", path, nil, user.ID), http.StatusNotFound)
+}
@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxEstimateMinutes  = 100000
+	MaxTimeEntryMinutes = 24 * 60
+)
+
+// checkEstimate validates a task's estimate_minutes
+func checkEstimate(minutes *int) error {
+	if minutes != nil && (*minutes < 0 || *minutes > MaxEstimateMinutes) {
+		return fmt.Errorf("estimate_minutes must be between 0 and %d", MaxEstimateMinutes)
+	}
+	return nil
+}
+
+// entrySeconds is the time an entry covers; a running timer counts up to now
+func entrySeconds(e models.TimeEntry, now time.Time) int64 {
+	if e.EndedAt == nil {
+		return int64(now.Sub(e.StartedAt) / time.Second)
+	}
+	return e.Seconds
+}
+
+// loadTrackedTime fills TrackedMinutes for a page of tasks
+func loadTrackedTime(tx *gorm.DB, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	var rows []struct {
+		TaskID  int64
+		Seconds int64
+	}
+	if err := tx.Model(&models.TimeEntry{}).Select("task_id, SUM(seconds) AS seconds").
+		Where("task_id IN ? AND ended_at IS NOT NULL", ids).Group("task_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+	var running []models.TimeEntry
+	if err := tx.Where("task_id IN ? AND ended_at IS NULL", ids).Find(&running).Error; err != nil {
+		return err
+	}
+	seconds := make(map[int64]int64, len(rows))
+	for _, r := range rows {
+		seconds[r.TaskID] = r.Seconds
+	}
+	now := time.Now()
+	for _, e := range running {
+		seconds[e.TaskID] += entrySeconds(e, now)
+	}
+	for i := range tasks {
+		tasks[i].TrackedMinutes = seconds[tasks[i].ID] / 60
+	}
+	return nil
+}
+
+// runningTimer returns the user's running timer, or nil
+func runningTimer(tx *gorm.DB, userID int64) (*models.TimeEntry, error) {
+	var e models.TimeEntry
+	err := tx.Where("user_id = ? AND ended_at IS NULL", userID).First(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// stopTimer ends a running timer now
+func stopTimer(tx *gorm.DB, e *models.TimeEntry) error {
+	now := time.Now()
+	e.Seconds = entrySeconds(*e, now)
+	e.EndedAt = &now
+	e.UpdatedAt = &now
+	return tx.Save(e).Error
+}
+
+// GetTimer returns the user's running timer, if any
+func GetTimer(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	timer, err := runningTimer(config.DB, uid.(int64))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"timer": timer})
+}
+
+// StartTimer starts a timer on the task. A user has one running timer, so
+// a timer running on another task is stopped first and returned as stopped.
+func StartTimer(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	var in struct {
+		Note string `json:"note" binding:"max=255"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&in); err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+			return
+		}
+	}
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	var timer, stopped *models.TimeEntry
+	already := false
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		running, err := runningTimer(tx, uid.(int64))
+		if err != nil {
+			return err
+		}
+		if running != nil && running.TaskID == task.ID {
+			timer, already = running, true
+			return nil
+		}
+		if running != nil {
+			if err := stopTimer(tx, running); err != nil {
+				return err
+			}
+			stopped = running
+		}
+		timer = &models.TimeEntry{
+			TaskID:    task.ID,
+			UserID:    uid.(int64),
+			StartedAt: time.Now(),
+			Note:      strings.TrimSpace(in.Note),
+		}
+		return tx.Create(timer).Error
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail start timer"})
+		return
+	}
+	if already {
+		helpers.APIResponse(c, http.StatusOK, "Timer already running", gin.H{"timer": timer, "stopped": nil})
+		return
+	}
+	helpers.APIResponse(c, http.StatusCreated, "Timer started", gin.H{"timer": timer, "stopped": stopped})
+}
+
+// StopTimer stops the user's timer on the task and returns the entry
+func StopTimer(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	timer, err := runningTimer(config.DB, uid.(int64))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if timer == nil || timer.TaskID != id {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "no timer running on this task"})
+		return
+	}
+	if err := stopTimer(config.DB, timer); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail stop timer"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Timer stopped", timer)
+}
+
+// timeEntryInput is a manually entered time entry: either started_at and
+// ended_at, or minutes, optionally with started_at (it then defaults to
+// minutes before now)
+type timeEntryInput struct {
+	StartedAt *time.Time `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Minutes   *int       `json:"minutes"`
+	Note      string     `json:"note" binding:"max=255"`
+}
+
+// span returns the start and end of the entry
+func (in timeEntryInput) span(now time.Time) (time.Time, time.Time, error) {
+	var start, end time.Time
+	switch {
+	case in.Minutes != nil && in.EndedAt != nil:
+		return start, end, errors.New("give ended_at or minutes, not both")
+	case in.Minutes != nil:
+		if *in.Minutes < 1 || *in.Minutes > MaxTimeEntryMinutes {
+			return start, end, fmt.Errorf("minutes must be between 1 and %d", MaxTimeEntryMinutes)
+		}
+		length := time.Duration(*in.Minutes) * time.Minute
+		start = now.Add(-length)
+		if in.StartedAt != nil {
+			start = *in.StartedAt
+		}
+		end = start.Add(length)
+	case in.EndedAt != nil && in.StartedAt != nil:
+		start, end = *in.StartedAt, *in.EndedAt
+		if !end.After(start) {
+			return start, end, errors.New("ended_at must be after started_at")
+		}
+		if end.Sub(start) > MaxTimeEntryMinutes*time.Minute {
+			return start, end, fmt.Errorf("an entry can be at most %d minutes", MaxTimeEntryMinutes)
+		}
+	default:
+		return start, end, errors.New("give started_at and ended_at, or minutes")
+	}
+	if end.After(now.Add(time.Minute)) {
+		return start, end, errors.New("entries can't end in the future")
+	}
+	return start.UTC(), end.UTC(), nil
+}
+
+// apply validates the input and stores it in e
+func (in timeEntryInput) apply(e *models.TimeEntry) error {
+	start, end, err := in.span(time.Now())
+	if err != nil {
+		return err
+	}
+	e.StartedAt = start
+	e.EndedAt = &end
+	e.Seconds = int64(end.Sub(start) / time.Second)
+	e.Note = strings.TrimSpace(in.Note)
+	return nil
+}
+
+// GetTimeEntries lists everyone's time on the task, newest first, with the
+// total and the task's estimate
+func GetTimeEntries(c *gin.Context) {
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return
+	}
+	var entries []models.TimeEntry
+	if err := config.DB.Where("task_id = ?", task.ID).Order("started_at desc, id desc").Find(&entries).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	var seconds int64
+	now := time.Now()
+	for _, e := range entries {
+		seconds += entrySeconds(e, now)
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"entries":          entries,
+		"tracked_minutes":  seconds / 60,
+		"estimate_minutes": task.EstimateMinutes,
+	})
+}
+
+// CreateTimeEntry records time spent on the task without a timer
+func CreateTimeEntry(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	var in timeEntryInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	task, err := findTask(config.DB, uid.(int64), id, true)
+	if err != nil {
+		taskErrorResponse(c, err)
+		return
+	}
+	entry := models.TimeEntry{TaskID: task.ID, UserID: uid.(int64)}
+	if err := in.apply(&entry); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create time entry"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusCreated, "Time entry created", entry)
+}
+
+// ownTimeEntry loads one of the user's entries on the task named in the
+// URL, writing the error response itself
+func ownTimeEntry(c *gin.Context) (models.TimeEntry, bool) {
+	uid, _ := c.Get("user_id")
+	var entry models.TimeEntry
+	task, ok := loadVisibleTask(c)
+	if !ok {
+		return entry, false
+	}
+	entryID, err := strconv.ParseInt(c.Param("entry_id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid time entry id"})
+		return entry, false
+	}
+	if err := config.DB.Where("id = ? AND task_id = ?", entryID, task.ID).First(&entry).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "time entry not found"})
+		return entry, false
+	}
+	if entry.UserID != uid.(int64) {
+		helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "only your own time entries can be changed"})
+		return entry, false
+	}
+	return entry, true
+}
+
+// UpdateTimeEntry replaces the span and note of one of the user's finished
+// entries
+func UpdateTimeEntry(c *gin.Context) {
+	entry, ok := ownTimeEntry(c)
+	if !ok {
+		return
+	}
+	if entry.EndedAt == nil {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": "stop the timer before editing the entry"})
+		return
+	}
+	var in timeEntryInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if err := in.apply(&entry); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	now := time.Now()
+	entry.UpdatedAt = &now
+	if err := config.DB.Save(&entry).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Time entry updated", entry)
+}
+
+// DeleteTimeEntry deletes one of the user's entries, running or not
+func DeleteTimeEntry(c *gin.Context) {
+	entry, ok := ownTimeEntry(c)
+	if !ok {
+		return
+	}
+	if err := config.DB.Delete(&entry).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Time entry deleted", gin.H{"id": entry.ID})
+}
@@ -0,0 +1,152 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+)
+
+func TestTimeTracking(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks/:id", controllers.GetTask)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+	api.POST("/tasks/:id/timer/start", controllers.StartTimer)
+	api.POST("/tasks/:id/timer/stop", controllers.StopTimer)
+	api.GET("/tasks/:id/time-entries", controllers.GetTimeEntries)
+	api.POST("/tasks/:id/time-entries", controllers.CreateTimeEntry)
+	api.PUT("/tasks/:id/time-entries/:entry_id", controllers.UpdateTimeEntry)
+	api.DELETE("/tasks/:id/time-entries/:entry_id", controllers.DeleteTimeEntry)
+	api.GET("/timer", controllers.GetTimer)
+	api.GET("/time/report", controllers.GetTimeReport)
+
+	owner := seedUser(t, "client")
+	contractor := seedUser(t, "contractor")
+	project := models.Project{UserID: owner.ID, Name: "Website"}
+	config.DB.Create(&project)
+	config.DB.Create(&models.ProjectMember{ProjectID: project.ID, UserID: contractor.ID, Role: models.ProjectRoleEditor})
+
+	newTask := func(title string, tags []string) string {
+		t.Helper()
+		w := doJSON(r, "POST", "/api/tasks", gin.H{"title": title, "project_id": project.ID, "tags": tags, "estimate_minutes": 120}, contractor.ID)
+		expectStatus(t, w, http.StatusCreated)
+		return fmt.Sprintf("/api/tasks/%v", responseData(t, w)["id"])
+	}
+	design := newTask("design", []string{"ui"})
+	backend := newTask("backend", []string{"api", "db"})
+	expectStatus(t, doJSON(r, "POST", "/api/tasks", gin.H{"title": "x", "estimate_minutes": -5}, contractor.ID), http.StatusBadRequest)
+
+	// starting a timer stops the one running on another task
+	expectStatus(t, doJSON(r, "POST", design+"/timer/start", nil, contractor.ID), http.StatusCreated)
+	expectStatus(t, doJSON(r, "POST", design+"/timer/start", nil, contractor.ID), http.StatusOK)
+	w := doJSON(r, "POST", backend+"/timer/start", gin.H{"note": "schema"}, contractor.ID)
+	expectStatus(t, w, http.StatusCreated)
+	if stopped := responseData(t, w)["stopped"].(map[string]interface{}); stopped["ended_at"] == nil {
+		t.Fatalf("stopped=%v", stopped)
+	}
+	w = doJSON(r, "GET", "/api/timer", nil, contractor.ID)
+	if timer := responseData(t, w)["timer"].(map[string]interface{}); timer["note"] != "schema" {
+		t.Fatalf("timer=%v", timer)
+	}
+	expectStatus(t, doJSON(r, "POST", design+"/timer/stop", nil, contractor.ID), http.StatusNotFound)
+	expectStatus(t, doJSON(r, "POST", backend+"/timer/stop", nil, contractor.ID), http.StatusOK)
+
+	// manual entries
+	for _, e := range []struct {
+		task string
+		body gin.H
+	}{
+		{design, gin.H{"started_at": "2026-03-02T09:00:00Z", "ended_at": "2026-03-02T10:30:00Z"}},
+		{backend, gin.H{"started_at": "2026-03-02T13:00:00Z", "minutes": 45, "note": "migrations"}},
+		{backend, gin.H{"started_at": "2026-03-03T08:00:00Z", "minutes": 60}},
+	} {
+		expectStatus(t, doJSON(r, "POST", e.task+"/time-entries", e.body, contractor.ID), http.StatusCreated)
+	}
+	for _, bad := range []gin.H{
+		{"started_at": "2026-03-02T10:00:00Z", "ended_at": "2026-03-02T09:00:00Z"},
+		{"minutes": 0},
+		{"minutes": 30, "ended_at": "2026-03-02T09:00:00Z"},
+		{"started_at": "2099-01-01T00:00:00Z", "minutes": 30},
+		{"note": "nothing"},
+	} {
+		if w := doJSON(r, "POST", design+"/time-entries", bad, contractor.ID); w.Code != http.StatusBadRequest {
+			t.Errorf("%v: status %d want 400", bad, w.Code)
+		}
+	}
+
+	w = doJSON(r, "GET", design, nil, owner.ID)
+	if task := responseData(t, w); task["tracked_minutes"] != float64(90) || task["estimate_minutes"] != float64(120) {
+		t.Fatalf("task=%v", task)
+	}
+	w = doJSON(r, "PATCH", design, gin.H{"estimate_minutes": nil}, contractor.ID)
+	if task := responseData(t, w); task["estimate_minutes"] != nil || task["tracked_minutes"] != float64(90) {
+		t.Fatalf("patched task=%v", task)
+	}
+	w = doJSON(r, "GET", backend+"/time-entries", nil, owner.ID)
+	data := responseData(t, w)
+	entries := data["entries"].([]interface{})
+	if len(entries) != 3 || data["tracked_minutes"] != float64(105) {
+		t.Fatalf("entries=%v", data)
+	}
+
+	// only the author may change an entry
+	var morning string
+	for _, e := range entries {
+		if e := e.(map[string]interface{}); e["started_at"] == "2026-03-03T08:00:00Z" {
+			morning = fmt.Sprintf("%s/time-entries/%v", backend, e["id"])
+		}
+	}
+	expectStatus(t, doJSON(r, "DELETE", morning, nil, owner.ID), http.StatusForbidden)
+	expectStatus(t, doJSON(r, "PUT", morning, gin.H{"started_at": "2026-03-03T08:00:00Z", "minutes": 30}, contractor.ID), http.StatusOK)
+
+	report := fmt.Sprintf("/api/time/report?from=2026-03-01&to=2026-03-31&project_id=%d", project.ID)
+	w = doJSON(r, "GET", report+"&group_by=day", nil, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+	data = responseData(t, w)
+	var days []string
+	for _, row := range data["rows"].([]interface{}) {
+		row := row.(map[string]interface{})
+		days = append(days, fmt.Sprint(row["day"], "=", row["minutes"]))
+	}
+	if fmt.Sprint(days) != "[2026-03-02=135 2026-03-03=30]" || data["total_minutes"] != float64(165) {
+		t.Fatalf("report=%v", data)
+	}
+
+	// tasks with several tags count for each
+	w = doJSON(r, "GET", report+"&group_by=tag&format=csv", nil, owner.ID)
+	expectStatus(t, w, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("Content-Type=%q", ct)
+	}
+	if got := w.Body.String(); got != "tag,minutes,hours\napi,75,1.25\ndb,75,1.25\nui,90,1.50\n" {
+		t.Fatalf("csv=%q", got)
+	}
+	// names from other members can't smuggle in spreadsheet formulas
+	config.DB.Model(&project).Update("name", "=HYPERLINK(1)")
+	w = doJSON(r, "GET", report+"&group_by=project&format=csv", nil, owner.ID)
+	if got := w.Body.String(); !strings.Contains(got, ",'=HYPERLINK(1),") {
+		t.Fatalf("csv=%q", got)
+	}
+	config.DB.Model(&project).Update("name", "Website")
+
+	w = doJSON(r, "GET", report+"&group_by=project,user", nil, owner.ID)
+	rows := responseData(t, w)["rows"].([]interface{})
+	if row := rows[0].(map[string]interface{}); len(rows) != 1 || row["project_name"] != "Website" || row["username"] != contractor.Username {
+		t.Fatalf("rows=%v", rows)
+	}
+	expectStatus(t, doJSON(r, "GET", "/api/time/report?from=2026-03-31&to=2026-03-01", nil, owner.ID), http.StatusBadRequest)
+	expectStatus(t, doJSON(r, "GET", report+"&group_by=week", nil, owner.ID), http.StatusBadRequest)
+
+	// people without access to the project see none of its time
+	outsider := seedUser(t, "outsider")
+	w = doJSON(r, "GET", report, nil, outsider.ID)
+	if n := len(responseData(t, w)["rows"].([]interface{})); n != 0 {
+		t.Fatalf("outsider rows=%d", n)
+	}
+}
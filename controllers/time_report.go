@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+)
+
+const MaxReportDays = 366
+
+// reportDimensions are what time reports can be grouped by
+var reportDimensions = []string{"day", "project", "tag", "user"}
+
+// reportRow is the time tracked for one combination of the grouped
+// dimensions; the others are left empty
+type reportRow struct {
+	Day         string  `json:"day,omitempty"`
+	ProjectID   *int64  `json:"project_id,omitempty"`
+	ProjectName string  `json:"project_name,omitempty"`
+	Tag         *string `json:"tag,omitempty"` // "" for untagged tasks
+	UserID      *int64  `json:"user_id,omitempty"`
+	Username    string  `json:"username,omitempty"`
+	Minutes     int64   `json:"minutes"`
+	Hours       float64 `json:"hours"`
+
+	seconds int64
+}
+
+// parseReportRange reads from and to, both inclusive YYYY-MM-DD UTC days,
+// and returns the start of from and the end of to
+func parseReportRange(from, to string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return start, start, errors.New("from must be a YYYY-MM-DD date")
+	}
+	last, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return start, start, errors.New("to must be a YYYY-MM-DD date")
+	}
+	end := last.AddDate(0, 0, 1)
+	if !end.After(start) {
+		return start, end, errors.New("to must not be before from")
+	}
+	if end.Sub(start) > MaxReportDays*24*time.Hour {
+		return start, end, fmt.Errorf("a report covers at most %d days", MaxReportDays)
+	}
+	return start, end, nil
+}
+
+// parseReportGroups parses group_by, a comma separated list of dimensions
+func parseReportGroups(param string) ([]string, error) {
+	if param == "" {
+		return []string{"day"}, nil
+	}
+	var groups []string
+	seen := map[string]bool{}
+	for _, g := range strings.Split(param, ",") {
+		g = strings.TrimSpace(g)
+		if indexOf(reportDimensions, g) < 0 {
+			return nil, fmt.Errorf("cannot group by %q; use %s", g, strings.Join(reportDimensions, "|"))
+		}
+		if seen[g] {
+			return nil, fmt.Errorf("%q given more than once", g)
+		}
+		seen[g] = true
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// GetTimeReport adds up the time tracked on the tasks the user can see
+// between two days, grouped by day, project, tag and/or user. An entry
+// counts on the UTC day it started; one on a task with several tags counts
+// for each tag, so only the total is free of double counting. format=csv
+// returns the rows as a CSV download.
+func GetTimeReport(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	from, to, err := parseReportRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	groups, err := parseReportGroups(c.Query("group_by"))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "format must be json|csv"})
+		return
+	}
+
+	tasks := config.DB.Model(&models.Task{}).Scopes(visibleTasks(uid.(int64))).Select("tasks.id")
+	if v := c.Query("project_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid project_id"})
+			return
+		}
+		tasks = tasks.Where("tasks.project_id = ?", id)
+	}
+	q := config.DB.Where("task_id IN (?) AND started_at >= ? AND started_at < ?", tasks, from, to)
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if v == "me" {
+			id, err = uid.(int64), nil
+		}
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "user_id must be an id or me"})
+			return
+		}
+		q = q.Where("user_id = ?", id)
+	}
+	var entries []models.TimeEntry
+	if err := q.Order("started_at").Find(&entries).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	rows, total, err := timeReportRows(entries, groups)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+
+	if format == "csv" {
+		writeTimeReportCSV(c, rows, groups, fmt.Sprintf("time-report-%s-%s.csv", c.Query("from"), c.Query("to")))
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"from":          c.Query("from"),
+		"to":            c.Query("to"),
+		"group_by":      groups,
+		"rows":          rows,
+		"total_minutes": total / 60,
+		"total_hours":   hours(total),
+	})
+}
+
+// timeReportRows groups the entries and returns the rows in order of the
+// grouped dimensions, with the total seconds
+func timeReportRows(entries []models.TimeEntry, groups []string) ([]*reportRow, int64, error) {
+	taskIDs := map[int64]bool{}
+	userIDs := map[int64]bool{}
+	for _, e := range entries {
+		taskIDs[e.TaskID] = true
+		userIDs[e.UserID] = true
+	}
+	var tasks []models.Task
+	if err := config.DB.Where("id IN ?", keys(taskIDs)).Find(&tasks).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := loadTags(config.DB, tasks); err != nil {
+		return nil, 0, err
+	}
+	taskByID := map[int64]models.Task{}
+	projectIDs := map[int64]bool{}
+	for _, t := range tasks {
+		taskByID[t.ID] = t
+		if t.ProjectID != nil {
+			projectIDs[*t.ProjectID] = true
+		}
+	}
+	var projects []models.Project
+	if err := config.DB.Where("id IN ?", keys(projectIDs)).Find(&projects).Error; err != nil {
+		return nil, 0, err
+	}
+	projectNames := map[int64]string{}
+	for _, p := range projects {
+		projectNames[p.ID] = p.Name
+	}
+	var users []models.User
+	if err := config.DB.Where("id IN ?", keys(userIDs)).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	usernames := map[int64]string{}
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	now := time.Now()
+	var total int64
+	byKey := map[string]*reportRow{}
+	rows := []*reportRow{}
+	for _, e := range entries {
+		seconds := entrySeconds(e, now)
+		total += seconds
+		task := taskByID[e.TaskID]
+		tags := []string{""}
+		if indexOf(groups, "tag") >= 0 && len(task.Tags) > 0 {
+			tags = task.Tags
+		}
+		for _, tag := range tags {
+			row := &reportRow{}
+			for _, g := range groups {
+				switch g {
+				case "day":
+					row.Day = e.StartedAt.UTC().Format("2006-01-02")
+				case "project":
+					row.ProjectID = task.ProjectID
+					if task.ProjectID != nil {
+						row.ProjectName = projectNames[*task.ProjectID]
+					}
+				case "tag":
+					tag := tag
+					row.Tag = &tag
+				case "user":
+					userID := e.UserID
+					row.UserID, row.Username = &userID, usernames[userID]
+				}
+			}
+			key := row.key()
+			if existing, ok := byKey[key]; ok {
+				row = existing
+			} else {
+				byKey[key] = row
+				rows = append(rows, row)
+			}
+			row.seconds += seconds
+		}
+	}
+	for _, row := range rows {
+		row.Minutes = row.seconds / 60
+		row.Hours = hours(row.seconds)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, g := range groups {
+			a, b := rows[i].sortValue(g), rows[j].sortValue(g)
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+	return rows, total, nil
+}
+
+func (r *reportRow) key() string {
+	return fmt.Sprint(r.Day, "|", deref(r.ProjectID), "|", derefString(r.Tag), "|", deref(r.UserID))
+}
+
+func (r *reportRow) sortValue(group string) string {
+	switch group {
+	case "day":
+		return r.Day
+	case "project":
+		return strings.ToLower(r.ProjectName) + "|" + fmt.Sprintf("%020d", deref(r.ProjectID))
+	case "tag":
+		return derefString(r.Tag)
+	default:
+		return strings.ToLower(r.Username)
+	}
+}
+
+// writeTimeReportCSV writes the rows as a CSV file with a column per
+// grouped dimension
+func writeTimeReportCSV(c *gin.Context, rows []*reportRow, groups []string, filename string) {
+	var header []string
+	for _, g := range groups {
+		switch g {
+		case "project":
+			header = append(header, "project_id", "project")
+		case "user":
+			header = append(header, "user_id", "username")
+		default:
+			header = append(header, g)
+		}
+	}
+	header = append(header, "minutes", "hours")
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	w.Write(header)
+	for _, r := range rows {
+		var record []string
+		for _, g := range groups {
+			switch g {
+			case "day":
+				record = append(record, r.Day)
+			case "project":
+				id := ""
+				if r.ProjectID != nil {
+					id = strconv.FormatInt(*r.ProjectID, 10)
+				}
+				record = append(record, id, csvText(r.ProjectName))
+			case "tag":
+				record = append(record, csvText(derefString(r.Tag)))
+			case "user":
+				record = append(record, strconv.FormatInt(deref(r.UserID), 10), csvText(r.Username))
+			}
+		}
+		record = append(record, strconv.FormatInt(r.Minutes, 10), strconv.FormatFloat(r.Hours, 'f', 2, 64))
+		w.Write(record)
+	}
+	w.Flush()
+}
+
+// csvText keeps spreadsheets from reading a user-supplied cell as a
+// formula by prefixing it with a quote
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// hours converts seconds to hours rounded to two decimals
+func hours(seconds int64) float64 {
+	return math.Round(float64(seconds)/36) / 100
+}
+
+func keys(set map[int64]bool) []int64 {
+	out := make([]int64, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	return out
+}
+
+func deref(p *int64) int64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+func derefString(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
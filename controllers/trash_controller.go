@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/jobs"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// trashedTasks scopes a query to trashed tasks the user may restore or purge
+func trashedTasks(userID int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Scopes(editableTasks(userID)).Where("tasks.deleted_at IS NOT NULL")
+	}
+}
+
+// GetTrash lists trashed tasks the user can edit, most recently deleted
+// first, with the time each one will be purged
+func GetTrash(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	page, pageSize := pageParams(c)
+
+	q := config.DB.Scopes(trashedTasks(uid.(int64)))
+	var total int64
+	if err := q.Model(&models.Task{}).Count(&total).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail count"})
+		return
+	}
+	var tasks []models.Task
+	if err := q.Order("tasks.deleted_at desc").Limit(pageSize).Offset((page - 1) * pageSize).
+		Find(&tasks).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+
+	type trashedTask struct {
+		models.Task
+		PurgeAt time.Time `json:"purge_at"`
+	}
+	items := make([]trashedTask, len(tasks))
+	for i, t := range tasks {
+		items[i] = trashedTask{Task: t, PurgeAt: t.DeletedAt.Time.Add(config.C.TrashRetention)}
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{
+		"tasks": items,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// RestoreTask moves a task out of the trash. Tasks of archived projects
+// can't be restored until the project is unarchived.
+func RestoreTask(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "invalid task id"})
+		return
+	}
+	var task models.Task
+	if err := config.DB.Scopes(trashedTasks(uid.(int64))).Where("tasks.id = ?", id).First(&task).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "task not found in trash"})
+		return
+	}
+	if task.ProjectID != nil {
+		var project models.Project
+		if err := config.DB.First(&project, *task.ProjectID).Error; err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		if project.ArchivedAt != nil {
+			helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "project is archived; unarchive it to restore the task"})
+			return
+		}
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		task.DeletedAt = gorm.DeletedAt{}
+		task.Version++
+		if err := tx.Unscoped().Model(&task).Updates(map[string]interface{}{"deleted_at": nil, "version": nextVersion}).Error; err != nil {
+			return err
+		}
+		return recordTaskEvent(tx, c, &task, models.ActivityRestored)
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail restore"})
+		return
+	}
+	tasks := []models.Task{task}
+	if err := decorateTasks(config.DB, tasks); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	taskResponse(c, http.StatusOK, "Restored", tasks[0])
+}
+
+// EmptyTrash permanently deletes every trashed task the user can edit
+func EmptyTrash(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var ids []int64
+	if err := config.DB.Scopes(trashedTasks(uid.(int64))).Model(&models.Task{}).
+		Pluck("tasks.id", &ids).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if err := jobs.PurgeTasks(c.Request.Context(), ids); err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail purge"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Deleted", gin.H{"deleted": len(ids)})
+}
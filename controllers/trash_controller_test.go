@@ -0,0 +1,100 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+	"go-todo-app/models"
+	"go-todo-app/storage"
+)
+
+func TestTrash(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/tasks", controllers.GetTasks)
+	api.DELETE("/tasks/:id", controllers.DeleteTask)
+	api.POST("/tasks/:id/restore", controllers.RestoreTask)
+	api.POST("/tasks/:id/comments", controllers.CreateComment)
+	api.GET("/trash", controllers.GetTrash)
+	api.DELETE("/trash", controllers.EmptyTrash)
+
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Blobs = store
+
+	u := seedUser(t, "trasher")
+	other := seedUser(t, "snooper")
+	var ids []int64
+	for _, title := range []string{"keep", "oops"} {
+		w := doJSON(r, "POST", "/api/tasks", gin.H{"title": title}, u.ID)
+		expectStatus(t, w, http.StatusCreated)
+		ids = append(ids, int64(responseData(t, w)["id"].(float64)))
+	}
+	doJSON(r, "POST", fmt.Sprintf("/api/tasks/%d/comments", ids[1]), gin.H{"body": "note"}, u.ID)
+
+	w := doJSON(r, "DELETE", fmt.Sprintf("/api/tasks/%d", ids[1]), nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+
+	w = doJSON(r, "GET", "/api/tasks", nil, u.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 1 {
+		t.Fatalf("task list has %d tasks, want 1", n)
+	}
+	w = doJSON(r, "GET", "/api/trash", nil, u.ID)
+	trash := responseData(t, w)["tasks"].([]interface{})
+	if len(trash) != 1 || trash[0].(map[string]interface{})["purge_at"] == nil {
+		t.Fatalf("trash=%v", trash)
+	}
+	w = doJSON(r, "GET", "/api/trash", nil, other.ID)
+	if n := len(responseData(t, w)["tasks"].([]interface{})); n != 0 {
+		t.Fatalf("other user sees %d trashed tasks", n)
+	}
+	w = doJSON(r, "POST", fmt.Sprintf("/api/tasks/%d/restore", ids[1]), nil, other.ID)
+	expectStatus(t, w, http.StatusNotFound)
+
+	// restoring brings the task back with its comments
+	w = doJSON(r, "POST", fmt.Sprintf("/api/tasks/%d/restore", ids[1]), nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	if restored := responseData(t, w); restored["comment_count"] != float64(1) || restored["deleted_at"] != nil {
+		t.Fatalf("restored=%v", restored)
+	}
+	w = doJSON(r, "POST", fmt.Sprintf("/api/tasks/%d/restore", ids[1]), nil, u.ID)
+	expectStatus(t, w, http.StatusNotFound)
+
+	// emptying the trash removes the task and its comments for good
+	doJSON(r, "DELETE", fmt.Sprintf("/api/tasks/%d", ids[1]), nil, u.ID)
+	w = doJSON(r, "DELETE", "/api/trash", nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	if n := responseData(t, w)["deleted"]; n != float64(1) {
+		t.Fatalf("deleted=%v want 1", n)
+	}
+	var count int64
+	config.DB.Unscoped().Model(&models.Task{}).Where("id = ?", ids[1]).Count(&count)
+	if count != 0 {
+		t.Fatal("task still stored after emptying the trash")
+	}
+	config.DB.Model(&models.Comment{}).Where("task_id = ?", ids[1]).Count(&count)
+	if count != 0 {
+		t.Fatal("comments still stored after emptying the trash")
+	}
+	config.DB.Model(&models.TaskActivity{}).Where("task_id = ?", ids[1]).Count(&count)
+	if count != 0 {
+		t.Fatal("activity still stored after emptying the trash")
+	}
+
+	// tasks of archived projects stay in the trash
+	project := models.Project{UserID: u.ID, Name: "Old"}
+	config.DB.Create(&project)
+	w = doJSON(r, "POST", "/api/tasks", gin.H{"title": "archived", "project_id": project.ID}, u.ID)
+	archived := int64(responseData(t, w)["id"].(float64))
+	doJSON(r, "DELETE", fmt.Sprintf("/api/tasks/%d", archived), nil, u.ID)
+	config.DB.Model(&project).Update("archived_at", time.Now())
+	w = doJSON(r, "POST", fmt.Sprintf("/api/tasks/%d/restore", archived), nil, u.ID)
+	expectStatus(t, w, http.StatusBadRequest)
+}
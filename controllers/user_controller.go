@@ -1,13 +1,16 @@
 package controllers
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"go-todo-app/config"
 	"go-todo-app/helpers"
 	"go-todo-app/models"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 	"net/http"
 	"strings"
+	"time"
 )
 
 func Register(c *gin.Context) {
@@ -15,6 +18,7 @@ func Register(c *gin.Context) {
 		Username string `json:"username" binding:"required,min=3,max=30"`
 		Email    string `json:"email"    binding:"required"`
 		Password string `json:"password" binding:"required,min=8"`
+		TimeZone string `json:"time_zone"` // IANA name, default UTC
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -43,6 +47,13 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	if _, err := loadTimeZone(input.TimeZone); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{
+			"details": err.Error(),
+		})
+		return
+	}
+
 	// Check if email already exists
 	var existingUser models.User
 	if err := config.DB.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
@@ -72,17 +83,26 @@ func Register(c *gin.Context) {
 		Username:     input.Username,
 		Email:        strings.ToLower(input.Email),
 		PasswordHash: string(hashedPassword),
+		TimeZone:     input.TimeZone,
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		_, err := ensureInbox(tx, user.ID)
+		return err
+	})
+	if err != nil {
 		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server Error", gin.H{"details": "Failed to create user"})
 		return
 	}
 
 	helpers.APIResponse(c, http.StatusCreated, "User created successfully", gin.H{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"email":    user.Email,
+		"user_id":   user.ID,
+		"username":  user.Username,
+		"email":     user.Email,
+		"time_zone": user.TimeZone,
 	})
 }
 
@@ -136,9 +156,56 @@ func Login(c *gin.Context) {
 		"expires_in": int(config.C.JWTExpiry.Seconds()),
 		"token":      token,
 		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
+			"id":        user.ID,
+			"username":  user.Username,
+			"email":     user.Email,
+			"time_zone": user.TimeZone,
 		},
 	})
 }
+
+// loadTimeZone loads an IANA time zone; empty is UTC
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q; use an IANA name such as Europe/Berlin", name)
+	}
+	return loc, nil
+}
+
+// userLocation returns the user's time zone, UTC if unset
+func userLocation(tx *gorm.DB, userID int64) (*time.Location, error) {
+	var u models.User
+	if err := tx.Select("time_zone").First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	loc, err := loadTimeZone(u.TimeZone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// SetTimeZone sets the zone quick add and recurring tasks resolve dates in
+func SetTimeZone(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var in struct {
+		TimeZone string `json:"time_zone"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if _, err := loadTimeZone(in.TimeZone); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	if err := config.DB.Model(&models.User{}).Where("id = ?", uid.(int64)).Update("time_zone", in.TimeZone).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Time zone updated", gin.H{"time_zone": in.TimeZone})
+}
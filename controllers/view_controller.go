@@ -0,0 +1,399 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// builtinViews are offered to every user. Their keys stand in for an id in
+// the /views/:id routes and can't be edited or deleted.
+var builtinViews = []models.View{
+	{Key: "today", Name: "Today", Query: "due<=today category!=done", Sort: "due_at,-priority"},
+	{Key: "upcoming", Name: "Upcoming", Query: "due>=tomorrow due<today+8 category!=done", Sort: "due_at,-priority"},
+	{Key: "overdue", Name: "Overdue", Query: "due<today category!=done", Sort: "due_at,-priority"},
+	{Key: "high-priority", Name: "High Priority", Query: "priority:high category!=done", Sort: "due_at"},
+}
+
+// viewGroups maps the group_by values to the sort key that keeps a group's
+// tasks together
+var viewGroups = map[string]string{
+	"status":   "status",
+	"priority": "-priority",
+	"project":  "project_id",
+	"assignee": "assignee_id",
+}
+
+var errViewNotFound = errors.New("view not found")
+
+// viewRef is how a view is named in the routes and in User.DefaultView
+func viewRef(v models.View) string {
+	if v.Key != "" {
+		return v.Key
+	}
+	return strconv.FormatInt(v.ID, 10)
+}
+
+// findView resolves a built-in key or the id of one of the user's views
+func findView(userID int64, ref string) (models.View, error) {
+	for _, v := range builtinViews {
+		if v.Key == ref {
+			return v, nil
+		}
+	}
+	id, err := strconv.ParseInt(ref, 10, 64)
+	if err != nil {
+		return models.View{}, errViewNotFound
+	}
+	var v models.View
+	err = config.DB.Where("id = ? AND user_id = ?", id, userID).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return v, errViewNotFound
+	}
+	return v, err
+}
+
+func viewErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, errViewNotFound) {
+		helpers.ErrorResponse(c, http.StatusNotFound, "Not found", gin.H{"details": "view not found"})
+		return
+	}
+	helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+}
+
+func defaultViewRef(userID int64) (string, error) {
+	var u models.User
+	err := config.DB.Select("default_view").First(&u, userID).Error
+	return u.DefaultView, err
+}
+
+// viewSort puts the grouping key in front of sort so groups come out whole
+func viewSort(sort, groupBy string) string {
+	if sort == "manual" {
+		sort = "position"
+	}
+	group, ok := viewGroups[groupBy]
+	if !ok {
+		return sort
+	}
+	if sort == "" {
+		sort = "-id"
+	}
+	keys := []string{group}
+	for _, key := range strings.Split(sort, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(key), "-") != strings.TrimPrefix(group, "-") {
+			keys = append(keys, key)
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+// validateView normalizes v and checks its query, sort and grouping
+func validateView(c *gin.Context, v *models.View) bool {
+	uid, _ := c.Get("user_id")
+	v.Name = strings.TrimSpace(v.Name)
+	v.Query = strings.TrimSpace(v.Query)
+	v.Sort = strings.ReplaceAll(v.Sort, " ", "")
+	v.GroupBy = strings.ToLower(strings.TrimSpace(v.GroupBy))
+	if v.Name == "" || len(v.Name) > 100 {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "name must be 1-100 characters"})
+		return false
+	}
+	if _, err := (taskFilter{Query: v.Query}).apply(config.DB, uid.(int64)); err != nil {
+		filterErrorResponse(c, err)
+		return false
+	}
+	if _, ok := viewGroups[v.GroupBy]; v.GroupBy != "" && !ok {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": "group_by must be status|priority|project|assignee"})
+		return false
+	}
+	if _, err := parseTaskSort(viewSort(v.Sort, v.GroupBy), v.Nulls); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return false
+	}
+	return true
+}
+
+// viewInput is the body of create and update requests
+type viewInput struct {
+	Name    *string `json:"name"`
+	Query   *string `json:"query"`
+	Sort    *string `json:"sort"`
+	Nulls   *string `json:"nulls"`    // first|last
+	GroupBy *string `json:"group_by"` // status|priority|project|assignee
+}
+
+func (in viewInput) applyTo(v *models.View) {
+	if in.Name != nil {
+		v.Name = *in.Name
+	}
+	if in.Query != nil {
+		v.Query = *in.Query
+	}
+	if in.Sort != nil {
+		v.Sort = *in.Sort
+	}
+	if in.Nulls != nil {
+		v.Nulls = *in.Nulls
+	}
+	if in.GroupBy != nil {
+		v.GroupBy = *in.GroupBy
+	}
+}
+
+// nameTaken reports whether the user has another view called name
+func nameTaken(v models.View) (bool, error) {
+	var n int64
+	err := config.DB.Model(&models.View{}).
+		Where("user_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", v.UserID, v.Name, v.ID).Count(&n).Error
+	return n > 0, err
+}
+
+func GetViews(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var saved []models.View
+	if err := config.DB.Where("user_id = ?", uid.(int64)).Order("name asc, id asc").Find(&saved).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	def, err := defaultViewRef(uid.(int64))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	views := append(append([]models.View{}, builtinViews...), saved...)
+	for i := range views {
+		views[i].IsDefault = viewRef(views[i]) == def
+	}
+	helpers.APIResponse(c, http.StatusOK, "OK", gin.H{"views": views})
+}
+
+func GetView(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	v, err := findView(uid.(int64), c.Param("id"))
+	if err != nil {
+		viewErrorResponse(c, err)
+		return
+	}
+	def, err := defaultViewRef(uid.(int64))
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	v.IsDefault = viewRef(v) == def
+	helpers.APIResponse(c, http.StatusOK, "OK", v)
+}
+
+func CreateView(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	var in viewInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	v := models.View{UserID: uid.(int64)}
+	in.applyTo(&v)
+	if !validateView(c, &v) {
+		return
+	}
+	taken, err := nameTaken(v)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if taken {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": "a view with this name already exists"})
+		return
+	}
+	if err := config.DB.Create(&v).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail create view"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusCreated, "View created", v)
+}
+
+func UpdateView(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	v, err := findView(uid.(int64), c.Param("id"))
+	if err != nil {
+		viewErrorResponse(c, err)
+		return
+	}
+	if v.Key != "" {
+		helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "built-in views can't be changed"})
+		return
+	}
+	var in viewInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	in.applyTo(&v)
+	if !validateView(c, &v) {
+		return
+	}
+	taken, err := nameTaken(v)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+		return
+	}
+	if taken {
+		helpers.ErrorResponse(c, http.StatusConflict, "Conflict", gin.H{"details": "a view with this name already exists"})
+		return
+	}
+	if err := config.DB.Save(&v).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update view"})
+		return
+	}
+	def, _ := defaultViewRef(uid.(int64))
+	v.IsDefault = viewRef(v) == def
+	helpers.APIResponse(c, http.StatusOK, "View updated", v)
+}
+
+func DeleteView(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	v, err := findView(uid.(int64), c.Param("id"))
+	if err != nil {
+		viewErrorResponse(c, err)
+		return
+	}
+	if v.Key != "" {
+		helpers.ErrorResponse(c, http.StatusForbidden, "Forbidden", gin.H{"details": "built-in views can't be deleted"})
+		return
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&v).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ? AND default_view = ?", v.UserID, viewRef(v)).
+			Update("default_view", "").Error
+	})
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail delete view"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "View deleted", nil)
+}
+
+// SetDefaultView makes the view the one clients open first
+func SetDefaultView(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	v, err := findView(uid.(int64), c.Param("id"))
+	if err != nil {
+		viewErrorResponse(c, err)
+		return
+	}
+	if err := config.DB.Model(&models.User{}).Where("id = ?", uid.(int64)).Update("default_view", viewRef(v)).Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update view"})
+		return
+	}
+	v.IsDefault = true
+	helpers.APIResponse(c, http.StatusOK, "Default view set", v)
+}
+
+// UnsetDefaultView clears the default if it is this view
+func UnsetDefaultView(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	v, err := findView(uid.(int64), c.Param("id"))
+	if err != nil {
+		viewErrorResponse(c, err)
+		return
+	}
+	if err := config.DB.Model(&models.User{}).Where("id = ? AND default_view = ?", uid.(int64), viewRef(v)).
+		Update("default_view", "").Error; err != nil {
+		helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail update view"})
+		return
+	}
+	helpers.APIResponse(c, http.StatusOK, "Default view cleared", v)
+}
+
+// GetViewTasks lists the tasks in a view. The usual list parameters work
+// too: filters narrow the view, and sort and nulls replace its own.
+// "default" stands for the user's default view.
+func GetViewTasks(c *gin.Context) {
+	uid, _ := c.Get("user_id")
+	ref := c.Param("id")
+	if ref == "default" {
+		def, err := defaultViewRef(uid.(int64))
+		if err != nil {
+			helpers.ErrorResponse(c, http.StatusInternalServerError, "Server error", gin.H{"details": "fail query"})
+			return
+		}
+		ref = def
+	}
+	v, err := findView(uid.(int64), ref)
+	if err != nil {
+		viewErrorResponse(c, err)
+		return
+	}
+
+	q := config.DB.Scopes(visibleTasks(uid.(int64)))
+	q, err = taskFilter{Query: v.Query}.apply(q, uid.(int64))
+	if err != nil {
+		// the expression was valid when saved; a project or user it
+		// named may have gone since
+		filterErrorResponse(c, err)
+		return
+	}
+	f, err := queryTaskFilter(c)
+	if err == nil {
+		q, err = f.apply(q, uid.(int64))
+	}
+	if err != nil {
+		filterErrorResponse(c, err)
+		return
+	}
+	sort, nulls := v.Sort, v.Nulls
+	if s := c.Query("sort"); s != "" {
+		sort = s
+	}
+	if n := c.Query("nulls"); n != "" {
+		nulls = n
+	}
+	order, err := parseTaskSort(viewSort(sort, v.GroupBy), nulls)
+	if err != nil {
+		helpers.ErrorResponse(c, http.StatusBadRequest, "Validation error", gin.H{"details": err.Error()})
+		return
+	}
+	writeTaskList(c, q, order, v.GroupBy)
+}
+
+// taskGroup is one run of tasks sharing a group_by value
+type taskGroup struct {
+	Key   interface{}   `json:"key"`
+	Tasks []models.Task `json:"tasks"`
+}
+
+// groupTasks splits tasks, already sorted by the group key, into groups
+func groupTasks(tasks []models.Task, groupBy string) []taskGroup {
+	groups := []taskGroup{}
+	for _, t := range tasks {
+		var key interface{}
+		switch groupBy {
+		case "status":
+			key = t.Status
+		case "priority":
+			key = t.Priority
+		case "project":
+			if t.ProjectID != nil {
+				key = *t.ProjectID
+			}
+		case "assignee":
+			if t.AssigneeID != nil {
+				key = *t.AssigneeID
+			}
+		}
+		if n := len(groups); n == 0 || groups[n-1].Key != key {
+			groups = append(groups, taskGroup{Key: key})
+		}
+		groups[len(groups)-1].Tasks = append(groups[len(groups)-1].Tasks, t)
+	}
+	return groups
+}
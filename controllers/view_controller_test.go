@@ -0,0 +1,150 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-todo-app/config"
+	"go-todo-app/controllers"
+)
+
+func TestViews(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/views", controllers.GetViews)
+	api.POST("/views", controllers.CreateView)
+	api.PUT("/views/:id", controllers.UpdateView)
+	api.DELETE("/views/:id", controllers.DeleteView)
+	api.POST("/views/:id/default", controllers.SetDefaultView)
+	api.GET("/views/:id/tasks", controllers.GetViewTasks)
+
+	u := seedUser(t, "viewer")
+	today := time.Now().UTC().Truncate(24 * time.Hour).Add(12 * time.Hour)
+	for _, in := range []gin.H{
+		{"title": "late", "priority": "low", "due_at": today.AddDate(0, 0, -2)},
+		{"title": "now", "priority": "high", "due_at": today},
+		{"title": "soon", "priority": "medium", "due_at": today.AddDate(0, 0, 3)},
+		{"title": "later", "priority": "high", "due_at": today.AddDate(0, 0, 30)},
+		{"title": "whenever", "priority": "high"},
+	} {
+		expectStatus(t, doJSON(r, "POST", "/api/tasks", in, u.ID), http.StatusCreated)
+	}
+	titles := func(path string) string {
+		t.Helper()
+		w := doJSON(r, "GET", path, nil, u.ID)
+		expectStatus(t, w, http.StatusOK)
+		var out []string
+		for _, task := range responseData(t, w)["tasks"].([]interface{}) {
+			out = append(out, task.(map[string]interface{})["title"].(string))
+		}
+		return strings.Join(out, ",")
+	}
+
+	for path, want := range map[string]string{
+		"/api/views/today/tasks":                     "late,now",
+		"/api/views/upcoming/tasks":                  "soon",
+		"/api/views/overdue/tasks":                   "late",
+		"/api/views/high-priority/tasks":             "now,later,whenever",
+		"/api/views/high-priority/tasks?sort=-title": "whenever,now,later",
+		"/api/views/today/tasks?priority=high":       "now",
+	} {
+		if got := titles(path); got != want {
+			t.Errorf("%s: got %s want %s", path, got, want)
+		}
+	}
+
+	w := doJSON(r, "POST", "/api/views", gin.H{"name": "Dated", "query": "due!=none", "sort": "title", "group_by": "priority"}, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	id := responseData(t, w)["id"]
+	w = doJSON(r, "GET", fmt.Sprintf("/api/views/%v/tasks", id), nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	var groups []string
+	for _, g := range responseData(t, w)["groups"].([]interface{}) {
+		var names []string
+		for _, task := range g.(map[string]interface{})["tasks"].([]interface{}) {
+			names = append(names, task.(map[string]interface{})["title"].(string))
+		}
+		groups = append(groups, fmt.Sprintf("%v:%s", g.(map[string]interface{})["key"], strings.Join(names, ",")))
+	}
+	if got := strings.Join(groups, " "); got != "high:later,now medium:soon low:late" {
+		t.Fatalf("groups %s", got)
+	}
+
+	// the default view is marked and reachable as "default"
+	expectStatus(t, doJSON(r, "GET", "/api/views/default/tasks", nil, u.ID), http.StatusNotFound)
+	expectStatus(t, doJSON(r, "POST", fmt.Sprintf("/api/views/%v/default", id), nil, u.ID), http.StatusOK)
+	w = doJSON(r, "GET", "/api/views/default/tasks", nil, u.ID)
+	expectStatus(t, w, http.StatusOK)
+	if responseData(t, w)["group_by"] != "priority" {
+		t.Fatalf("default view not used: %s", w.Body.String())
+	}
+	w = doJSON(r, "GET", "/api/views", nil, u.ID)
+	var defaults []string
+	for _, v := range responseData(t, w)["views"].([]interface{}) {
+		if v.(map[string]interface{})["is_default"] == true {
+			defaults = append(defaults, v.(map[string]interface{})["name"].(string))
+		}
+	}
+	if fmt.Sprint(defaults) != "[Dated]" {
+		t.Fatalf("default views %v", defaults)
+	}
+	expectStatus(t, doJSON(r, "DELETE", fmt.Sprintf("/api/views/%v", id), nil, u.ID), http.StatusOK)
+	expectStatus(t, doJSON(r, "GET", "/api/views/default/tasks", nil, u.ID), http.StatusNotFound)
+
+	// validation, and other users' and built-in views are off limits
+	for _, in := range []gin.H{
+		{"name": "", "query": "status:pending"},
+		{"name": "Bad", "query": "due<soon"},
+		{"name": "Bad", "sort": "password_hash"},
+		{"name": "Bad", "group_by": "color"},
+	} {
+		expectStatus(t, doJSON(r, "POST", "/api/views", in, u.ID), http.StatusBadRequest)
+	}
+	w = doJSON(r, "POST", "/api/views", gin.H{"name": "Mine", "query": "tag:x"}, u.ID)
+	expectStatus(t, w, http.StatusCreated)
+	mine := fmt.Sprintf("/api/views/%v", responseData(t, w)["id"])
+	expectStatus(t, doJSON(r, "POST", "/api/views", gin.H{"name": "mine"}, u.ID), http.StatusConflict)
+	other := seedUser(t, "peeker")
+	expectStatus(t, doJSON(r, "GET", mine+"/tasks", nil, other.ID), http.StatusNotFound)
+	expectStatus(t, doJSON(r, "PUT", "/api/views/today", gin.H{"name": "Mine"}, u.ID), http.StatusForbidden)
+	expectStatus(t, doJSON(r, "DELETE", "/api/views/today", nil, u.ID), http.StatusForbidden)
+}
+
+func TestViewsInTimeZone(t *testing.T) {
+	r, api := newTestAPI()
+	api.POST("/tasks", controllers.CreateTask)
+	api.GET("/views/:id/tasks", controllers.GetViewTasks)
+
+	// today starts 14 hours before it does in UTC
+	u := seedUser(t, "islander")
+	config.DB.Model(&u).Update("time_zone", "Pacific/Kiritimati")
+	loc, _ := time.LoadLocation("Pacific/Kiritimati")
+	y, m, d := time.Now().In(loc).Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	for _, in := range []gin.H{
+		{"title": "yesterday", "due_at": midnight.Add(-time.Minute).UTC()},
+		{"title": "today", "due_at": midnight.UTC()},
+		{"title": "tomorrow", "due_at": midnight.AddDate(0, 0, 1).UTC()},
+	} {
+		expectStatus(t, doJSON(r, "POST", "/api/tasks", in, u.ID), http.StatusCreated)
+	}
+	for path, want := range map[string]string{
+		"/api/views/today/tasks":    "yesterday,today",
+		"/api/views/upcoming/tasks": "tomorrow",
+		"/api/views/overdue/tasks":  "yesterday",
+	} {
+		w := doJSON(r, "GET", path, nil, u.ID)
+		expectStatus(t, w, http.StatusOK)
+		var got []string
+		for _, task := range responseData(t, w)["tasks"].([]interface{}) {
+			got = append(got, task.(map[string]interface{})["title"].(string))
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("%s: got %v want %s", path, got, want)
+		}
+	}
+}
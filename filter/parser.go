@@ -0,0 +1,194 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MaxLength     = 1000
+	MaxConditions = 50
+	MaxDepth      = 20
+)
+
+// Node is a node of the syntax tree: *And, *Or, *Not or *Cond
+type Node interface {
+	node()
+}
+
+type And struct{ Left, Right Node }
+type Or struct{ Left, Right Node }
+type Not struct{ X Node }
+
+// Cond compares a field with a value, e.g. due<2026-11-01
+type Cond struct {
+	Field string // lowercased
+	Op    string // one of : = != < <= > >=
+	Value string
+	Pos   int
+}
+
+func (*And) node()  {}
+func (*Or) node()   {}
+func (*Not) node()  {}
+func (*Cond) node() {}
+
+type parser struct {
+	tokens []token
+	i      int
+	conds  int
+	depth  int
+}
+
+// Parse parses an expression. Errors are *Error with the position of the
+// offending token.
+func Parse(input string) (Node, error) {
+	if len(input) > MaxLength {
+		return nil, errorf(MaxLength, "expression longer than %d characters", MaxLength)
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, errorf(1, "empty expression")
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		if t.kind == tokRParen {
+			return nil, errorf(t.pos, "unmatched )")
+		}
+		return nil, errorf(t.pos, "unexpected %s", t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.i] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.next()
+		case tokWord, tokNot, tokLParen:
+			// implicit AND
+		default:
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{left, right}
+	}
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if p.peek().kind != tokNot {
+		return p.parsePrimary()
+	}
+	not := p.next()
+	if err := p.enter(not.pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return &Not{x}, nil
+}
+
+func (p *parser) enter(pos int) error {
+	p.depth++
+	if p.depth > MaxDepth {
+		return errorf(pos, "expression nested deeper than %d levels", MaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() { p.depth-- }
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		if err := p.enter(t.pos); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokRParen {
+			return nil, errorf(c.pos, "expected ) to close ( at position %d, got %s", t.pos, c)
+		}
+		return n, nil
+	case tokWord:
+		op := p.next()
+		if op.kind != tokOp {
+			return nil, errorf(op.pos, "expected an operator (: = != < <= > >=) after %q, got %s", t.text, op)
+		}
+		v := p.next()
+		if v.kind != tokWord && v.kind != tokString {
+			return nil, errorf(v.pos, "expected a value after %s%s, got %s", t.text, op.text, v)
+		}
+		p.conds++
+		if p.conds > MaxConditions {
+			return nil, errorf(t.pos, "more than %d conditions", MaxConditions)
+		}
+		return &Cond{Field: strings.ToLower(t.text), Op: op.text, Value: v.text, Pos: t.pos}, nil
+	case tokEOF:
+		return nil, errorf(t.pos, "unexpected end of input, expected a condition")
+	default:
+		return nil, errorf(t.pos, "unexpected %s, expected a condition", t)
+	}
+}
+
+// String renders a tree fully parenthesized, e.g. (a:1 AND (NOT b:2))
+func String(n Node) string {
+	switch n := n.(type) {
+	case *And:
+		return "(" + String(n.Left) + " AND " + String(n.Right) + ")"
+	case *Or:
+		return "(" + String(n.Left) + " OR " + String(n.Right) + ")"
+	case *Not:
+		return "(NOT " + String(n.X) + ")"
+	case *Cond:
+		return fmt.Sprintf("%s%s%q", n.Field, n.Op, n.Value)
+	}
+	return "?"
+}
@@ -0,0 +1,81 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{`status:pending`, `status:"pending"`},
+		{`Priority:HIGH`, `priority:"HIGH"`},
+		{`due<2026-11-01`, `due<"2026-11-01"`},
+		{`due <= 2026-11-01`, `due<="2026-11-01"`},
+		{`tag!=someday`, `tag!="someday"`},
+		{`title:"weekly sync"`, `title:"weekly sync"`},
+		{`title:"say \"hi\""`, `title:"say \"hi\""`},
+		{`a:1 b:2`, `(a:"1" AND b:"2")`},
+		{`a:1 and b:2 AND c:3`, `((a:"1" AND b:"2") AND c:"3")`},
+		{`a:1 OR b:2 AND c:3`, `(a:"1" OR (b:"2" AND c:"3"))`},
+		{`a:1 AND b:2 OR c:3`, `((a:"1" AND b:"2") OR c:"3")`},
+		{`NOT a:1 AND b:2`, `((NOT a:"1") AND b:"2")`},
+		{`not not a:1`, `(NOT (NOT a:"1"))`},
+		{`NOT (a:1 OR b:2)`, `(NOT (a:"1" OR b:"2"))`},
+		{`((a:1))`, `a:"1"`},
+		{`priority:high AND (status:pending OR due<2026-11-01) AND NOT tag:someday`,
+			`((priority:"high" AND (status:"pending" OR due<"2026-11-01")) AND (NOT tag:"someday"))`},
+		{`tag:"not"`, `tag:"not"`},
+		{`assignee:me`, `assignee:"me"`},
+		{`title:ünï`, `title:"ünï"`},
+	}
+	for _, tc := range cases {
+		n, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.in, err)
+			continue
+		}
+		if got := String(n); got != tc.want {
+			t.Errorf("Parse(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		in  string
+		pos int
+		msg string
+	}{
+		{``, 1, "empty expression"},
+		{`   `, 1, "empty expression"},
+		{`status`, 7, "expected an operator"},
+		{`status:`, 8, "expected a value"},
+		{`status:(`, 8, "expected a value"},
+		{`:pending`, 1, "unexpected \":\""},
+		{`a:1 AND`, 8, "unexpected end of input"},
+		{`a:1 OR OR b:2`, 8, "unexpected \"OR\""},
+		{`(a:1`, 5, "expected ) to close ( at position 1"},
+		{`a:1)`, 4, "unmatched )"},
+		{`a:"open`, 3, "unterminated string"},
+		{`a:1 & b:2`, 5, "unexpected '&'"},
+		{`a!1`, 2, "unexpected '!'"},
+		{`a:1 NOT`, 8, "unexpected end of input"},
+		{`tag:not`, 5, "expected a value"},
+		{`a:1 b:2 )`, 9, "unmatched )"},
+		{strings.Repeat("(", 30) + "a:1" + strings.Repeat(")", 30), 21, "nested deeper"},
+		{strings.Repeat("a:1 ", 51), 201, "more than 50 conditions"},
+		{strings.Repeat("x", MaxLength+1), MaxLength, "longer than"},
+	}
+	for _, tc := range cases {
+		_, err := Parse(tc.in)
+		var fe *Error
+		if !errors.As(err, &fe) {
+			t.Errorf("Parse(%q): got %v, want a filter error", tc.in, err)
+			continue
+		}
+		if fe.Pos != tc.pos || !strings.Contains(fe.Msg, tc.msg) {
+			t.Errorf("Parse(%q): got %d %q, want %d %q", tc.in, fe.Pos, fe.Msg, tc.pos, tc.msg)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"go-todo-app/config"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func cursorSignature(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(config.C.JWTSecret))
+	mac.Write([]byte("cursor:" + payload))
+	return mac.Sum(nil)
+}
+
+// EncodeCursor serializes v into an opaque, signed pagination cursor
+func EncodeCursor(v interface{}) string {
+	b, _ := json.Marshal(v)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(cursorSignature(payload))
+}
+
+// DecodeCursor verifies a cursor made by EncodeCursor and decodes it into v
+func DecodeCursor(cursor string, v interface{}) error {
+	payload, sig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return ErrInvalidCursor
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, cursorSignature(payload)) {
+		return ErrInvalidCursor
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package helpers
+
+import "regexp"
+
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.\-]{3,30})`)
+
+// ParseMentions returns the unique usernames mentioned as @username, in
+// order of first appearance
+func ParseMentions(text string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range mentionRegex.FindAllStringSubmatch(text, -1) {
+		name := m[1]
+		// a trailing dot is punctuation, not part of the name
+		for len(name) > 0 && name[len(name)-1] == '.' {
+			name = name[:len(name)-1]
+		}
+		if len(name) < 3 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
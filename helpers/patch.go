@@ -0,0 +1,268 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch means a patch is malformed or can't be applied to
+	// the document, e.g. it names a path that doesn't exist
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPatchTestFailed means a JSON Patch test operation didn't match
+	ErrPatchTestFailed = errors.New("patch test failed")
+)
+
+// MergePatch applies an RFC 7396 JSON merge patch to doc: objects are
+// merged recursively, null removes a member and anything else replaces it.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// PatchOperation is one step of an RFC 6902 JSON Patch
+type PatchOperation struct {
+	Op    string          `json:"op"` // add|remove|replace|move|copy|test
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch to doc. The operations apply in
+// order and all or nothing; errors wrap ErrInvalidPatch or
+// ErrPatchTestFailed.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	var ops []PatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: a JSON Patch is an array of operations", ErrInvalidPatch)
+	}
+	var root interface{}
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		var err error
+		if root, err = applyOperation(root, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(root)
+}
+
+func applyOperation(root interface{}, op PatchOperation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("%w: value is required", ErrInvalidPatch)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return addValue(root, path, value)
+	case "remove":
+		return removeValue(root, path)
+	case "replace":
+		if _, err := getValue(root, path); err != nil {
+			return nil, err
+		}
+		return setValue(root, path, value)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := getValue(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			// the copy mustn't share maps or slices with the original
+			b, _ := json.Marshal(v)
+			json.Unmarshal(b, &v)
+			return addValue(root, path, v)
+		}
+		if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+			return nil, fmt.Errorf("%w: can't move a value into itself", ErrInvalidPatch)
+		}
+		if root, err = removeValue(root, from); err != nil {
+			return nil, err
+		}
+		return addValue(root, path, v)
+	case "test":
+		v, err := getValue(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(v, value) {
+			return nil, ErrPatchTestFailed
+		}
+		return root, nil
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its reference tokens
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidPatch, p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token; "-" (past the end) is only
+// allowed when adding
+func arrayIndex(token string, n int, adding bool) (int, error) {
+	if adding && token == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || token != strconv.Itoa(i) {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrInvalidPatch, token)
+	}
+	if i > n || i == n && !adding {
+		return 0, fmt.Errorf("%w: index %d out of range", ErrInvalidPatch, i)
+	}
+	return i, nil
+}
+
+func getValue(root interface{}, path []string) (interface{}, error) {
+	v := root
+	for _, token := range path {
+		switch c := v.(type) {
+		case map[string]interface{}:
+			next, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q not found", ErrInvalidPatch, token)
+			}
+			v = next
+		case []interface{}:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			v = c[i]
+		default:
+			return nil, fmt.Errorf("%w: %q not found", ErrInvalidPatch, token)
+		}
+	}
+	return v, nil
+}
+
+// setValue stores value at path, whose parent must exist, and returns the
+// new root
+func setValue(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		c[last] = value
+	case []interface{}:
+		i, err := arrayIndex(last, len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = value
+	default:
+		return nil, fmt.Errorf("%w: %q not found", ErrInvalidPatch, last)
+	}
+	return root, nil
+}
+
+func addValue(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	list, ok := parent.([]interface{})
+	if !ok {
+		return setValue(root, path, value)
+	}
+	i, err := arrayIndex(path[len(path)-1], len(list), true)
+	if err != nil {
+		return nil, err
+	}
+	grown := make([]interface{}, 0, len(list)+1)
+	grown = append(append(append(grown, list[:i]...), value), list[i:]...)
+	return setValue(root, path[:len(path)-1], grown)
+}
+
+func removeValue(root interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: can't remove the whole document", ErrInvalidPatch)
+	}
+	parent, err := getValue(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := c[last]; !ok {
+			return nil, fmt.Errorf("%w: %q not found", ErrInvalidPatch, last)
+		}
+		delete(c, last)
+		return root, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		shrunk := append(append([]interface{}{}, c[:i]...), c[i+1:]...)
+		return setValue(root, path[:len(path)-1], shrunk)
+	}
+	return nil, fmt.Errorf("%w: %q not found", ErrInvalidPatch, last)
+}
@@ -0,0 +1,30 @@
+package helpers
+
+import "regexp"
+
+var placeholderRegex = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]{0,49})\s*\}\}`)
+
+// ParsePlaceholders returns the unique variable names used as {{name}} in
+// text, in order of first appearance
+func ParsePlaceholders(text string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range placeholderRegex.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// FillPlaceholders replaces each {{name}} in text with vars[name]. Names
+// missing from vars are left as they are.
+func FillPlaceholders(text string, vars map[string]string) string {
+	return placeholderRegex.ReplaceAllStringFunc(text, func(m string) string {
+		if v, ok := vars[placeholderRegex.FindStringSubmatch(m)[1]]; ok {
+			return v
+		}
+		return m
+	})
+}
@@ -0,0 +1,17 @@
+package helpers
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPlaceholders(t *testing.T) {
+	text := "Welcome {{name}} to {{ team }}! {{name}}, meet {{ buddy}}. {{1x}} {x} {{}}"
+	if got := fmt.Sprint(ParsePlaceholders(text)); got != "[name team buddy]" {
+		t.Errorf("ParsePlaceholders = %s", got)
+	}
+	got := FillPlaceholders(text, map[string]string{"name": "Ada", "team": "{{buddy}}"})
+	if want := "Welcome Ada to {{buddy}}! Ada, meet {{ buddy}}. {{1x}} {x} {{}}"; got != want {
+		t.Errorf("FillPlaceholders = %q, want %q", got, want)
+	}
+}
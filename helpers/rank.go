@@ -0,0 +1,114 @@
+package helpers
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Rank keys order tasks by plain string comparison. A key never ends in
+// the lowest digit, so there is always room to insert before it.
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+var ErrInvalidRankRange = errors.New("invalid rank range")
+
+// RankBetween returns a key that sorts strictly between a and b. An empty a
+// means the start of the list and an empty b the end.
+func RankBetween(a, b string) (string, error) {
+	if b != "" && a >= b {
+		return "", ErrInvalidRankRange
+	}
+	if strings.HasSuffix(a, rankDigits[:1]) || strings.HasSuffix(b, rankDigits[:1]) {
+		return "", ErrInvalidRankRange
+	}
+	return rankMidpoint(a, b), nil
+}
+
+// rankAppendWidth is the least number of digits RankAfter counts in
+const rankAppendWidth = 4
+
+// RankAfter returns a key after a for appending to a list. Halving the gap
+// to the end would add a digit every few appends; instead a is counted up
+// in its last of at least rankAppendWidth digits, so keys only grow once
+// every digit is the highest.
+func RankAfter(a string) string {
+	if a == "" {
+		return rankMidpoint("", "")
+	}
+	width := len(a)
+	if width < rankAppendWidth {
+		width = rankAppendWidth
+	}
+	digits := []byte(a + strings.Repeat(rankDigits[:1], width-len(a)))
+	for i := len(digits) - 1; i >= 0; i-- {
+		if d := strings.IndexByte(rankDigits, digits[i]); d >= 0 && d < len(rankDigits)-1 {
+			digits[i] = rankDigits[d+1]
+			return strings.TrimRight(string(digits[:i+1]), rankDigits[:1])
+		}
+	}
+	// every digit is the highest: count on in one more digit
+	return a + rankDigits[1:2]
+}
+
+func rankMidpoint(a, b string) string {
+	if b != "" {
+		// keep the common prefix, padding a with the lowest digit
+		n := 0
+		for n < len(b) && rankDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + rankMidpoint(rest(a, n), b[n:])
+		}
+	}
+	lo := 0
+	if a != "" {
+		lo = strings.IndexByte(rankDigits, a[0])
+	}
+	hi := len(rankDigits)
+	if b != "" {
+		hi = strings.IndexByte(rankDigits, b[0])
+	}
+	if hi-lo > 1 {
+		return string(rankDigits[(lo+hi+1)/2])
+	}
+	// adjacent digits: extend a, or shorten b when it has room
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(rankDigits[lo]) + rankMidpoint(rest(a, 1), "")
+}
+
+func rankDigitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return rankDigits[0]
+}
+
+func rest(s string, n int) string {
+	if n >= len(s) {
+		return ""
+	}
+	return s[n:]
+}
+
+// RankSpread returns n increasing keys spaced evenly, all of the same
+// short length, for rebalancing a list
+func RankSpread(n int) []string {
+	base := big.NewInt(int64(len(rankDigits)))
+	width, space := 1, new(big.Int).Set(base)
+	for space.Cmp(big.NewInt(int64(n+1)*2)) < 0 {
+		width++
+		space.Mul(space, base)
+	}
+	step := new(big.Int).Div(space, big.NewInt(int64(n+1)))
+	keys := make([]string, n)
+	for i := range keys {
+		v := new(big.Int).Mul(step, big.NewInt(int64(i+1)))
+		s := v.Text(len(rankDigits))
+		s = strings.Repeat("0", width-len(s)) + s
+		keys[i] = strings.TrimRight(s, "0")
+	}
+	return keys
+}
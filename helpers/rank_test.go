@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRankBetween(t *testing.T) {
+	cases := []struct{ a, b string }{
+		{"", ""}, {"", "1"}, {"", "01"}, {"a", "b"}, {"az", "b"}, {"a1", "a2"}, {"zz", ""},
+	}
+	for _, tc := range cases {
+		k, err := RankBetween(tc.a, tc.b)
+		if err != nil {
+			t.Fatalf("RankBetween(%q, %q): %v", tc.a, tc.b, err)
+		}
+		if k <= tc.a || tc.b != "" && k >= tc.b {
+			t.Fatalf("RankBetween(%q, %q) = %q, not in range", tc.a, tc.b, k)
+		}
+	}
+	for _, tc := range []struct{ a, b string }{{"b", "a"}, {"a", "a"}, {"a0", ""}} {
+		if _, err := RankBetween(tc.a, tc.b); err == nil {
+			t.Fatalf("RankBetween(%q, %q) should fail", tc.a, tc.b)
+		}
+	}
+}
+
+func TestRankAfter(t *testing.T) {
+	cases := []struct{ a, want string }{
+		{"", "i"}, {"i", "i001"}, {"i00z", "i01"}, {"i01", "i011"}, {"azzz", "b"}, {"zzzz", "zzzz1"}, {"m5k2xy", "m5k2xz"},
+	}
+	for _, tc := range cases {
+		if got := RankAfter(tc.a); got != tc.want {
+			t.Fatalf("RankAfter(%q) = %q, want %q", tc.a, got, tc.want)
+		}
+	}
+	// appends stay short for a long time
+	k := RankAfter("")
+	for i := 0; i < 100000; i++ {
+		next := RankAfter(k)
+		if next <= k || strings.HasSuffix(next, "0") {
+			t.Fatalf("RankAfter(%q) = %q", k, next)
+		}
+		k = next
+	}
+	if len(k) > rankAppendWidth {
+		t.Fatalf("key after 100000 appends is %q", k)
+	}
+}
+
+func TestRankRandomInserts(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := RankSpread(10)
+	if !sort.StringsAreSorted(keys) {
+		t.Fatalf("spread not sorted: %v", keys)
+	}
+	for i := 0; i < 2000; i++ {
+		at := rng.Intn(len(keys) + 1)
+		var a, b string
+		if at > 0 {
+			a = keys[at-1]
+		}
+		if at < len(keys) {
+			b = keys[at]
+		}
+		k, err := RankBetween(a, b)
+		if err != nil {
+			t.Fatalf("RankBetween(%q, %q): %v", a, b, err)
+		}
+		keys = append(keys[:at], append([]string{k}, keys[at:]...)...)
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] {
+			t.Fatalf("keys out of order at %d: %q >= %q", i, keys[i-1], keys[i])
+		}
+	}
+	if spread := RankSpread(len(keys)); len(spread[len(spread)-1]) > 3 {
+		t.Fatalf("spread of %d keys too long: %q", len(keys), spread[len(spread)-1])
+	}
+}
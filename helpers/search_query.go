@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"strings"
+	"unicode"
+)
+
+const MaxSearchTerms = 10
+
+// SearchTerm is a word or a quoted phrase of a search query. With Prefix
+// set the last word also matches longer words.
+type SearchTerm struct {
+	Words  []string
+	Prefix bool
+}
+
+// ParseSearchQuery splits a user query into terms that must all match.
+// "quoted words" form a phrase and a trailing * matches prefixes. Anything
+// but letters and digits is dropped, so the result is safe to turn into
+// the database's query syntax.
+func ParseSearchQuery(q string) []SearchTerm {
+	var terms []SearchTerm
+	for len(q) > 0 && len(terms) < MaxSearchTerms {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+		var chunk string
+		if q[0] == '"' {
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				chunk, q = q[1:], ""
+			} else {
+				chunk, q = q[1:end+1], q[end+2:]
+			}
+			// a * right after the closing quote applies to the phrase
+			if strings.HasPrefix(q, "*") {
+				chunk += "*"
+				q = q[1:]
+			}
+		} else {
+			end := strings.IndexFunc(q, unicode.IsSpace)
+			if end < 0 {
+				end = len(q)
+			}
+			chunk, q = q[:end], q[end:]
+		}
+		t := SearchTerm{Prefix: strings.HasSuffix(strings.TrimSpace(chunk), "*")}
+		for _, w := range strings.FieldsFunc(chunk, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			t.Words = append(t.Words, strings.ToLower(w))
+		}
+		if len(t.Words) > 0 {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// FTS5Query renders terms as an SQLite FTS5 MATCH expression
+func FTS5Query(terms []SearchTerm) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = `"` + strings.Join(t.Words, " ") + `"`
+		if t.Prefix {
+			parts[i] += " *"
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// TSQuery renders terms as a Postgres to_tsquery expression
+func TSQuery(terms []SearchTerm) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		p := strings.Join(t.Words, " <-> ")
+		if t.Prefix {
+			p += ":*"
+		}
+		if len(t.Words) > 1 {
+			p = "(" + p + ")"
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, " & ")
+}
@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	cases := []struct {
+		in, fts, ts string
+	}{
+		{`milk eggs`, `"milk" AND "eggs"`, `milk & eggs`},
+		{`"quarterly report" draft*`, `"quarterly report" AND "draft" *`, `(quarterly <-> report) & draft:*`},
+		{`"buy gro"*`, `"buy gro" *`, `(buy <-> gro:*)`},
+		{`Ünïcode 2026`, `"ünïcode" AND "2026"`, `ünïcode & 2026`},
+		{`"unterminated phrase`, `"unterminated phrase"`, `(unterminated <-> phrase)`},
+		{`x') OR 1=1 --`, `"x" AND "or" AND "1 1"`, `x & or & (1 <-> 1)`},
+		{`   *  ""  `, ``, ``},
+	}
+	for _, tc := range cases {
+		terms := ParseSearchQuery(tc.in)
+		if got := FTS5Query(terms); got != tc.fts {
+			t.Errorf("FTS5Query(%q) = %s, want %s", tc.in, got, tc.fts)
+		}
+		if got := TSQuery(terms); got != tc.ts {
+			t.Errorf("TSQuery(%q) = %s, want %s", tc.in, got, tc.ts)
+		}
+	}
+	if n := len(ParseSearchQuery(fmt.Sprint(make([]int, 50)))); n != MaxSearchTerms {
+		t.Errorf("got %d terms, want at most %d", n, MaxSearchTerms)
+	}
+}
@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"go-todo-app/config"
+)
+
+func downloadSignature(attachmentID, expires int64) []byte {
+	mac := hmac.New(sha256.New, []byte(config.C.JWTSecret))
+	fmt.Fprintf(mac, "attachment:%d:%d", attachmentID, expires)
+	return mac.Sum(nil)
+}
+
+// SignedDownloadURL returns a download path for an attachment that is valid
+// until the configured TTL elapses, without requiring a bearer token
+func SignedDownloadURL(attachmentID int64) (string, time.Time) {
+	expires := time.Now().Add(config.C.DownloadURLTTL).Truncate(time.Second)
+	sig := hex.EncodeToString(downloadSignature(attachmentID, expires.Unix()))
+	return fmt.Sprintf("/files/%d?expires=%d&signature=%s", attachmentID, expires.Unix(), sig), expires
+}
+
+// VerifyDownloadSignature checks a signature created by SignedDownloadURL
+func VerifyDownloadSignature(attachmentID, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(sig, downloadSignature(attachmentID, expires))
+}
@@ -57,3 +57,33 @@ func IsStrongPassword(password string) (bool, string) {
 
 	return true, ""
 }
+
+var hexColorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// IsValidHexColor checks for a #RRGGBB color string
+func IsValidHexColor(color string) bool {
+	return hexColorRegex.MatchString(color)
+}
+
+var tagNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// IsValidTagName checks for a lowercase tag of letters, digits, - and _
+func IsValidTagName(name string) bool {
+	return tagNameRegex.MatchString(name)
+}
+
+var statusKeyRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,29}$`)
+
+// IsValidStatusKey checks for a lowercase workflow status key such as
+// in_review
+func IsValidStatusKey(key string) bool {
+	return statusKeyRegex.MatchString(key)
+}
+
+var fieldKeyRegex = regexp.MustCompile(`^[a-z][a-z0-9_]{0,29}$`)
+
+// IsValidFieldKey checks for a lowercase custom field key such as
+// ticket_no
+func IsValidFieldKey(key string) bool {
+	return fieldKeyRegex.MatchString(key)
+}
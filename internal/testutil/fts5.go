@@ -0,0 +1,7 @@
+//go:build sqlite_fts5
+
+package testutil
+
+// FTS5 reports whether the tests were built with SQLite full-text search,
+// in which case the search tests must run rather than skip
+const FTS5 = true
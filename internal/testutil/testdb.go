@@ -1,6 +1,9 @@
 package testutil
 
 import (
+	"errors"
+
+	"go-todo-app/config"
 	"go-todo-app/models"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -11,7 +14,12 @@ func NewTestDB() *gorm.DB {
 	if err != nil {
 		panic(err)
 	}
-	if err := db.AutoMigrate(&models.User{}, &models.Task{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.ProjectMember{}, &models.ProjectInvitation{}, &models.Task{}, &models.Notification{}, &models.Comment{}, &models.CommentEdit{}, &models.Attachment{}, &models.TaskActivity{}, &models.Tag{}, &models.TaskTag{}, &models.View{}, &models.TaskStatus{}, &models.CustomField{}, &models.TaskFieldValue{}, &models.TaskDependency{}, &models.TimeEntry{}, &models.BoardLimit{}, &models.Template{}, &models.ChecklistItem{}); err != nil {
+		panic(err)
+	}
+	// search tests skip when SQLite lacks FTS5, unless built with -tags
+	// sqlite_fts5
+	if err := config.SetupSearch(db); err != nil && (FTS5 || !errors.Is(err, config.ErrSearchUnsupported)) {
 		panic(err)
 	}
 	return db
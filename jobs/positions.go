@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"log"
+
+	"go-todo-app/config"
+	"go-todo-app/helpers"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// MaxPositionLength is the key length past which a list gets rebalanced
+const MaxPositionLength = 16
+
+// TaskList scopes a task query to the manually ordered list the task
+// belongs to: its project, or its creator's tasks outside any project
+func TaskList(task models.Task) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if task.ProjectID != nil {
+			return db.Where("tasks.project_id = ?", *task.ProjectID)
+		}
+		return db.Where("tasks.project_id IS NULL AND tasks.user_id = ?", task.UserID)
+	}
+}
+
+// RebalancePositions rewrites the positions of the list containing task to
+// short, evenly spaced keys, keeping the current order. Tasks without a
+// position go last, oldest first.
+func RebalancePositions(tx *gorm.DB, task models.Task) error {
+	var ids []int64
+	if err := tx.Unscoped().Model(&models.Task{}).Scopes(TaskList(task)).
+		Order("CASE WHEN position = '' THEN 1 ELSE 0 END, position, id").
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	keys := helpers.RankSpread(len(ids))
+	for i, id := range ids {
+		if err := tx.Unscoped().Model(&models.Task{}).Where("id = ?", id).
+			UpdateColumn("position", keys[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebalanceLongPositions rebalances every list holding a position key that
+// grew too long or that has tasks without a position yet
+func RebalanceLongPositions() (int, error) {
+	var lists []models.Task
+	if err := config.DB.Unscoped().Model(&models.Task{}).Select("project_id, user_id").
+		Where("position = '' OR LENGTH(position) > ?", MaxPositionLength).
+		Group("project_id, user_id").Find(&lists).Error; err != nil {
+		return 0, err
+	}
+	done := map[int64]bool{}
+	n := 0
+	for _, list := range lists {
+		if list.ProjectID != nil {
+			if done[*list.ProjectID] {
+				continue
+			}
+			done[*list.ProjectID] = true
+		}
+		if err := config.DB.Transaction(func(tx *gorm.DB) error {
+			return RebalancePositions(tx, list)
+		}); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func runRebalance(ctx context.Context) {
+	n, err := RebalanceLongPositions()
+	if err != nil {
+		log.Printf("position rebalance failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("position rebalance updated %d lists", n)
+	}
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go-todo-app/config"
+)
+
+// Start runs the background jobs until ctx is cancelled
+func Start(ctx context.Context) {
+	go every(ctx, config.C.TrashPurgeInterval, runTrashPurge)
+	go every(ctx, config.C.RebalanceInterval, runRebalance)
+	go every(ctx, config.C.SnoozeWakeInterval, runSnoozeWake)
+}
+
+func every(ctx context.Context, interval time.Duration, fn func(context.Context)) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	fn(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go-todo-app/config"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// WakeSnoozedTasks clears the snooze of tasks whose snooze has ended and
+// tells their assignee, or the owner of unassigned tasks, that they are
+// back. Finished tasks wake silently.
+func WakeSnoozedTasks() (int, error) {
+	var tasks []models.Task
+	if err := config.DB.Where("snoozed_until IS NOT NULL AND snoozed_until <= ?", time.Now().UTC()).
+		Order("snoozed_until asc").Limit(1000).Find(&tasks).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, t := range tasks {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			// the task may have been snoozed again since it was loaded
+			res := tx.Model(&models.Task{}).Where("id = ? AND snoozed_until = ?", t.ID, *t.SnoozedUntil).
+				Updates(map[string]interface{}{"snoozed_until": nil, "version": gorm.Expr("version + 1")})
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			n++
+			if t.StatusCategory == models.StatusCategoryDone {
+				return nil
+			}
+			userID := t.UserID
+			if t.AssigneeID != nil {
+				userID = *t.AssigneeID
+			}
+			return tx.Create(&models.Notification{
+				UserID:  userID,
+				TaskID:  &t.ID,
+				Type:    models.NotificationSnoozeEnded,
+				Message: fmt.Sprintf("%q is back from snooze", t.Title),
+			}).Error
+		})
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func runSnoozeWake(ctx context.Context) {
+	n, err := WakeSnoozedTasks()
+	if err != nil {
+		log.Printf("snooze wake failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("snooze wake woke %d tasks", n)
+	}
+}
@@ -0,0 +1,98 @@
+// Package jobs contains background maintenance work started from main.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-todo-app/config"
+	"go-todo-app/models"
+	"gorm.io/gorm"
+)
+
+// PurgeTasks permanently deletes the given tasks together with their
+// comments, tags, time entries, checklists, activity and attachments.
+// Notifications about them are kept without the link to the task.
+// Attachment blobs are removed after the transaction commits.
+func PurgeTasks(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var attachments []models.Attachment
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id IN ?", ids).Find(&attachments).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN ?", ids).Delete(&models.Attachment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("comment_id IN (SELECT id FROM comments WHERE task_id IN ?)", ids).
+			Delete(&models.CommentEdit{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN ?", ids).Delete(&models.Comment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN ?", ids).Delete(&models.TaskTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN ?", ids).Delete(&models.TaskFieldValue{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN ? OR blocker_id IN ?", ids, ids).Delete(&models.TaskDependency{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN ?", ids).Delete(&models.TimeEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN ?", ids).Delete(&models.ChecklistItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN ?", ids).Delete(&models.TaskActivity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Notification{}).Where("task_id IN ?", ids).
+			UpdateColumn("task_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Task{}).Where("parent_id IN ?", ids).
+			UpdateColumn("parent_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Task{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		if err := config.Blobs.Delete(ctx, a.StorageKey); err != nil {
+			log.Printf("attachment blob %s not deleted: %v", a.StorageKey, err)
+		}
+	}
+	return nil
+}
+
+// PurgeExpiredTrash permanently deletes tasks that have been in the trash
+// longer than the retention period
+func PurgeExpiredTrash(ctx context.Context, retention time.Duration) (int, error) {
+	var ids []int64
+	cutoff := time.Now().Add(-retention)
+	if err := config.DB.Unscoped().Model(&models.Task{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Limit(1000).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	return len(ids), PurgeTasks(ctx, ids)
+}
+
+func runTrashPurge(ctx context.Context) {
+	n, err := PurgeExpiredTrash(ctx, config.C.TrashRetention)
+	if err != nil {
+		log.Printf("trash purge failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("trash purge removed %d tasks", n)
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // time zones work on hosts without a zoneinfo database
 
 	"github.com/gin-gonic/gin"
 	"go-todo-app/config"
 	"go-todo-app/controllers"
+	"go-todo-app/jobs"
 	"go-todo-app/middlewares"
 	"go-todo-app/models"
 )
@@ -20,12 +22,25 @@ func main() {
 	config.Load()
 	gin.SetMode(config.C.GinMode)
 	config.ConnectDB()
+	config.ConnectStorage()
+
+	// tasks from before custom statuses need their category filled in
+	backfillCategory := !config.DB.Migrator().HasColumn(&models.Task{}, "status_category")
 
 	// Auto Migrate
-	err := config.DB.AutoMigrate(&models.User{}, &models.Task{})
+	err := config.DB.AutoMigrate(&models.User{}, &models.Project{}, &models.ProjectMember{}, &models.ProjectInvitation{}, &models.Task{}, &models.Notification{}, &models.Comment{}, &models.CommentEdit{}, &models.Attachment{}, &models.TaskActivity{}, &models.Tag{}, &models.TaskTag{}, &models.View{}, &models.TaskStatus{}, &models.CustomField{}, &models.TaskFieldValue{}, &models.TaskDependency{}, &models.TimeEntry{}, &models.BoardLimit{}, &models.Template{}, &models.ChecklistItem{})
 	if err != nil {
 		log.Fatal("Error migrating DB")
 	}
+	if backfillCategory {
+		if err := config.DB.Model(&models.Task{}).Unscoped().Where("status = ?", models.TaskStatusCompleted).
+			Update("status_category", models.StatusCategoryDone).Error; err != nil {
+			log.Fatal("Error migrating DB")
+		}
+	}
+	if err := config.SetupSearch(config.DB); err != nil {
+		log.Printf("full-text search disabled: %v", err)
+	}
 
 	router := gin.New()
 	router.Use(gin.Recovery())
@@ -41,14 +56,115 @@ func main() {
 	// Public routes
 	router.POST("/register", controllers.Register)
 	router.POST("/login", controllers.Login)
+	router.GET("/files/:id", controllers.DownloadAttachment) // signed URL, no bearer token
 
 	// Protected routes
 	api := router.Group("/api")
 	api.Use(middlewares.JWTAuth())
 	api.GET("/tasks", controllers.GetTasks)
+	api.GET("/tasks/search", controllers.SearchTasks)
 	api.POST("/tasks", controllers.CreateTask)
+	api.POST("/tasks/quick", controllers.QuickAddTask)
+	api.GET("/tasks/:id", controllers.GetTask)
 	api.PUT("/tasks/:id", controllers.UpdateTask)
+	api.PATCH("/tasks/:id", controllers.PatchTask)
+	api.POST("/tasks/bulk", controllers.BulkTasks)
 	api.DELETE("/tasks/:id", controllers.DeleteTask)
+	api.POST("/tasks/:id/restore", controllers.RestoreTask)
+	api.POST("/tasks/:id/move", controllers.MoveTask)
+	api.GET("/trash", controllers.GetTrash)
+	api.DELETE("/trash", controllers.EmptyTrash)
+	api.PUT("/tasks/:id/assignee", controllers.AssignTask)
+	api.DELETE("/tasks/:id/assignee", controllers.UnassignTask)
+	api.GET("/tasks/:id/comments", controllers.GetComments)
+	api.POST("/tasks/:id/comments", controllers.CreateComment)
+	api.PUT("/tasks/:id/comments/:comment_id", controllers.UpdateComment)
+	api.DELETE("/tasks/:id/comments/:comment_id", controllers.DeleteComment)
+	api.GET("/tasks/:id/comments/:comment_id/edits", controllers.GetCommentEdits)
+	api.GET("/tasks/:id/attachments", controllers.GetAttachments)
+	api.POST("/tasks/:id/attachments", controllers.UploadAttachment)
+	api.GET("/tasks/:id/attachments/:attachment_id", controllers.GetAttachment)
+	api.DELETE("/tasks/:id/attachments/:attachment_id", controllers.DeleteAttachment)
+	api.GET("/tasks/:id/history", controllers.GetTaskHistory)
+	api.GET("/tasks/:id/checklist", controllers.GetChecklist)
+	api.POST("/tasks/:id/checklist", controllers.AddChecklistItem)
+	api.PUT("/tasks/:id/checklist/order", controllers.ReorderChecklist)
+	api.PATCH("/tasks/:id/checklist/:item_id", controllers.UpdateChecklistItem)
+	api.POST("/tasks/:id/checklist/:item_id/toggle", controllers.ToggleChecklistItem)
+	api.DELETE("/tasks/:id/checklist/:item_id", controllers.DeleteChecklistItem)
+	api.PUT("/tasks/:id/star", controllers.StarTask)
+	api.DELETE("/tasks/:id/star", controllers.UnstarTask)
+	api.PUT("/tasks/:id/pin", controllers.PinTask)
+	api.DELETE("/tasks/:id/pin", controllers.UnpinTask)
+	api.PUT("/tasks/:id/snooze", controllers.SnoozeTask)
+	api.DELETE("/tasks/:id/snooze", controllers.UnsnoozeTask)
+	api.GET("/tasks/:id/dependencies", controllers.GetTaskDependencies)
+	api.POST("/tasks/:id/dependencies", controllers.AddTaskDependency)
+	api.DELETE("/tasks/:id/dependencies/:blocker_id", controllers.RemoveTaskDependency)
+	api.POST("/tasks/:id/timer/start", controllers.StartTimer)
+	api.POST("/tasks/:id/timer/stop", controllers.StopTimer)
+	api.GET("/tasks/:id/time-entries", controllers.GetTimeEntries)
+	api.POST("/tasks/:id/time-entries", controllers.CreateTimeEntry)
+	api.PUT("/tasks/:id/time-entries/:entry_id", controllers.UpdateTimeEntry)
+	api.DELETE("/tasks/:id/time-entries/:entry_id", controllers.DeleteTimeEntry)
+	api.GET("/timer", controllers.GetTimer)
+	api.GET("/time/report", controllers.GetTimeReport)
+	api.GET("/board", controllers.GetBoard)
+	api.PUT("/board/limits", controllers.SetBoardLimits)
+	api.POST("/board/move", controllers.MoveCard)
+	api.GET("/activity", controllers.GetActivityFeed)
+
+	api.PUT("/me/time-zone", controllers.SetTimeZone)
+
+	api.GET("/templates", controllers.GetTemplates)
+	api.POST("/templates", controllers.CreateTemplate)
+	api.GET("/templates/:id", controllers.GetTemplate)
+	api.PUT("/templates/:id", controllers.UpdateTemplate)
+	api.DELETE("/templates/:id", controllers.DeleteTemplate)
+	api.POST("/templates/:id/instantiate", controllers.InstantiateTemplate)
+
+	api.GET("/views", controllers.GetViews)
+	api.POST("/views", controllers.CreateView)
+	api.GET("/views/:id", controllers.GetView)
+	api.PUT("/views/:id", controllers.UpdateView)
+	api.DELETE("/views/:id", controllers.DeleteView)
+	api.POST("/views/:id/default", controllers.SetDefaultView)
+	api.DELETE("/views/:id/default", controllers.UnsetDefaultView)
+	api.GET("/views/:id/tasks", controllers.GetViewTasks)
+
+	api.GET("/statuses", controllers.GetStatuses)
+	api.PUT("/statuses", controllers.SetStatuses)
+	api.DELETE("/statuses", controllers.DeleteStatuses)
+
+	api.GET("/projects", controllers.GetProjects)
+	api.POST("/projects", controllers.CreateProject)
+	api.GET("/projects/:id", controllers.GetProject)
+	api.PUT("/projects/:id", controllers.UpdateProject)
+	api.DELETE("/projects/:id", controllers.DeleteProject)
+	api.POST("/projects/:id/archive", controllers.ArchiveProject)
+	api.POST("/projects/:id/unarchive", controllers.UnarchiveProject)
+	api.GET("/projects/:id/tasks", controllers.GetProjectTasks)
+	api.GET("/projects/:id/statuses", controllers.GetProjectStatuses)
+	api.PUT("/projects/:id/statuses", controllers.SetProjectStatuses)
+	api.DELETE("/projects/:id/statuses", controllers.DeleteProjectStatuses)
+	api.GET("/projects/:id/fields", controllers.GetProjectFields)
+	api.POST("/projects/:id/fields", controllers.CreateProjectField)
+	api.PUT("/projects/:id/fields/:field_id", controllers.UpdateProjectField)
+	api.DELETE("/projects/:id/fields/:field_id", controllers.DeleteProjectField)
+	api.GET("/projects/:id/members", controllers.GetProjectMembers)
+	api.PUT("/projects/:id/members/:user_id", controllers.UpdateProjectMember)
+	api.DELETE("/projects/:id/members/:user_id", controllers.RemoveProjectMember)
+	api.GET("/projects/:id/invitations", controllers.GetProjectInvitations)
+	api.POST("/projects/:id/invitations", controllers.InviteToProject)
+	api.DELETE("/projects/:id/invitations/:invitation_id", controllers.RevokeInvitation)
+
+	api.GET("/invitations", controllers.GetMyInvitations)
+	api.POST("/invitations/:id/accept", controllers.AcceptInvitation)
+	api.POST("/invitations/:id/decline", controllers.DeclineInvitation)
+
+	api.GET("/notifications", controllers.GetNotifications)
+	api.POST("/notifications/read", controllers.MarkAllNotificationsRead)
+	api.POST("/notifications/:id/read", controllers.MarkNotificationRead)
 
 	srv := &http.Server{
 		Addr:         ":" + config.C.Port,
@@ -58,6 +174,10 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobs.Start(jobsCtx)
+
 	// Graceful shutdown
 	go func() {
 		log.Printf("Server starting on port %s", config.C.Port)
@@ -71,6 +191,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+	stopJobs()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// TaskActivity is one entry of a task's audit trail. Updates produce one
+// entry per changed field.
+type TaskActivity struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	TaskID    int64     `gorm:"index;not null" json:"task_id"`
+	ProjectID *int64    `gorm:"index" json:"project_id,omitempty"`
+	ActorID   int64     `gorm:"index;not null" json:"actor_id"`
+	Actor     *UserRef  `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	Action    string    `gorm:"size:20;not null" json:"action"`
+	Field     string    `gorm:"size:50" json:"field,omitempty"`
+	OldValue  *string   `gorm:"type:text" json:"old_value"`
+	NewValue  *string   `gorm:"type:text" json:"new_value"`
+	RequestID string    `gorm:"size:64;index" json:"request_id,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+type Attachment struct {
+	ID          int64     `gorm:"primaryKey" json:"id"`
+	TaskID      int64     `gorm:"index;not null" json:"task_id"`
+	UserID      int64     `gorm:"index;not null" json:"user_id"`
+	FileName    string    `gorm:"size:255;not null" json:"file_name"`
+	ContentType string    `gorm:"size:100;not null" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	StorageKey  string    `gorm:"size:255;not null" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Filled in by the handlers
+	DownloadURL string     `gorm:"-" json:"download_url,omitempty"`
+	URLExpires  *time.Time `gorm:"-" json:"download_url_expires_at,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// BoardLimit is a work-in-progress limit: the most tasks one column of a
+// project's board should hold. Column is the column's key when the board
+// is grouped by GroupBy.
+type BoardLimit struct {
+	ID        int64  `gorm:"primaryKey" json:"-"`
+	ProjectID int64  `gorm:"not null;uniqueIndex:idx_board_limit" json:"-"`
+	GroupBy   string `gorm:"size:10;not null;uniqueIndex:idx_board_limit" json:"group_by"`
+	Column    string `gorm:"column:column_key;size:50;not null;uniqueIndex:idx_board_limit" json:"column"`
+	Limit     int    `gorm:"column:wip_limit;not null" json:"limit"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ChecklistItem is one line of a task's checklist, for steps too small to
+// be subtasks. Items are ordered by Position.
+type ChecklistItem struct {
+	ID        int64      `gorm:"primaryKey" json:"id"`
+	TaskID    int64      `gorm:"index;not null" json:"task_id"`
+	Text      string     `gorm:"size:255;not null" json:"text"`
+	Done      bool       `gorm:"not null;default:false" json:"done"`
+	DoneAt    *time.Time `json:"done_at"`
+	Position  int        `gorm:"not null" json:"position"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ChecklistProgress counts a task's checklist items
+type ChecklistProgress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+type Comment struct {
+	ID        int64      `gorm:"primaryKey" json:"id"`
+	TaskID    int64      `gorm:"index;not null" json:"task_id"`
+	UserID    int64      `gorm:"index;not null" json:"user_id"`
+	Author    *UserRef   `gorm:"foreignKey:UserID" json:"author,omitempty"`
+	Body      string     `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+}
+
+// CommentEdit keeps the body a comment had before an edit
+type CommentEdit struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	CommentID int64     `gorm:"index;not null" json:"comment_id"`
+	Body      string    `gorm:"type:text" json:"body"`
+	EditedAt  time.Time `json:"edited_at"`
+}
@@ -1,10 +1,15 @@
 package models
 
 const (
-	// Task Status
+	// Task Status, in the default workflow
 	TaskStatusPending   = "pending"
 	TaskStatusCompleted = "completed"
 
+	// Status categories, which every workflow status maps to
+	StatusCategoryTodo  = "todo"
+	StatusCategoryDoing = "doing"
+	StatusCategoryDone  = "done"
+
 	// Task Priority
 	TaskPriorityLow    = "low"
 	TaskPriorityMedium = "medium"
@@ -12,14 +17,14 @@ const (
 )
 
 var (
-	ValidTaskStatuses   = []string{TaskStatusPending, TaskStatusCompleted}
-	ValidTaskPriorities = []string{TaskPriorityLow, TaskPriorityMedium, TaskPriorityHigh}
+	ValidStatusCategories = []string{StatusCategoryTodo, StatusCategoryDoing, StatusCategoryDone}
+	ValidTaskPriorities   = []string{TaskPriorityLow, TaskPriorityMedium, TaskPriorityHigh}
 )
 
-// IsValidStatus checks if the status is valid
-func IsValidStatus(status string) bool {
-	for _, s := range ValidTaskStatuses {
-		if s == status {
+// IsValidStatusCategory checks if the status category is valid
+func IsValidStatusCategory(category string) bool {
+	for _, c := range ValidStatusCategories {
+		if c == category {
 			return true
 		}
 	}
@@ -35,3 +40,116 @@ func IsValidPriority(priority string) bool {
 	}
 	return false
 }
+
+const (
+	// Custom field types
+	FieldTypeText        = "text"
+	FieldTypeNumber      = "number"
+	FieldTypeDate        = "date"
+	FieldTypeSelect      = "select"
+	FieldTypeMultiSelect = "multi_select"
+	FieldTypeCheckbox    = "checkbox"
+	FieldTypeURL         = "url"
+)
+
+var ValidFieldTypes = []string{FieldTypeText, FieldTypeNumber, FieldTypeDate, FieldTypeSelect, FieldTypeMultiSelect, FieldTypeCheckbox, FieldTypeURL}
+
+// IsValidFieldType checks if the custom field type is valid
+func IsValidFieldType(t string) bool {
+	for _, v := range ValidFieldTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// Board groupings
+	BoardByStatus   = "status"
+	BoardByPriority = "priority"
+	BoardByAssignee = "assignee"
+	BoardByTag      = "tag"
+)
+
+var ValidBoardGroupings = []string{BoardByStatus, BoardByPriority, BoardByAssignee, BoardByTag}
+
+// IsValidBoardGrouping checks if tasks can be grouped into board columns by g
+func IsValidBoardGrouping(g string) bool {
+	for _, v := range ValidBoardGroupings {
+		if v == g {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// InboxProjectName is the name of the project every user gets on registration
+	InboxProjectName = "Inbox"
+)
+
+const (
+	// Project member roles
+	ProjectRoleOwner  = "owner"
+	ProjectRoleEditor = "editor"
+	ProjectRoleViewer = "viewer"
+
+	// Invitation status
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+var ValidProjectRoles = []string{ProjectRoleOwner, ProjectRoleEditor, ProjectRoleViewer}
+
+// IsValidProjectRole checks if the role is valid
+func IsValidProjectRole(role string) bool {
+	for _, r := range ValidProjectRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEditTasks reports whether a project role may create and modify tasks
+func CanEditTasks(role string) bool {
+	return role == ProjectRoleOwner || role == ProjectRoleEditor
+}
+
+const (
+	// Notification types
+	NotificationTaskAssigned  = "task_assigned"
+	NotificationMentioned     = "mentioned"
+	NotificationTaskUnblocked = "task_unblocked"
+	NotificationSnoozeEnded   = "snooze_ended"
+)
+
+// AllowedAttachmentTypes are the sniffed content types accepted for uploads
+var AllowedAttachmentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"text/plain; charset=utf-8",
+}
+
+// IsAllowedAttachmentType checks if the content type may be uploaded
+func IsAllowedAttachmentType(contentType string) bool {
+	for _, t := range AllowedAttachmentTypes {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// Task activity actions
+	ActivityCreated  = "created"
+	ActivityUpdated  = "updated"
+	ActivityDeleted  = "deleted"
+	ActivityRestored = "restored"
+)
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// TaskDependency records that a task is blocked by another: TaskID can't
+// be finished until BlockerID is done. Dependencies form a DAG.
+type TaskDependency struct {
+	TaskID    int64     `gorm:"primaryKey" json:"task_id"`
+	BlockerID int64     `gorm:"primaryKey;index" json:"blocker_id"`
+	CreatedBy int64     `gorm:"not null" json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
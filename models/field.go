@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// CustomField is a typed field a project defines for its tasks. Tasks
+// show their values under custom_fields by Key.
+type CustomField struct {
+	ID        int64  `gorm:"primaryKey" json:"id"`
+	ProjectID int64  `gorm:"uniqueIndex:idx_project_field;not null" json:"project_id"`
+	Key       string `gorm:"uniqueIndex:idx_project_field;size:30;not null" json:"key"`
+	Name      string `gorm:"size:50;not null" json:"name"`
+	Type      string `gorm:"size:15;not null" json:"type"`
+	Position  int    `gorm:"not null" json:"position"`
+
+	// Options are the choices of select and multi_select fields
+	Options   []string   `gorm:"type:text;serializer:json" json:"options,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// TaskFieldValue is a task's value for a custom field, kept in the column
+// that suits the field's type so values filter and sort natively
+type TaskFieldValue struct {
+	TaskID  int64      `gorm:"primaryKey"`
+	FieldID int64      `gorm:"primaryKey;index"`
+	Text    *string    `gorm:"type:text"` // text, url, select; multi_select as a JSON array
+	Number  *float64   // number; checkbox as 0 or 1
+	Date    *time.Time // date, at midnight UTC
+}
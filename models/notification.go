@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+type Notification struct {
+	ID        int64      `gorm:"primaryKey" json:"id"`
+	UserID    int64      `gorm:"index;not null" json:"user_id"`
+	ActorID   *int64     `json:"actor_id,omitempty"`
+	TaskID    *int64     `gorm:"index" json:"task_id,omitempty"`
+	Type      string     `gorm:"size:30;not null" json:"type"`
+	Message   string     `gorm:"size:255" json:"message"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
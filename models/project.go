@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// Project groups a user's tasks into a list. DefaultPriority is a per-project
+// setting applied to new tasks that don't specify a priority.
+type Project struct {
+	ID              int64      `gorm:"primaryKey" json:"id"`
+	UserID          int64      `gorm:"index;not null" json:"user_id"`
+	Name            string     `gorm:"size:100;not null" json:"name"`
+	Description     string     `gorm:"type:text" json:"description"`
+	Color           string     `gorm:"size:7" json:"color"`
+	DefaultPriority string     `gorm:"size:10;default:medium" json:"default_priority"`
+	IsInbox         bool       `gorm:"not null;default:false" json:"is_inbox"`
+	ArchivedAt      *time.Time `gorm:"index" json:"archived_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+
+	// Role is the requesting user's role, filled in by the handlers
+	Role string `gorm:"-" json:"role,omitempty"`
+}
+
+// ProjectMember grants a user access to a project they don't own. The
+// project's creator (Project.UserID) is always an implicit owner.
+type ProjectMember struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	ProjectID int64     `gorm:"uniqueIndex:idx_project_member;not null" json:"project_id"`
+	UserID    int64     `gorm:"uniqueIndex:idx_project_member;index;not null" json:"user_id"`
+	Role      string    `gorm:"size:10;not null" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ProjectInvitation struct {
+	ID          int64      `gorm:"primaryKey" json:"id"`
+	ProjectID   int64      `gorm:"index;not null" json:"project_id"`
+	InviterID   int64      `gorm:"not null" json:"inviter_id"`
+	InviteeID   int64      `gorm:"index;not null" json:"invitee_id"`
+	Role        string     `gorm:"size:10;not null" json:"role"`
+	Status      string     `gorm:"size:10;default:pending;index" json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// TaskStatus is one status of a workflow, the ordered set of statuses tasks
+// move through. A workflow belongs to a project (ProjectID set) or is a
+// user's default for the projects they own (UserID set); projects with
+// neither use DefaultWorkflow. Tasks store the Key.
+type TaskStatus struct {
+	ID        int64  `gorm:"primaryKey" json:"-"`
+	ProjectID *int64 `gorm:"index" json:"-"`
+	UserID    *int64 `gorm:"index" json:"-"`
+	Key       string `gorm:"size:30;not null" json:"key"`
+	Name      string `gorm:"size:50;not null" json:"name"`
+	Category  string `gorm:"size:10;not null" json:"category"` // todo|doing|done
+	Position  int    `gorm:"not null" json:"-"`
+
+	// Transitions lists the statuses a task may move to from this one;
+	// nil allows any
+	Transitions []string `gorm:"type:text;serializer:json" json:"transitions"`
+}
+
+// DefaultWorkflow is the workflow of projects that haven't defined one
+var DefaultWorkflow = []TaskStatus{
+	{Key: TaskStatusPending, Name: "Pending", Category: StatusCategoryTodo},
+	{Key: TaskStatusCompleted, Name: "Completed", Category: StatusCategoryDone},
+}
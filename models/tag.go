@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// Tag is a label shared by every task that uses the same name
+type Tag struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:50;uniqueIndex;not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaskTag links a task to a tag
+type TaskTag struct {
+	TaskID int64 `gorm:"primaryKey;autoIncrement:false" json:"task_id"`
+	TagID  int64 `gorm:"primaryKey;autoIncrement:false;index" json:"tag_id"`
+}
@@ -2,15 +2,48 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Task struct {
-	ID          int64      `gorm:"primaryKey" json:"id"`
-	UserID      int64      `gorm:"index;not null" json:"user_id"`
-	Title       string     `gorm:"size:255;not null" json:"title"`
-	Description string     `gorm:"type:text" json:"description"`
-	Priority    string     `gorm:"size:10;default:medium;index:idx_user_priority" json:"priority"`
-	Status      string     `gorm:"size:12;default:pending;index:idx_user_status" json:"status"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	ID              int64          `gorm:"primaryKey" json:"id"`
+	UserID          int64          `gorm:"index;not null" json:"user_id"`
+	ProjectID       *int64         `gorm:"index" json:"project_id"`
+	AssigneeID      *int64         `gorm:"index" json:"assignee_id"`
+	ParentID        *int64         `gorm:"index" json:"parent_id"` // set on subtasks
+	Title           string         `gorm:"size:255;not null" json:"title"`
+	Description     string         `gorm:"type:text" json:"description"`
+	Priority        string         `gorm:"size:10;default:medium;index:idx_user_priority" json:"priority"`
+	Status          string         `gorm:"size:30;default:pending;index:idx_user_status" json:"status"`
+	StatusCategory  string         `gorm:"size:10;default:todo;index" json:"status_category"` // Status's category in the task's workflow
+	Position        string         `gorm:"size:64;index" json:"position"`
+	DueAt           *time.Time     `gorm:"index" json:"due_at"`
+	EstimateMinutes *int           `json:"estimate_minutes"`
+	Recurrence      string         `gorm:"size:50" json:"recurrence"`         // repeat rule, see package recurrence; empty for one-off tasks
+	Version         int64          `gorm:"not null;default:1" json:"version"` // bumped on every change; see the ETag
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       *time.Time     `json:"updated_at,omitempty"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// AutoComplete finishes the task once every checklist item is done
+	AutoComplete bool `gorm:"not null;default:false" json:"checklist_auto_complete"`
+
+	// Pinned tasks are listed first. A snoozed task is left out of the task
+	// lists until SnoozedUntil passes.
+	Starred      bool       `gorm:"not null;default:false" json:"starred"`
+	Pinned       bool       `gorm:"not null;default:false" json:"pinned"`
+	SnoozedUntil *time.Time `gorm:"index" json:"snoozed_until"`
+
+	// Filled in for responses from other tables
+	CommentCount   int64    `gorm:"-" json:"comment_count"`
+	Tags           []string `gorm:"-" json:"tags"`
+	Blocked        bool     `gorm:"-" json:"blocked"`         // has blockers that aren't done
+	TrackedMinutes int64    `gorm:"-" json:"tracked_minutes"` // finished and running time entries
+
+	Checklist ChecklistProgress `gorm:"-" json:"checklist"`
+
+	// CustomFields maps the keys of the project's custom fields to the
+	// task's values
+	CustomFields map[string]interface{} `gorm:"-" json:"custom_fields"`
 }
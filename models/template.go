@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// Template is a reusable task, or tree of tasks, that a user instantiates
+// into a project. Titles and descriptions may hold {{variable}}
+// placeholders, filled in when instantiating.
+type Template struct {
+	ID          int64          `gorm:"primaryKey" json:"id"`
+	UserID      int64          `gorm:"uniqueIndex:idx_user_template;not null" json:"user_id"`
+	Name        string         `gorm:"uniqueIndex:idx_user_template;size:100;not null" json:"name"`
+	Description string         `gorm:"type:text" json:"description"`
+	Tasks       []TemplateTask `gorm:"type:text;serializer:json" json:"tasks"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   *time.Time     `json:"updated_at,omitempty"`
+
+	// Variables are the placeholders the tasks use
+	Variables []string `gorm:"-" json:"variables"`
+}
+
+// TemplateTask is a task of a template with its subtasks
+type TemplateTask struct {
+	Title           string   `json:"title"`
+	Description     string   `json:"description,omitempty"`
+	Priority        string   `json:"priority,omitempty"` // the project's default when empty
+	Tags            []string `json:"tags,omitempty"`
+	EstimateMinutes *int     `json:"estimate_minutes,omitempty"`
+
+	// DueOffsetDays sets the due date this many days after the start date
+	// given when instantiating
+	DueOffsetDays *int `json:"due_offset_days,omitempty"`
+
+	Subtasks []TemplateTask `json:"subtasks,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// TimeEntry is time a user spent on a task, tracked with a timer or
+// entered by hand. A running timer has no EndedAt yet; each user has at
+// most one.
+type TimeEntry struct {
+	ID        int64      `gorm:"primaryKey" json:"id"`
+	TaskID    int64      `gorm:"index;not null" json:"task_id"`
+	UserID    int64      `gorm:"index;not null;uniqueIndex:idx_running_timer,where:ended_at IS NULL" json:"user_id"`
+	StartedAt time.Time  `gorm:"index;not null" json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Seconds   int64      `gorm:"not null;default:0" json:"seconds"` // set once the entry has ended
+	Note      string     `gorm:"size:255" json:"note"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
@@ -10,4 +10,19 @@ type User struct {
 	Email        string    `gorm:"uniqueIndex;size:255;not null" json:"email"`
 	PasswordHash string    `gorm:"size:255;not null" json:"-"`
 	CreatedAt    time.Time `json:"created_at"`
+
+	// TimeZone is an IANA zone name such as Europe/Berlin; empty means UTC
+	TimeZone string `gorm:"size:64" json:"time_zone"`
+
+	// DefaultView is the id or built-in key of the user's default view
+	DefaultView string `gorm:"size:20" json:"-"`
 }
+
+// UserRef is the public part of a user shown to other users, e.g. as a
+// comment's author
+type UserRef struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+func (UserRef) TableName() string { return "users" }
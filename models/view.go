@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// View is a saved task list: a filter expression (see package filter) with
+// the sort order and grouping to show it in.
+type View struct {
+	ID        int64      `gorm:"primaryKey" json:"id"`
+	UserID    int64      `gorm:"uniqueIndex:idx_user_view;not null" json:"user_id"`
+	Name      string     `gorm:"uniqueIndex:idx_user_view;size:100;not null" json:"name"`
+	Query     string     `gorm:"type:text" json:"query"`
+	Sort      string     `gorm:"size:100" json:"sort"`
+	Nulls     string     `gorm:"size:5" json:"nulls"`
+	GroupBy   string     `gorm:"size:20" json:"group_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+
+	// Key names built-in views, which aren't stored
+	Key       string `gorm:"-" json:"key,omitempty"`
+	IsDefault bool   `gorm:"-" json:"is_default"`
+}
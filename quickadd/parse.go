@@ -0,0 +1,466 @@
+// Package quickadd parses a one-line task such as
+//
+//	Pay rent every 1st !high #finance @home due friday 9am
+//
+// into a title and the task's properties:
+//
+//	!high !medium !low (or !1 !2 !3)   priority
+//	#tag                               tags, any number
+//	@name                              the project, by name
+//	every ...                          a recurrence, e.g. every day, every other
+//	                                   week, every mon and thu, every 15th
+//	[due|on|by] date [[at] time]       the due date: today, tomorrow, a weekday,
+//	                                   next week, in 3 days, 2026-05-01, may 5
+//	[at] time                          9am, 9:30pm, 17:00, noon
+//
+// Words that don't parse stay in the title. Dates are resolved relative to
+// the time passed to Parse, in its location.
+package quickadd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-todo-app/recurrence"
+)
+
+const MaxLength = 500
+
+const (
+	KindTitle      = "title"
+	KindPriority   = "priority"
+	KindTag        = "tag"
+	KindProject    = "project"
+	KindDue        = "due"
+	KindRecurrence = "recurrence"
+)
+
+// Token is a part of the line and what it was read as. Start and End are
+// character offsets into the line, End exclusive.
+type Token struct {
+	Kind  string `json:"kind"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type Result struct {
+	Title      string     `json:"title"`
+	Priority   string     `json:"priority,omitempty"`
+	Tags       []string   `json:"tags"`
+	Project    string     `json:"project,omitempty"`
+	DueAt      *time.Time `json:"due_at"`
+	Recurrence string     `json:"recurrence,omitempty"` // in the form stored on tasks, see package recurrence
+	Tokens     []Token    `json:"tokens"`
+}
+
+type word struct {
+	text  string
+	key   string // lowercased, without trailing commas and periods
+	start int
+	end   int
+}
+
+// split breaks the line into words, keeping their character offsets
+func split(line string) []word {
+	var words []word
+	var cur []rune
+	start, pos := 0, 0
+	flush := func() {
+		if len(cur) > 0 {
+			text := string(cur)
+			words = append(words, word{text: text, key: strings.TrimRight(strings.ToLower(text), ",."), start: start, end: pos})
+			cur = nil
+		}
+	}
+	for _, r := range line {
+		if unicode.IsSpace(r) {
+			flush()
+		} else {
+			if len(cur) == 0 {
+				start = pos
+			}
+			cur = append(cur, r)
+		}
+		pos++
+	}
+	flush()
+	return words
+}
+
+var priorities = map[string]string{
+	"high": "high", "h": "high", "1": "high",
+	"medium": "medium", "med": "medium", "m": "medium", "2": "medium",
+	"low": "low", "l": "low", "3": "low",
+}
+
+// dueSpan is a date and/or time found in the line
+type dueSpan struct {
+	from, to int // word indexes, to exclusive
+	day      *time.Time
+	clock    *[2]int // hour, minute
+	explicit bool    // introduced by due, on or by
+}
+
+// Parse reads a quick-add line. now sets the reference day and location for
+// relative dates.
+func Parse(line string, now time.Time) (*Result, error) {
+	if len([]rune(line)) > MaxLength {
+		return nil, fmt.Errorf("the line can be at most %d characters", MaxLength)
+	}
+	words := split(line)
+	kinds := make([]string, len(words))
+	mark := func(from, to int, kind string) {
+		for i := from; i < to; i++ {
+			kinds[i] = kind
+		}
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	res := &Result{Tags: []string{}}
+	var rule *recurrence.Rule
+	var dues []dueSpan
+
+	for i := 0; i < len(words); {
+		w := words[i]
+		switch {
+		case len(w.text) > 1 && w.text[0] == '!':
+			if p, ok := priorities[w.key[1:]]; ok {
+				res.Priority = p
+				mark(i, i+1, KindPriority)
+				i++
+				continue
+			}
+		case len(w.text) > 1 && w.text[0] == '#':
+			res.Tags = append(res.Tags, strings.TrimRight(w.text[1:], ",."))
+			mark(i, i+1, KindTag)
+			i++
+			continue
+		case len(w.text) > 1 && w.text[0] == '@':
+			if res.Project != "" {
+				return nil, errors.New("only one @project can be given")
+			}
+			res.Project = strings.TrimRight(w.text[1:], ",.")
+			mark(i, i+1, KindProject)
+			i++
+			continue
+		case w.key == "every":
+			if r, n, ok := parseEvery(words[i+1:]); ok {
+				if rule != nil {
+					return nil, errors.New("only one recurrence can be given")
+				}
+				rule = &r
+				mark(i, i+1+n, KindRecurrence)
+				i += 1 + n
+				continue
+			}
+		}
+		if d, ok := parseDue(words, i, today); ok {
+			dues = append(dues, d)
+			i = d.to
+			continue
+		}
+		i++
+	}
+
+	// a due date introduced by due, on or by beats a bare one; otherwise
+	// the last wins. The others stay in the title.
+	var due *dueSpan
+	for i := range dues {
+		if due == nil || dues[i].explicit || !due.explicit {
+			due = &dues[i]
+		}
+	}
+	if due != nil {
+		mark(due.from, due.to, KindDue)
+		res.DueAt = dueTime(*due, rule, now, today)
+	} else if rule != nil {
+		first := rule.First(today)
+		res.DueAt = &first
+	}
+	if rule != nil {
+		res.Recurrence = rule.String()
+	}
+
+	var title []string
+	runes := []rune(line)
+	for i := 0; i < len(words); {
+		j := i + 1
+		for j < len(words) && kinds[j] == kinds[i] && (kinds[i] == "" || kinds[i] == KindDue || kinds[i] == KindRecurrence) {
+			j++
+		}
+		kind := kinds[i]
+		if kind == "" {
+			kind = KindTitle
+			for _, w := range words[i:j] {
+				title = append(title, w.text)
+			}
+		}
+		start, end := words[i].start, words[j-1].end
+		res.Tokens = append(res.Tokens, Token{Kind: kind, Text: string(runes[start:end]), Start: start, End: end})
+		i = j
+	}
+	res.Title = strings.Join(title, " ")
+	if res.Title == "" {
+		return nil, errors.New("the task needs a title")
+	}
+	return res, nil
+}
+
+// dueTime resolves the due date. A time alone means the next time the
+// clock shows it, on the recurrence's schedule if there is one.
+func dueTime(d dueSpan, rule *recurrence.Rule, now, today time.Time) *time.Time {
+	day := today
+	if d.day != nil {
+		day = *d.day
+	}
+	if d.clock != nil {
+		day = time.Date(day.Year(), day.Month(), day.Day(), d.clock[0], d.clock[1], 0, 0, day.Location())
+	}
+	if d.day == nil {
+		if rule != nil {
+			day = rule.First(day)
+			if !day.After(now) {
+				day = rule.Next(day)
+			}
+		} else if !day.After(now) {
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+	return &day
+}
+
+// parseDue reads a due date and/or time starting at words[i]
+func parseDue(words []word, i int, today time.Time) (dueSpan, bool) {
+	d := dueSpan{from: i}
+	j := i
+	switch words[i].key {
+	case "due", "on", "by":
+		d.explicit = true
+		j++
+	}
+	if j >= len(words) {
+		return d, false
+	}
+	if day, n, ok := parseDate(words[j:], today); ok {
+		d.day = &day
+		j += n
+	}
+	k := j
+	if k < len(words) && words[k].key == "at" {
+		k++
+	}
+	if k < len(words) {
+		if clock, ok := parseClock(words[k].key); ok {
+			d.clock = &clock
+			j = k + 1
+		}
+	}
+	d.to = j
+	return d, d.day != nil || d.clock != nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+// parseDate reads a date at the start of words and returns it with the
+// number of words it took
+func parseDate(words []word, today time.Time) (time.Time, int, bool) {
+	key := func(i int) string {
+		if i < len(words) {
+			return words[i].key
+		}
+		return ""
+	}
+	switch key(0) {
+	case "today", "tod":
+		return today, 1, true
+	case "tomorrow", "tmr", "tmrw":
+		return today.AddDate(0, 0, 1), 1, true
+	case "next":
+		if key(1) == "week" {
+			return nextWeekday(today, time.Monday), 2, true
+		}
+		if wd, ok := weekdays[key(1)]; ok {
+			return nextWeekday(today, wd), 2, true
+		}
+	case "in":
+		n, err := strconv.Atoi(key(1))
+		if err != nil || n < 1 || n > 3650 {
+			break
+		}
+		switch key(2) {
+		case "day", "days":
+			return today.AddDate(0, 0, n), 3, true
+		case "week", "weeks":
+			return today.AddDate(0, 0, 7*n), 3, true
+		case "month", "months":
+			return today.AddDate(0, n, 0), 3, true
+		}
+	}
+	if wd, ok := weekdays[key(0)]; ok {
+		return nextWeekday(today, wd), 1, true
+	}
+	if day, err := time.ParseInLocation("2006-01-02", key(0), today.Location()); err == nil {
+		return day, 1, true
+	}
+	if m, ok := months[key(0)]; ok {
+		if d, ok := ordinal(key(1)); ok {
+			return inYear(today, m, d), 2, true
+		}
+	}
+	if d, ok := ordinal(key(0)); ok {
+		if m, ok := months[key(1)]; ok {
+			return inYear(today, m, d), 2, true
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// nextWeekday returns the first such weekday after today
+func nextWeekday(today time.Time, wd time.Weekday) time.Time {
+	days := (int(wd) - int(today.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return today.AddDate(0, 0, days)
+}
+
+// inYear returns the next month/day that isn't before today
+func inYear(today time.Time, m time.Month, d int) time.Time {
+	day := time.Date(today.Year(), m, d, 0, 0, 0, 0, today.Location())
+	if day.Before(today) {
+		day = day.AddDate(1, 0, 0)
+	}
+	return day
+}
+
+var ordinalRe = regexp.MustCompile(`^([0-9]{1,2})(st|nd|rd|th)?$`)
+
+// ordinal reads a day of the month such as 5 or 21st
+func ordinal(s string) (int, bool) {
+	m := ordinalRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n, n >= 1 && n <= 31
+}
+
+var clockRe = regexp.MustCompile(`^([0-9]{1,2})(?::([0-9]{2}))?(am|pm)?$`)
+
+// parseClock reads a time of day. A bare number needs am or pm.
+func parseClock(s string) ([2]int, bool) {
+	switch s {
+	case "noon":
+		return [2]int{12, 0}, true
+	case "midnight":
+		return [2]int{0, 0}, true
+	}
+	m := clockRe.FindStringSubmatch(s)
+	if m == nil || m[2] == "" && m[3] == "" {
+		return [2]int{}, false
+	}
+	h, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	if min > 59 {
+		return [2]int{}, false
+	}
+	switch m[3] {
+	case "":
+		if h > 23 {
+			return [2]int{}, false
+		}
+	default:
+		if h < 1 || h > 12 {
+			return [2]int{}, false
+		}
+		h %= 12
+		if m[3] == "pm" {
+			h += 12
+		}
+	}
+	return [2]int{h, min}, true
+}
+
+var units = map[string]string{
+	"day": recurrence.Daily, "days": recurrence.Daily,
+	"week": recurrence.Weekly, "weeks": recurrence.Weekly,
+	"month": recurrence.Monthly, "months": recurrence.Monthly,
+	"year": recurrence.Yearly, "years": recurrence.Yearly,
+}
+
+// parseEvery reads what follows "every" and returns the rule with the
+// number of words it took
+func parseEvery(words []word) (recurrence.Rule, int, bool) {
+	key := func(i int) string {
+		if i < len(words) {
+			return words[i].key
+		}
+		return ""
+	}
+	rule := func(s string, n int) (recurrence.Rule, int, bool) {
+		r, err := recurrence.Parse(s)
+		return r, n, err == nil
+	}
+	if freq, ok := units[key(0)]; ok {
+		return rule(freq, 1)
+	}
+	switch key(0) {
+	case "weekday", "weekdays":
+		return rule("weekly:mon,tue,wed,thu,fri", 1)
+	case "other":
+		if freq, ok := units[key(1)]; ok {
+			return rule(freq+"/2", 2)
+		}
+	}
+	if n, err := strconv.Atoi(key(0)); err == nil {
+		if freq, ok := units[key(1)]; ok {
+			return rule(fmt.Sprintf("%s/%d", freq, n), 2)
+		}
+	}
+	if d, ok := ordinal(key(0)); ok && key(0) != strconv.Itoa(d) {
+		return rule(fmt.Sprintf("monthly:%d", d), 1)
+	}
+	// one or more weekdays: every mon, wed and fri
+	var days []string
+	n := 0
+	for i := 0; ; i++ {
+		if wd, ok := weekdays[key(i)]; ok {
+			days = append(days, strings.ToLower(wd.String()[:3]))
+			n = i + 1
+		} else if key(i) != "and" || len(days) == 0 {
+			break
+		}
+	}
+	if len(days) > 0 {
+		return rule("weekly:"+strings.Join(days, ","), n)
+	}
+	return recurrence.Rule{}, 0, false
+}
@@ -0,0 +1,75 @@
+package quickadd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// a Wednesday afternoon in Berlin
+	loc := time.FixedZone("CET", 3600)
+	now := time.Date(2026, 4, 15, 14, 0, 0, 0, loc)
+	cases := []struct{ in, want string }{
+		{"Pay rent every 1st !high #finance @home due friday 9am",
+			"Pay rent|high|[finance]|home|Fri 2026-04-17 09:00|monthly:1"},
+		{"Buy milk", "Buy milk||[]||-|"},
+		{"Call mom tomorrow", "Call mom||[]||Thu 2026-04-16 00:00|"},
+		{"Standup every weekday at 9:30", "Standup||[]||Thu 2026-04-16 09:30|weekly:mon,tue,wed,thu,fri"},
+		{"Gym every mon and thu 7pm", "Gym||[]||Thu 2026-04-16 19:00|weekly:mon,thu"},
+		{"Water plants every other day", "Water plants||[]||Wed 2026-04-15 00:00|daily/2"},
+		{"Report !2 #work #q2 in 3 days", "Report|medium|[work q2]||Sat 2026-04-18 00:00|"},
+		{"Dinner at 7:30pm", "Dinner||[]||Wed 2026-04-15 19:30|"},
+		{"Lunch at noon", "Lunch||[]||Thu 2026-04-16 12:00|"},
+		{"Wake up 6am", "Wake up||[]||Thu 2026-04-16 06:00|"},
+		{"Taxes on apr 10", "Taxes||[]||Sat 2027-04-10 00:00|"},
+		{"Dentist 3 may 10:15", "Dentist||[]||Sun 2026-05-03 10:15|"},
+		{"Review monday notes due 2026-05-01", "Review monday notes||[]||Fri 2026-05-01 00:00|"},
+		{"Work on report next week !urgent", "Work on report !urgent||[]||Mon 2026-04-20 00:00|"},
+		{"Back up every 2 weeks", "Back up||[]||Wed 2026-04-15 00:00|weekly/2"},
+		{"Read every chapter", "Read every chapter||[]||-|"},
+	}
+	for _, tc := range cases {
+		r, err := Parse(tc.in, now)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.in, err)
+			continue
+		}
+		due := "-"
+		if r.DueAt != nil {
+			due = r.DueAt.Format("Mon 2006-01-02 15:04")
+		}
+		got := fmt.Sprintf("%s|%s|%v|%s|%s|%s", r.Title, r.Priority, r.Tags, r.Project, due, r.Recurrence)
+		if got != tc.want {
+			t.Errorf("Parse(%q)\n got %s\nwant %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseTokens(t *testing.T) {
+	line := "Pay rent every 1st !high #finance @home due friday 9am"
+	r, err := Parse(line, time.Date(2026, 4, 15, 14, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tok := range r.Tokens {
+		if tok.Text != string([]rune(line)[tok.Start:tok.End]) {
+			t.Errorf("%+v: offsets don't match the text", tok)
+		}
+		got = append(got, tok.Kind+"="+tok.Text)
+	}
+	want := "title=Pay rent|recurrence=every 1st|priority=!high|tag=#finance|project=@home|due=due friday 9am"
+	if strings.Join(got, "|") != want {
+		t.Errorf("tokens = %s", strings.Join(got, "|"))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{"", "#just #tags", "x @a @b", "x every day every week", strings.Repeat("x", MaxLength+1)} {
+		if _, err := Parse(in, time.Now()); err == nil {
+			t.Errorf("Parse(%q) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Package recurrence handles the repeat rules of recurring tasks. Rules are
+// stored in a compact form:
+//
+//	daily  weekly  monthly  yearly      every day, week, ...
+//	weekly/2                            every other week
+//	weekly:mon,fri                      on those weekdays
+//	monthly:15                          on that day of the month
+//
+// An interval (/N) can be combined with the weekdays or the day of the
+// month, e.g. weekly/2:tue. Occurrences are computed in the location of
+// the times passed in, so they keep their wall clock time across DST.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+	Yearly  = "yearly"
+
+	MaxInterval = 365
+)
+
+var weekdayKeys = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Rule is a parsed repeat rule. Weekdays only apply to weekly rules and
+// MonthDay (1-31, clamped to short months) only to monthly ones.
+type Rule struct {
+	Freq     string
+	Interval int
+	Weekdays []time.Weekday // in week order, Monday first
+	MonthDay int
+}
+
+// Parse reads a rule in the stored form
+func Parse(s string) (Rule, error) {
+	r := Rule{Interval: 1}
+	s = strings.ToLower(strings.TrimSpace(s))
+	head, by, hasBy := strings.Cut(s, ":")
+	freq, interval, hasInterval := strings.Cut(head, "/")
+	switch freq {
+	case Daily, Weekly, Monthly, Yearly:
+		r.Freq = freq
+	default:
+		return r, errors.New("recurrence must be daily, weekly, monthly or yearly, e.g. weekly:mon,fri or monthly/2:15")
+	}
+	if hasInterval {
+		n, err := strconv.Atoi(interval)
+		if err != nil || n < 1 || n > MaxInterval {
+			return r, fmt.Errorf("recurrence interval must be between 1 and %d", MaxInterval)
+		}
+		r.Interval = n
+	}
+	if !hasBy {
+		return r, nil
+	}
+	switch r.Freq {
+	case Weekly:
+		seen := map[time.Weekday]bool{}
+		for _, key := range strings.Split(by, ",") {
+			d, ok := weekdayByKey(strings.TrimSpace(key))
+			if !ok {
+				return r, fmt.Errorf("unknown weekday %q; use %s", key, strings.Join(weekdayKeys, "|"))
+			}
+			seen[d] = true
+		}
+		for _, d := range weekOrder {
+			if seen[d] {
+				r.Weekdays = append(r.Weekdays, d)
+			}
+		}
+	case Monthly:
+		n, err := strconv.Atoi(by)
+		if err != nil || n < 1 || n > 31 {
+			return r, errors.New("the day of the month must be between 1 and 31")
+		}
+		r.MonthDay = n
+	default:
+		return r, fmt.Errorf("%s rules take no %q", r.Freq, ":"+by)
+	}
+	return r, nil
+}
+
+// Normalize parses a rule and returns its canonical form
+func Normalize(s string) (string, error) {
+	r, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+func (r Rule) String() string {
+	s := r.Freq
+	if r.Interval > 1 {
+		s += "/" + strconv.Itoa(r.Interval)
+	}
+	switch {
+	case len(r.Weekdays) > 0:
+		keys := make([]string, len(r.Weekdays))
+		for i, d := range r.Weekdays {
+			keys[i] = weekdayKeys[d]
+		}
+		s += ":" + strings.Join(keys, ",")
+	case r.MonthDay > 0:
+		s += ":" + strconv.Itoa(r.MonthDay)
+	}
+	return s
+}
+
+// First returns the first occurrence at or after t, keeping t's clock time
+func (r Rule) First(t time.Time) time.Time {
+	switch {
+	case len(r.Weekdays) > 0:
+		for i := 0; i < 7; i++ {
+			if d := t.AddDate(0, 0, i); r.onWeekday(d.Weekday()) {
+				return d
+			}
+		}
+	case r.MonthDay > 0:
+		if day := inMonth(t, 0, r.MonthDay); !day.Before(t) {
+			return day
+		}
+		return inMonth(t, 1, r.MonthDay)
+	}
+	return t
+}
+
+// Next returns the occurrence after t, keeping t's clock time
+func (r Rule) Next(t time.Time) time.Time {
+	switch r.Freq {
+	case Daily:
+		return t.AddDate(0, 0, r.Interval)
+	case Weekly:
+		if len(r.Weekdays) == 0 {
+			return t.AddDate(0, 0, 7*r.Interval)
+		}
+		for i := 1; i <= 7; i++ {
+			d := t.AddDate(0, 0, i)
+			if !r.onWeekday(d.Weekday()) {
+				continue
+			}
+			// weeks start on Monday; moving into a new week skips the
+			// weeks left out by the interval
+			if weekIndex(d.Weekday()) <= weekIndex(t.Weekday()) {
+				d = d.AddDate(0, 0, 7*(r.Interval-1))
+			}
+			return d
+		}
+	case Monthly:
+		day := r.MonthDay
+		if day == 0 {
+			day = t.Day()
+		}
+		return inMonth(t, r.Interval, day)
+	case Yearly:
+		return inMonth(t, 12*r.Interval, t.Day())
+	}
+	return t
+}
+
+// After returns the first occurrence following t that is later than now,
+// skipping the ones missed in between
+func (r Rule) After(t, now time.Time) time.Time {
+	next := r.Next(t)
+	for i := 0; !next.After(now) && i < 10000; i++ {
+		next = r.Next(next)
+	}
+	return next
+}
+
+func (r Rule) onWeekday(d time.Weekday) bool {
+	for _, w := range r.Weekdays {
+		if w == d {
+			return true
+		}
+	}
+	return false
+}
+
+var weekOrder = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+func weekIndex(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+func weekdayByKey(key string) (time.Weekday, bool) {
+	for i, k := range weekdayKeys {
+		if k == key {
+			return time.Weekday(i), true
+		}
+	}
+	return 0, false
+}
+
+// inMonth returns t moved months ahead to the given day, clamped to the
+// last day of that month
+func inMonth(t time.Time, months, day int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,78 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"daily", "daily"},
+		{"Weekly/1", "weekly"},
+		{"weekly/2:fri,mon,fri", "weekly/2:mon,fri"},
+		{"weekly:sun,sat", "weekly:sat,sun"},
+		{"monthly:31", "monthly:31"},
+		{"yearly/3", "yearly/3"},
+	}
+	for _, tc := range cases {
+		if got, err := Normalize(tc.in); err != nil || got != tc.want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"", "hourly", "daily/0", "weekly/x", "weekly:mo", "monthly:32", "daily:mon", "yearly:1"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	// 2026-01-30 is a Friday
+	start := time.Date(2026, 1, 30, 9, 0, 0, 0, time.UTC)
+	day := func(t time.Time) string { return t.Format("Mon 2006-01-02 15:04") }
+	cases := []struct {
+		rule        string
+		first, next string
+	}{
+		{"daily/3", "Fri 2026-01-30 09:00", "Mon 2026-02-02 09:00"},
+		{"weekly", "Fri 2026-01-30 09:00", "Fri 2026-02-06 09:00"},
+		{"weekly:tue,fri", "Fri 2026-01-30 09:00", "Tue 2026-02-03 09:00"},
+		{"weekly/2:mon", "Mon 2026-02-02 09:00", "Mon 2026-02-16 09:00"},
+		{"monthly:1", "Sun 2026-02-01 09:00", "Sun 2026-03-01 09:00"},
+		{"monthly:31", "Sat 2026-01-31 09:00", "Sat 2026-02-28 09:00"},
+		{"yearly", "Fri 2026-01-30 09:00", "Sat 2027-01-30 09:00"},
+	}
+	for _, tc := range cases {
+		r, err := Parse(tc.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := r.First(start)
+		if got := day(first); got != tc.first {
+			t.Errorf("%s: First = %s, want %s", tc.rule, got, tc.first)
+		}
+		if got := day(r.Next(first)); got != tc.next {
+			t.Errorf("%s: Next = %s, want %s", tc.rule, got, tc.next)
+		}
+	}
+
+	// months are clamped rather than overflowing
+	r, _ := Parse("monthly")
+	if got := day(r.Next(start)); got != "Sat 2026-02-28 09:00" {
+		t.Errorf("monthly from Jan 30 = %s", got)
+	}
+	// the wall clock time survives DST
+	ny, err := time.LoadLocation("America/New_York")
+	if err == nil {
+		r, _ := Parse("daily")
+		if got := r.Next(time.Date(2026, 3, 7, 9, 0, 0, 0, ny)); got.Hour() != 9 {
+			t.Errorf("daily across DST = %s", got)
+		}
+	}
+	// missed occurrences are skipped
+	r, _ = Parse("weekly")
+	now := time.Date(2026, 2, 20, 12, 0, 0, 0, time.UTC)
+	if got := day(r.After(start, now)); got != "Fri 2026-02-27 09:00" {
+		t.Errorf("After = %s", got)
+	}
+}